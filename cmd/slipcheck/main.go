@@ -0,0 +1,124 @@
+// Command slipcheck runs bank templates against sample OCR texts and shows
+// which fields each pattern fills. Use it to try out a template before
+// dropping it into the templates directory.
+//
+//	go run ./cmd/slipcheck -templates ./banks -bank KBank ./samples/kbank
+//
+// Every *.txt file in the samples directory is treated as the OCR text of one
+// slip. Without -bank the bank is detected the same way uploads are.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"ocr-api/ocr"
+)
+
+func main() {
+	templatesDir := flag.String("templates", "./banks", "directory of YAML bank templates")
+	bank := flag.String("bank", "", "template to use (default: detect from each text)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: slipcheck [-templates dir] [-bank name] <samples-dir>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Extraction logs every match; the table below already shows them
+	log.SetOutput(io.Discard)
+
+	registry, err := ocr.NewTemplateRegistry(*templatesDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid bank templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	var template *ocr.BankTemplate
+	if *bank != "" {
+		if template = registry.Lookup(*bank); template == nil {
+			fmt.Fprintf(os.Stderr, "No template named %q\n", *bank)
+			os.Exit(1)
+		}
+	}
+
+	samples, err := filepath.Glob(filepath.Join(flag.Arg(0), "*.txt"))
+	if err != nil || len(samples) == 0 {
+		fmt.Fprintf(os.Stderr, "No *.txt samples found in %s\n", flag.Arg(0))
+		os.Exit(1)
+	}
+	sort.Strings(samples)
+
+	fields := ocr.TemplateFields()
+	filled := make(map[string]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for _, sample := range samples {
+		content, err := os.ReadFile(sample)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", sample, err)
+			os.Exit(1)
+		}
+		text := string(content)
+
+		used, how := template, "given"
+		if used == nil {
+			if used, how = registry.Detect(text), "detected"; used == nil {
+				used, how = registry.Fallback(), "fallback"
+			}
+		}
+
+		fmt.Fprintf(w, "%s\n", filepath.Base(sample))
+		if used == nil {
+			fmt.Fprintf(w, "  no template matched and there is no fallback\n\n")
+			continue
+		}
+		fmt.Fprintf(w, "  bank\t%s\t(%s)\n", used.Name, how)
+
+		for _, field := range fields {
+			var value string
+			var index int
+			if field == ocr.FieldAmount {
+				var amount float64
+				if amount, index = used.MatchAmount(text); index >= 0 {
+					value = fmt.Sprintf("%.2f", amount)
+				}
+			} else {
+				value, index = used.MatchField(field, text)
+			}
+
+			if index < 0 {
+				fmt.Fprintf(w, "  %s\t-\t\n", field)
+				continue
+			}
+			filled[field]++
+			fmt.Fprintf(w, "  %s\t%s\tpattern #%d\n", field, oneLine(value), index)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Filled (%d samples)\n", len(samples))
+	for _, field := range fields {
+		fmt.Fprintf(w, "  %s\t%d/%d\t\n", field, filled[field], len(samples))
+	}
+	w.Flush()
+}
+
+func oneLine(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if len([]rune(value)) > 50 {
+		value = string([]rune(value)[:47]) + "..."
+	}
+	return value
+}
@@ -3,25 +3,57 @@ package config
 import (
 	"log"
 	"os"
+	"runtime"
+	"strconv"
 )
 
 type Config struct {
-	ServerPort      string
-	DatabasePath    string
-	UploadDir       string
-	TesseractLang   string
-	MaxUploadSize   int64 
+	ServerPort          string
+	DatabasePath        string
+	UploadDir           string
+	TesseractLang       string
+	MaxUploadSize       int64
+	LegacyOwnerID       uint    // owner assigned to rows created before per-user scoping
+	JobWorkers          int     // number of background workers for async uploads
+	ReviewThreshold     float64 // OCR transactions below this confidence are flagged needs_review
+	BankTemplatesDir    string  // directory of YAML bank slip templates
+	BankTemplatesReload int     // seconds between checks for changed templates, 0 disables
+	ImportMappingsDir   string  // directory of YAML CSV column mappings for statement imports
+	ImportMatchWindow   int     // days either side of an imported row searched for its slip
+	SearchDictionary    string  // optional Thai word list added to the built-in search dictionary
+	PayeeMatchThreshold float64 // similarity (0-1) above which a receiver/sender is matched to an existing payee
+	TransferMatchWindow int     // days apart an expense and income between own accounts can be paired as a transfer
+	KeepSlipImages      bool    // keep uploaded slips as attachments of their transactions
+	AttachmentRetention int     // days attachments are kept, 0 keeps them for good
+	OCRMultiPass        bool    // read slips several ways and keep the best reading
+	OCRTimeBudget       int     // milliseconds a slip may take to read with multi-pass OCR
+	OCRConcurrency      int     // Tesseract reads run at once across all uploads; more wait their turn
 }
 
 var AppConfig *Config
 
 func Init() {
 	AppConfig = &Config{
-		ServerPort:      getEnv("SERVER_PORT", "8077"),
-		DatabasePath:    getEnv("DATABASE_PATH", "./db.sqlite"),
-		UploadDir:       getEnv("UPLOAD_DIR", "./uploads"),
-		TesseractLang:   getEnv("TESSERACT_LANG", "tha+eng"), // Thai + English
-		MaxUploadSize:   10 * 1024 * 1024, // 10MB
+		ServerPort:          getEnv("SERVER_PORT", "8077"),
+		DatabasePath:        getEnv("DATABASE_PATH", "./db.sqlite"),
+		UploadDir:           getEnv("UPLOAD_DIR", "./uploads"),
+		TesseractLang:       getEnv("TESSERACT_LANG", "tha+eng"), // Thai + English
+		MaxUploadSize:       10 * 1024 * 1024,                    // 10MB
+		LegacyOwnerID:       uint(getEnvInt("LEGACY_OWNER_ID", 0)),
+		JobWorkers:          getEnvInt("JOB_WORKERS", 2),
+		ReviewThreshold:     getEnvFloat("REVIEW_CONFIDENCE_THRESHOLD", 0.6),
+		BankTemplatesDir:    getEnv("BANK_TEMPLATES_DIR", "./banks"),
+		BankTemplatesReload: getEnvInt("BANK_TEMPLATES_RELOAD", 5),
+		ImportMappingsDir:   getEnv("IMPORT_MAPPINGS_DIR", "./imports"),
+		ImportMatchWindow:   getEnvInt("IMPORT_MATCH_WINDOW_DAYS", 2),
+		SearchDictionary:    getEnv("SEARCH_DICTIONARY", ""),
+		PayeeMatchThreshold: getEnvFloat("PAYEE_MATCH_THRESHOLD", 0.8),
+		TransferMatchWindow: getEnvInt("TRANSFER_MATCH_WINDOW_DAYS", 1),
+		KeepSlipImages:      getEnvBool("KEEP_SLIP_IMAGES", true),
+		AttachmentRetention: getEnvInt("ATTACHMENT_RETENTION_DAYS", 0),
+		OCRMultiPass:        getEnvBool("OCR_MULTI_PASS", false),
+		OCRTimeBudget:       getEnvInt("OCR_TIME_BUDGET_MS", 10000),
+		OCRConcurrency:      getEnvInt("OCR_CONCURRENCY", runtime.NumCPU()),
 	}
 
 	if err := os.MkdirAll(AppConfig.UploadDir, os.ModePerm); err != nil {
@@ -37,3 +69,33 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: invalid value for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: invalid value for %s: %q, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: invalid value for %s: %q, using default %g", key, value, defaultValue)
+	}
+	return defaultValue
+}
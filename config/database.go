@@ -14,7 +14,7 @@ var DB *gorm.DB
 func InitDatabase() {
 	var err error
 	
-	DB, err = gorm.Open(sqlite.Open(AppConfig.DatabasePath), &gorm.Config{
+	DB, err = gorm.Open(sqlite.Dialector{DriverName: sqliteDriver, DSN: AppConfig.DatabasePath}, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	
@@ -27,11 +27,24 @@ func InitDatabase() {
 		&models.Transaction{},
 		&models.Budget{},
 		&models.Subscription{},
+		&models.UploadJob{},
+		&models.UploadJobFile{},
+		&models.DraftTransaction{},
+		&models.ExtractionCorrection{},
+		&models.CategoryRule{},
+		&models.Payee{},
+		&models.Category{},
+		&models.TransactionSplit{},
+		&models.Account{},
+		&models.Attachment{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	runDataMigrations()
+	initSearchIndex()
+
 	log.Println("Database initialized successfully")
 }
 
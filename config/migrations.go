@@ -0,0 +1,109 @@
+package config
+
+import (
+	"log"
+	"ocr-api/models"
+	"ocr-api/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ownedTables lists the tables whose rows belong to a single user.
+var ownedTables = []string{"transactions", "budgets", "subscriptions"}
+
+// runDataMigrations applies one-off data fixes that AutoMigrate cannot express.
+// Every step must be safe to run on each startup.
+func runDataMigrations() {
+	assignLegacyOwner()
+	backfillOccurredAt()
+	backfillSource()
+}
+
+// assignLegacyOwner hands rows created before per-user scoping (user_id = 0)
+// to the account configured by LEGACY_OWNER_ID.
+func assignLegacyOwner() {
+	ownerID := AppConfig.LegacyOwnerID
+	if ownerID == 0 {
+		for _, table := range ownedTables {
+			var orphaned int64
+			DB.Table(table).Where("user_id = ?", 0).Count(&orphaned)
+			if orphaned > 0 {
+				log.Printf("Warning: %d rows in %s have no owner; set LEGACY_OWNER_ID to assign them", orphaned, table)
+			}
+		}
+		return
+	}
+
+	var owner models.User
+	if err := DB.First(&owner, ownerID).Error; err != nil {
+		log.Printf("Warning: LEGACY_OWNER_ID %d does not match any user, skipping owner migration", ownerID)
+		return
+	}
+
+	for _, table := range ownedTables {
+		result := DB.Table(table).Where("user_id = ?", 0).Update("user_id", ownerID)
+		if result.Error != nil {
+			log.Fatalf("Failed to assign legacy owner for %s: %v", table, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Assigned %d rows in %s to user %s", result.RowsAffected, table, owner.Username)
+		}
+	}
+}
+
+// backfillOccurredAt fills transactions.occurred_at from the legacy DD/MM/YYYY
+// date and time strings. Rows whose date cannot be parsed fall back to
+// created_at and are reported so they can be fixed by hand.
+func backfillOccurredAt() {
+	var rows []struct {
+		ID        uint
+		Date      string
+		Time      string
+		CreatedAt time.Time
+	}
+	if err := DB.Table("transactions").
+		Select("id, date, time, created_at").
+		Where("occurred_at IS NULL").
+		Find(&rows).Error; err != nil {
+		log.Fatalf("Failed to load transactions for occurred_at backfill: %v", err)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	var unparsed []uint
+	for _, row := range rows {
+		occurredAt, err := utils.ParseTransactionTime(row.Date, row.Time)
+		if err != nil {
+			log.Printf("Backfill: transaction #%d has unparseable date %q time %q (%v), using created_at", row.ID, row.Date, row.Time, err)
+			unparsed = append(unparsed, row.ID)
+			occurredAt = row.CreatedAt
+		}
+
+		if err := DB.Table("transactions").Where("id = ?", row.ID).
+			Update("occurred_at", occurredAt.UTC()).Error; err != nil {
+			log.Fatalf("Failed to backfill occurred_at for transaction #%d: %v", row.ID, err)
+		}
+	}
+
+	log.Printf("Backfilled occurred_at for %d transactions (%d could not be parsed: %v)",
+		len(rows), len(unparsed), unparsed)
+}
+
+// backfillSource labels transactions created before sources were recorded.
+// Statement rows keep their single statement line as raw_ocr_text, slips keep
+// the full multi-line OCR text, and manual entries have none.
+func backfillSource() {
+	result := DB.Unscoped().Model(&models.Transaction{}).
+		Where("source IS NULL OR source = ''").
+		Update("source", gorm.Expr(
+			"CASE WHEN raw_ocr_text = '' OR raw_ocr_text IS NULL THEN ? WHEN instr(raw_ocr_text, char(10)) > 0 THEN ? ELSE ? END",
+			models.SourceManual, models.SourceSlip, models.SourceStatement))
+	if result.Error != nil {
+		log.Fatalf("Failed to backfill transaction source: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled source for %d transactions", result.RowsAffected)
+	}
+}
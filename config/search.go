@@ -0,0 +1,135 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"ocr-api/search"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// sqliteDriver is go-sqlite3 with the thai_segment() SQL function, which the
+// search index triggers use to split Thai text into words
+const sqliteDriver = "sqlite3_ocr"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("thai_segment", search.Segment, true)
+		},
+	})
+}
+
+// SearchEnabled is false when SQLite was built without FTS5 (build with
+// -tags sqlite_fts5); transaction search is then unavailable
+var SearchEnabled bool
+
+// The index holds segmented copies of the searchable columns, keyed by
+// transaction ID. Separator is declared so segmented Thai words are tokens.
+var searchSchema = `CREATE VIRTUAL TABLE IF NOT EXISTS transaction_search USING fts5(
+	raw_ocr_text, sender, receiver, detail, user_id UNINDEXED,
+	tokenize = "unicode61 remove_diacritics 2 separators '` + search.Separator + `'"
+)`
+
+const searchColumns = `thai_segment(COALESCE(%[1]s.raw_ocr_text, '')), thai_segment(COALESCE(%[1]s.sender, '')),
+	thai_segment(COALESCE(%[1]s.receiver, '')), thai_segment(COALESCE(%[1]s.detail, '')), %[1]s.user_id`
+
+// Triggers keep the index in step with every write to transactions,
+// including soft deletes (deleted_at being set)
+var searchTriggers = []string{
+	`CREATE TRIGGER transactions_search_insert AFTER INSERT ON transactions
+	WHEN new.deleted_at IS NULL BEGIN
+		INSERT INTO transaction_search(rowid, raw_ocr_text, sender, receiver, detail, user_id)
+		VALUES (new.id, ` + segmentedColumns("new") + `);
+	END`,
+	`CREATE TRIGGER transactions_search_update AFTER UPDATE OF raw_ocr_text, sender, receiver, detail, user_id, deleted_at ON transactions BEGIN
+		DELETE FROM transaction_search WHERE rowid = old.id;
+		INSERT INTO transaction_search(rowid, raw_ocr_text, sender, receiver, detail, user_id)
+		SELECT new.id, ` + segmentedColumns("new") + ` WHERE new.deleted_at IS NULL;
+	END`,
+	`CREATE TRIGGER transactions_search_delete AFTER DELETE ON transactions BEGIN
+		DELETE FROM transaction_search WHERE rowid = old.id;
+	END`,
+}
+
+var searchTriggerNames = []string{"transactions_search_insert", "transactions_search_update", "transactions_search_delete"}
+
+// initSearchIndex creates the full-text index and its triggers, rebuilding the
+// index when it was built with a different dictionary
+func initSearchIndex() {
+	if AppConfig.SearchDictionary != "" {
+		added, err := search.LoadDictionary(AppConfig.SearchDictionary)
+		if err != nil {
+			log.Fatalf("Failed to load search dictionary: %v", err)
+		}
+		log.Printf("Loaded %d words from %s", added, AppConfig.SearchDictionary)
+	}
+
+	for _, name := range searchTriggerNames {
+		if err := DB.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+			log.Fatalf("Failed to drop search trigger %s: %v", name, err)
+		}
+	}
+
+	if err := DB.Exec("CREATE TABLE IF NOT EXISTS search_index_state (name TEXT PRIMARY KEY, value TEXT NOT NULL)").Error; err != nil {
+		log.Fatalf("Failed to create search index state: %v", err)
+	}
+
+	// Without FTS5 the triggers stay dropped so writes keep working. An index
+	// left by an FTS5 build goes stale and is rebuilt once FTS5 is back.
+	var fts5 bool
+	DB.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	if !fts5 {
+		if err := DB.Exec("DELETE FROM search_index_state").Error; err != nil {
+			log.Fatalf("Failed to reset search index state: %v", err)
+		}
+		log.Printf("Warning: transaction search disabled, SQLite was built without FTS5 (build with -tags sqlite_fts5)")
+		return
+	}
+	if err := DB.Exec(searchSchema).Error; err != nil {
+		log.Fatalf("Failed to create search index: %v", err)
+	}
+	for _, trigger := range searchTriggers {
+		if err := DB.Exec(trigger).Error; err != nil {
+			log.Fatalf("Failed to create search trigger: %v", err)
+		}
+	}
+
+	var built string
+	DB.Raw("SELECT value FROM search_index_state WHERE name = 'dictionary'").Scan(&built)
+
+	fingerprint := search.DictionaryFingerprint()
+	if built != fingerprint {
+		rebuildSearchIndex(fingerprint)
+	}
+
+	SearchEnabled = true
+	log.Printf("Transaction search ready (%d dictionary words)", search.DictionarySize())
+}
+
+func rebuildSearchIndex(fingerprint string) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM transaction_search").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO transaction_search(rowid, raw_ocr_text, sender, receiver, detail, user_id)
+			SELECT transactions.id, ` + segmentedColumns("transactions") + ` FROM transactions WHERE deleted_at IS NULL`).Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT OR REPLACE INTO search_index_state (name, value) VALUES ('dictionary', ?)", fingerprint).Error
+	})
+	if err != nil {
+		log.Fatalf("Failed to build search index: %v", err)
+	}
+
+	var count int64
+	DB.Raw("SELECT COUNT(*) FROM transaction_search").Scan(&count)
+	log.Printf("Rebuilt search index for %d transactions", count)
+}
+
+// segmentedColumns lists the index values for a row of table (new, old or transactions)
+func segmentedColumns(table string) string {
+	return fmt.Sprintf(searchColumns, table)
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"net/http"
+	"ocr-api/models"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	service *services.AccountService
+}
+
+func NewAccountController() *AccountController {
+	return &AccountController{service: services.NewAccountService()}
+}
+
+type AccountRequest struct {
+	Name           string  `json:"name"`
+	Bank           string  `json:"bank"`
+	Number         string  `json:"number"` // full or masked as on slips, e.g. xxx-x-x1234-x
+	Currency       string  `json:"currency"`
+	OpeningBalance float64 `json:"opening_balance"`
+}
+
+func (r *AccountRequest) account(userID uint) *models.Account {
+	return &models.Account{
+		UserID:         userID,
+		Name:           r.Name,
+		Bank:           r.Bank,
+		Number:         r.Number,
+		Currency:       r.Currency,
+		OpeningBalance: r.OpeningBalance,
+	}
+}
+
+func (c *AccountController) Create(ctx *gin.Context) {
+	var req AccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	account := req.account(utils.GetUserID(ctx))
+	if err := c.service.Create(account); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Account created successfully", "account": account})
+}
+
+// GetAll lists the accounts with their current balances
+func (c *AccountController) GetAll(ctx *gin.Context) {
+	accounts, err := c.service.GetAll(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+func (c *AccountController) GetByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	account, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"account": account})
+}
+
+func (c *AccountController) Update(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	var req AccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	account, err := c.service.Update(userID, uint(id), req.account(userID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account updated successfully", "account": account})
+}
+
+func (c *AccountController) Delete(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	if err := c.service.Delete(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+// GetBalance returns the account's transactions with running balances.
+// Query: from and to (YYYY-MM-DD or DD/MM/YYYY), both optional.
+func (c *AccountController) GetBalance(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	from, to, err := utils.DateRange(ctx.Query("from"), ctx.Query("to"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	ledger, err := c.service.Ledger(userID, uint(id), from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"ledger": ledger})
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttachmentController struct {
+	service            *services.AttachmentService
+	transactionService *services.TransactionService
+}
+
+func NewAttachmentController() *AttachmentController {
+	return &AttachmentController{
+		service:            services.NewAttachmentService(),
+		transactionService: services.NewTransactionService(),
+	}
+}
+
+// transactionID reads the transaction ID from the path and checks the user owns it
+func (c *AttachmentController) transactionID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return 0, false
+	}
+	if _, err := c.transactionService.GetByID(utils.GetUserID(ctx), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// attachment reads the attachment ID from the path and loads it
+func (c *AttachmentController) attachment(ctx *gin.Context) (*models.Attachment, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return nil, false
+	}
+	attachment, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	return attachment, true
+}
+
+// Upload attaches a receipt, invoice or other file to a transaction
+func (c *AttachmentController) Upload(ctx *gin.Context) {
+	transactionID, ok := c.transactionID(ctx)
+	if !ok {
+		return
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded. Use 'file' as the form field name"})
+		return
+	}
+	if file.Size > config.AppConfig.MaxUploadSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File '%s' is too large (max 10MB)", file.Filename)})
+		return
+	}
+
+	attachment, err := c.service.Add(utils.GetUserID(ctx), transactionID, ctx.PostForm("kind"), file)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrAttachmentType) {
+			status = http.StatusUnsupportedMediaType
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "File attached successfully", "attachment": attachment})
+}
+
+func (c *AttachmentController) List(ctx *gin.Context) {
+	transactionID, ok := c.transactionID(ctx)
+	if !ok {
+		return
+	}
+
+	attachments, err := c.service.List(utils.GetUserID(ctx), transactionID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+// GetImage serves the slip image of a transaction
+func (c *AttachmentController) GetImage(ctx *gin.Context) {
+	attachment, ok := c.slipImage(ctx)
+	if !ok {
+		return
+	}
+	c.serve(ctx, attachment, c.service.Path(attachment))
+}
+
+// GetImageThumbnail serves a thumbnail of the slip image of a transaction
+func (c *AttachmentController) GetImageThumbnail(ctx *gin.Context) {
+	attachment, ok := c.slipImage(ctx)
+	if !ok {
+		return
+	}
+	c.serveThumbnail(ctx, attachment)
+}
+
+func (c *AttachmentController) slipImage(ctx *gin.Context) (*models.Attachment, bool) {
+	transactionID, ok := c.transactionID(ctx)
+	if !ok {
+		return nil, false
+	}
+	attachment, err := c.service.SlipImage(utils.GetUserID(ctx), transactionID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, false
+	}
+	return attachment, true
+}
+
+// Get serves the file of an attachment
+func (c *AttachmentController) Get(ctx *gin.Context) {
+	attachment, ok := c.attachment(ctx)
+	if !ok {
+		return
+	}
+	c.serve(ctx, attachment, c.service.Path(attachment))
+}
+
+func (c *AttachmentController) GetThumbnail(ctx *gin.Context) {
+	attachment, ok := c.attachment(ctx)
+	if !ok {
+		return
+	}
+	c.serveThumbnail(ctx, attachment)
+}
+
+func (c *AttachmentController) Delete(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	if err := c.service.Delete(utils.GetUserID(ctx), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// serveThumbnail serves an image attachment scaled to fit ?size= pixels
+func (c *AttachmentController) serveThumbnail(ctx *gin.Context, attachment *models.Attachment) {
+	size := services.DefaultThumbnailSize
+	if s := ctx.Query("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || !containsInt(services.ThumbnailSizes, n) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid size. Must be one of %v", services.ThumbnailSizes)})
+			return
+		}
+		size = n
+	}
+
+	path, err := c.service.Thumbnail(attachment, size)
+	if errors.Is(err, services.ErrNoThumbnail) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no thumbnail"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	thumbnail := *attachment
+	thumbnail.Hash = fmt.Sprintf("%s_%d", attachment.Hash, size)
+	thumbnail.ContentType = "image/jpeg"
+	c.serve(ctx, &thumbnail, path)
+}
+
+// serve sends an attachment's file. Content is stored by hash and never
+// changes, so clients may cache it for good.
+func (c *AttachmentController) serve(ctx *gin.Context, attachment *models.Attachment, path string) {
+	ctx.Header("Content-Type", attachment.ContentType)
+	ctx.Header("Cache-Control", "private, max-age=31536000, immutable")
+	ctx.Header("ETag", strconv.Quote(attachment.Hash))
+	if attachment.Filename != "" {
+		ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Filename}))
+	}
+	ctx.File(path)
+}
+
+func containsInt(slice []int, item int) bool {
+	for _, v := range slice {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"ocr-api/models"
 	"ocr-api/services"
+	"ocr-api/utils"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,7 @@ func (c *BudgetController) Create(ctx *gin.Context) {
 	}
 
 	budget := &models.Budget{
+		UserID:       utils.GetUserID(ctx),
 		Category:     req.Category,
 		MonthlyLimit: req.MonthlyLimit,
 		Month:        req.Month,
@@ -49,7 +51,7 @@ func (c *BudgetController) Create(ctx *gin.Context) {
 }
 
 func (c *BudgetController) GetAll(ctx *gin.Context) {
-	budgets, err := c.service.GetAll()
+	budgets, err := c.service.GetAll(utils.GetUserID(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -67,7 +69,7 @@ func (c *BudgetController) GetBudgetStatus(ctx *gin.Context) {
 		return
 	}
 
-	statuses, err := c.service.GetBudgetStatus(month, year)
+	statuses, err := c.service.GetBudgetStatus(utils.GetUserID(ctx), month, year)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -78,7 +80,7 @@ func (c *BudgetController) GetBudgetStatus(ctx *gin.Context) {
 
 func (c *BudgetController) Delete(ctx *gin.Context) {
 	id, _ := strconv.ParseUint(ctx.Param("id"), 10, 32)
-	if err := c.service.Delete(uint(id)); err != nil {
+	if err := c.service.Delete(utils.GetUserID(ctx), uint(id)); err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
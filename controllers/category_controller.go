@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"ocr-api/models"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryController struct {
+	service *services.CategoryService
+}
+
+func NewCategoryController() *CategoryController {
+	return &CategoryController{service: services.NewCategoryService()}
+}
+
+type CategoryRequest struct {
+	ParentID *uint  `json:"parent_id"`
+	Name     string `json:"name" binding:"required"` // Thai label
+	NameEN   string `json:"name_en"`
+	Kind     string `json:"kind"` // income or expense, default expense
+	Icon     string `json:"icon"`
+	Color    string `json:"color"` // #RRGGBB
+}
+
+func (r *CategoryRequest) category(userID uint) *models.Category {
+	return &models.Category{
+		UserID:   userID,
+		ParentID: r.ParentID,
+		Name:     r.Name,
+		NameEN:   r.NameEN,
+		Kind:     r.Kind,
+		Icon:     r.Icon,
+		Color:    r.Color,
+	}
+}
+
+func (c *CategoryController) Create(ctx *gin.Context) {
+	var req CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	category := req.category(utils.GetUserID(ctx))
+	if err := c.service.Create(category); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Category created successfully", "category": category})
+}
+
+// GetAll lists the user's categories; tree=true nests subcategories under
+// their parents
+func (c *CategoryController) GetAll(ctx *gin.Context) {
+	tree := false
+	if raw := ctx.Query("tree"); raw != "" {
+		var err error
+		if tree, err = strconv.ParseBool(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid tree %q. Must be true or false", raw)})
+			return
+		}
+	}
+
+	userID := utils.GetUserID(ctx)
+	if tree {
+		categories, err := c.service.GetTree(userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"categories": categories})
+		return
+	}
+
+	categories, err := c.service.GetAll(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+func (c *CategoryController) GetByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	category, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+func (c *CategoryController) Update(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category, err := c.service.Update(userID, uint(id), req.category(userID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category updated successfully", "category": category})
+}
+
+func (c *CategoryController) Delete(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if err := c.service.Delete(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+	"ocr-api/services"
+	"ocr-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CorrectionController struct {
+	service *services.CorrectionService
+}
+
+func NewCorrectionController() *CorrectionController {
+	return &CorrectionController{service: services.NewCorrectionService()}
+}
+
+func (c *CorrectionController) GetAll(ctx *gin.Context) {
+	corrections, err := c.service.GetAll(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"corrections": corrections})
+}
+
+func (c *CorrectionController) GetAccuracy(ctx *gin.Context) {
+	data, err := c.service.GetAccuracy(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"accuracy": data})
+}
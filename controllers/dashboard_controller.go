@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"ocr-api/services"
+	"ocr-api/utils"
 	"strconv"
 	"strings"
 
@@ -24,7 +25,7 @@ func (c *DashboardController) GetMonthlyTrend(ctx *gin.Context) {
 		return
 	}
 
-	data, err := c.service.GetMonthlyTrend(year)
+	data, err := c.service.GetMonthlyTrend(utils.GetUserID(ctx), year)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -47,7 +48,7 @@ func (c *DashboardController) GetYearlyComparison(ctx *gin.Context) {
 		}
 	}
 
-	data, err := c.service.GetYearlyComparison(years)
+	data, err := c.service.GetYearlyComparison(utils.GetUserID(ctx), years)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -66,7 +67,7 @@ func (c *DashboardController) GetCategoryBreakdown(ctx *gin.Context) {
 		return
 	}
 
-	data, err := c.service.GetCategoryBreakdown(year, month, transactionType)
+	data, err := c.service.GetCategoryBreakdown(utils.GetUserID(ctx), year, month, transactionType)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -74,3 +75,35 @@ func (c *DashboardController) GetCategoryBreakdown(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{"category_breakdown": data})
 }
+
+// GetTopPayees ranks payees by total amount.
+// Query: year (required), month (optional, whole year when omitted),
+// type (default expense) and limit (default 10, max 100).
+func (c *DashboardController) GetTopPayees(ctx *gin.Context) {
+	year, _ := strconv.Atoi(ctx.Query("year"))
+	month, _ := strconv.Atoi(ctx.Query("month"))
+	if year == 0 || month < 0 || month > 12 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "year required, month must be 1-12"})
+		return
+	}
+
+	transactionType := ctx.DefaultQuery("type", "expense")
+	if !utils.ValidateTransactionType(transactionType) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Must be 'income' or 'expense'"})
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit. Must be between 1 and 100"})
+		return
+	}
+
+	data, err := c.service.GetTopPayees(utils.GetUserID(ctx), year, month, transactionType, limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"top_payees": data})
+}
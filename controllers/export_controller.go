@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController struct {
+	service *services.ExportService
+}
+
+func NewExportController() *ExportController {
+	return &ExportController{service: services.NewExportService()}
+}
+
+// Export streams the user's transactions as a file download.
+// Query: format (csv, xlsx, ofx, jsonl; default csv) and the same filters as
+// the transaction list.
+func (c *ExportController) Export(ctx *gin.Context) {
+	format := strings.ToLower(ctx.DefaultQuery("format", services.ExportCSV))
+	contentType, extension, err := c.service.ContentType(format)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := bindTransactionFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("transactions-%s.%s", time.Now().In(utils.BangkokLocation).Format("20060102"), extension)
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure part way can only be logged
+	if err := c.service.Export(ctx.Writer, utils.GetUserID(ctx), format, filter); err != nil {
+		log.Printf("Export (%s) failed for user %d: %v", format, utils.GetUserID(ctx), err)
+	}
+}
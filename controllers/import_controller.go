@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"ocr-api/config"
+	"ocr-api/importer"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	service *services.ImportService
+}
+
+func NewImportController() *ImportController {
+	return &ImportController{service: services.NewImportService()}
+}
+
+// Import reconciles a CSV, OFX or QIF statement export against recorded
+// transactions. Form fields: file, and optionally format (csv, ofx, qif; taken
+// from the file extension by default), bank (CSV mapping name) and dry_run.
+func (c *ImportController) Import(ctx *gin.Context) {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "No file uploaded. Use 'file' as the form field name",
+		})
+		return
+	}
+
+	if file.Size > config.AppConfig.MaxUploadSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("File '%s' is too large (max 10MB)", file.Filename),
+		})
+		return
+	}
+
+	format := strings.ToLower(ctx.PostForm("format"))
+	switch format {
+	case "":
+		if format, err = importer.FormatFromFilename(file.Filename); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case importer.FormatCSV, importer.FormatOFX, importer.FormatQIF:
+	case "qfx":
+		format = importer.FormatOFX
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be 'csv', 'ofx' or 'qif'"})
+		return
+	}
+
+	dryRun := ctx.PostForm("dry_run") == "true" || ctx.Query("dry_run") == "true"
+
+	opened, err := file.Open()
+	if err != nil {
+		log.Printf("Failed to open uploaded import '%s': %v", file.Filename, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read file '%s'", file.Filename),
+		})
+		return
+	}
+	defer opened.Close()
+
+	content, err := io.ReadAll(opened)
+	if err != nil {
+		log.Printf("Failed to read uploaded import '%s': %v", file.Filename, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read file '%s'", file.Filename),
+		})
+		return
+	}
+
+	result, err := c.service.Import(utils.GetUserID(ctx), format, content, ctx.PostForm("bank"), dryRun)
+	if err != nil {
+		log.Printf("Import failed for '%s': %v", file.Filename, err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to import '%s': %s", file.Filename, err.Error()),
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun || result.Summary.New == 0 {
+		status = http.StatusOK
+	}
+	ctx.JSON(status, gin.H{
+		"message": fmt.Sprintf("%d rows: %d matched, %d new, %d conflicting",
+			result.Summary.Rows, result.Summary.Matched, result.Summary.New, result.Summary.Conflicting),
+		"import": result,
+	})
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobController struct {
+	service *services.JobService
+}
+
+func NewJobController() *JobController {
+	return &JobController{service: services.NewJobService()}
+}
+
+func (c *JobController) GetByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"job": job})
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"net/http"
+	"ocr-api/models"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayeeController struct {
+	service *services.PayeeService
+}
+
+func NewPayeeController() *PayeeController {
+	return &PayeeController{service: services.NewPayeeService()}
+}
+
+type PayeeRequest struct {
+	Name            string   `json:"name" binding:"required"`
+	Aliases         []string `json:"aliases"`
+	DefaultCategory string   `json:"default_category"`
+}
+
+type MergePayeesRequest struct {
+	PayeeIDs []uint `json:"payee_ids" binding:"required,min=1"` // payees folded into the one in the URL
+}
+
+func (c *PayeeController) Create(ctx *gin.Context) {
+	var req PayeeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	payee := &models.Payee{
+		UserID:          utils.GetUserID(ctx),
+		Name:            req.Name,
+		Aliases:         req.Aliases,
+		DefaultCategory: req.DefaultCategory,
+	}
+	if err := c.service.Create(payee); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Payee created successfully", "payee": payee})
+}
+
+func (c *PayeeController) GetAll(ctx *gin.Context) {
+	payees, err := c.service.GetAll(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"payees": payees})
+}
+
+func (c *PayeeController) GetByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	payee, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payee not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"payee": payee})
+}
+
+func (c *PayeeController) Update(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	var req PayeeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payee not found"})
+		return
+	}
+
+	payee, err := c.service.Update(userID, uint(id), req.Name, req.Aliases, req.DefaultCategory)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Payee updated successfully", "payee": payee})
+}
+
+func (c *PayeeController) Delete(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	if err := c.service.Delete(utils.GetUserID(ctx), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Payee deleted successfully"})
+}
+
+// Merge folds the payees in the body into the payee in the URL
+func (c *PayeeController) Merge(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	var req MergePayeesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "payee_ids required"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payee not found"})
+		return
+	}
+
+	payee, err := c.service.Merge(userID, uint(id), req.PayeeIDs)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Payees merged successfully", "payee": payee})
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReviewController struct {
+	service *services.ReviewService
+}
+
+func NewReviewController() *ReviewController {
+	return &ReviewController{service: services.NewReviewService()}
+}
+
+func (c *ReviewController) GetQueue(ctx *gin.Context) {
+	queue, err := c.service.GetQueue(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"drafts":        queue.Drafts,
+		"flagged":       queue.Flagged,
+		"draft_count":   len(queue.Drafts),
+		"flagged_count": len(queue.Flagged),
+	})
+}
+
+func (c *ReviewController) GetDraft(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+
+	draft, err := c.service.GetDraft(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Draft not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"draft": draft})
+}
+
+// GetImage serves the preprocessed slip image kept for a pending draft
+func (c *ReviewController) GetImage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+
+	draft, err := c.service.GetDraft(utils.GetUserID(ctx), uint(id))
+	if err != nil || draft.ImagePath == "" {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	ctx.File(draft.ImagePath)
+}
+
+type CorrectDraftRequest struct {
+	Type      *string  `json:"type"`
+	Amount    *float64 `json:"amount"`
+	Date      *string  `json:"date"`
+	Time      *string  `json:"time"`
+	Reference *string  `json:"reference"`
+	Bank      *string  `json:"bank"`
+	Sender    *string  `json:"sender"`
+	Receiver  *string  `json:"receiver"`
+	Category  *string  `json:"category"`
+	Detail    *string  `json:"detail"`
+
+	SenderAccount   *string `json:"sender_account"`
+	ReceiverAccount *string `json:"receiver_account"`
+}
+
+func (c *ReviewController) Correct(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+
+	var req CorrectDraftRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Type != nil {
+		if !utils.ValidateTransactionType(*req.Type) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction type. Must be 'income' or 'expense'"})
+			return
+		}
+		updates["type"] = *req.Type
+	}
+	if req.Amount != nil {
+		updates["amount"] = *req.Amount
+	}
+	if req.Date != nil {
+		updates["date"] = *req.Date
+	}
+	if req.Time != nil {
+		updates["time"] = *req.Time
+	}
+	if req.Reference != nil {
+		updates["reference"] = *req.Reference
+	}
+	if req.Bank != nil {
+		updates["bank"] = *req.Bank
+	}
+	if req.Sender != nil {
+		updates["sender"] = *req.Sender
+	}
+	if req.Receiver != nil {
+		updates["receiver"] = *req.Receiver
+	}
+	if req.SenderAccount != nil {
+		updates["sender_account"] = *req.SenderAccount
+	}
+	if req.ReceiverAccount != nil {
+		updates["receiver_account"] = *req.ReceiverAccount
+	}
+	if req.Category != nil {
+		updates["category"] = *req.Category
+	}
+	if req.Detail != nil {
+		updates["detail"] = *req.Detail
+	}
+
+	if len(updates) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+
+	draft, err := c.service.Correct(utils.GetUserID(ctx), uint(id), updates)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Draft updated successfully", "draft": draft})
+}
+
+func (c *ReviewController) Approve(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+
+	transaction, err := c.service.Approve(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Draft approved", "transaction": transaction})
+}
+
+func (c *ReviewController) Reject(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+
+	if err := c.service.Reject(utils.GetUserID(ctx), uint(id)); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Draft rejected"})
+}
+
+func (c *ReviewController) respondError(ctx *gin.Context, err error) {
+	var duplicateErr *services.DuplicateSlipError
+	switch {
+	case errors.Is(err, services.ErrDraftResolved), errors.As(err, &duplicateErr):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDraftIncomplete), errors.Is(err, services.ErrInvalidAccount):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Draft not found"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
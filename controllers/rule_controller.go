@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"ocr-api/models"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuleController struct {
+	service *services.RuleService
+}
+
+func NewRuleController() *RuleController {
+	return &RuleController{service: services.NewRuleService()}
+}
+
+type RuleRequest struct {
+	Name            string   `json:"name"`
+	Priority        int      `json:"priority"`
+	Enabled         *bool    `json:"enabled"` // default true
+	Type            string   `json:"type"`
+	ReceiverPattern string   `json:"receiver_pattern"`
+	SenderPattern   string   `json:"sender_pattern"`
+	Bank            string   `json:"bank"`
+	PayeeID         *uint    `json:"payee_id"`
+	MinAmount       *float64 `json:"min_amount"`
+	MaxAmount       *float64 `json:"max_amount"`
+	TimeFrom        string   `json:"time_from"`
+	TimeTo          string   `json:"time_to"`
+	Weekdays        []string `json:"weekdays"`
+	Category        string   `json:"category" binding:"required"`
+	Detail          string   `json:"detail"`
+}
+
+func (r *RuleRequest) rule(userID uint) *models.CategoryRule {
+	enabled := r.Enabled == nil || *r.Enabled
+	return &models.CategoryRule{
+		UserID:          userID,
+		Name:            r.Name,
+		Priority:        r.Priority,
+		Enabled:         enabled,
+		Type:            r.Type,
+		ReceiverPattern: r.ReceiverPattern,
+		SenderPattern:   r.SenderPattern,
+		Bank:            r.Bank,
+		PayeeID:         r.PayeeID,
+		MinAmount:       r.MinAmount,
+		MaxAmount:       r.MaxAmount,
+		TimeFrom:        r.TimeFrom,
+		TimeTo:          r.TimeTo,
+		Weekdays:        r.Weekdays,
+		Category:        r.Category,
+		Detail:          r.Detail,
+	}
+}
+
+func (c *RuleController) Create(ctx *gin.Context) {
+	var req RuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	rule := req.rule(utils.GetUserID(ctx))
+	if err := c.service.Create(rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Rule created successfully", "rule": rule})
+}
+
+func (c *RuleController) GetAll(ctx *gin.Context) {
+	rules, err := c.service.GetAll(utils.GetUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (c *RuleController) GetByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (c *RuleController) Update(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req RuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	rule, err := c.service.Update(userID, uint(id), req.rule(userID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Rule updated successfully", "rule": rule})
+}
+
+func (c *RuleController) Delete(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := c.service.Delete(utils.GetUserID(ctx), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Rule deleted successfully"})
+}
+
+// DryRun shows which existing transactions the rule in the body would change,
+// without saving anything. Query: overwrite and the transaction list filters.
+func (c *RuleController) DryRun(ctx *gin.Context) {
+	var req RuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	filter, overwrite, err := bindRuleScope(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	changes, err := c.service.DryRun(userID, req.rule(userID), filter, overwrite)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"count": len(changes), "changes": changes})
+}
+
+// Apply re-runs the enabled rules over existing transactions.
+// Query: overwrite, dry_run and the transaction list filters.
+func (c *RuleController) Apply(ctx *gin.Context) {
+	filter, overwrite, err := bindRuleScope(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun := false
+	if raw := ctx.Query("dry_run"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid dry_run %q. Must be true or false", raw)})
+			return
+		}
+	}
+
+	changes, err := c.service.Apply(utils.GetUserID(ctx), filter, overwrite, dryRun)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("%d transactions recategorized", len(changes))
+	if dryRun {
+		message = fmt.Sprintf("%d transactions would be recategorized", len(changes))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": message, "dry_run": dryRun, "count": len(changes), "changes": changes})
+}
+
+// bindRuleScope reads which transactions rules are run over: the transaction
+// list filters, and overwrite to include transactions that have a category
+func bindRuleScope(ctx *gin.Context) (services.TransactionFilter, bool, error) {
+	filter, err := bindTransactionFilter(ctx)
+	if err != nil {
+		return filter, false, err
+	}
+
+	overwrite := false
+	if raw := ctx.Query("overwrite"); raw != "" {
+		if overwrite, err = strconv.ParseBool(raw); err != nil {
+			return filter, false, fmt.Errorf("invalid overwrite %q. Must be true or false", raw)
+		}
+	}
+	return filter, overwrite, nil
+}
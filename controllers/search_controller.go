@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	service *services.SearchService
+}
+
+func NewSearchController() *SearchController {
+	return &SearchController{service: services.NewSearchService()}
+}
+
+// Search runs a full-text search over the user's transactions.
+// Query: q (required), limit (default 20, max 100)
+func (c *SearchController) Search(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := 20
+	if value := ctx.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit. Must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := c.service.Search(utils.GetUserID(ctx), query, limit)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrSearchUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"query":   query,
+		"count":   len(results),
+		"results": results,
+		"message": fmt.Sprintf("%d transactions found", len(results)),
+	})
+}
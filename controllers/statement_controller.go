@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"ocr-api/config"
+	"ocr-api/services"
+	"ocr-api/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatementController struct {
+	service *services.StatementService
+}
+
+func NewStatementController() *StatementController {
+	return &StatementController{service: services.NewStatementService()}
+}
+
+func (c *StatementController) Upload(ctx *gin.Context) {
+	file, err := ctx.FormFile("statement")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "No file uploaded. Use 'statement' as the form field name",
+		})
+		return
+	}
+
+	if file.Size > config.AppConfig.MaxUploadSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("File '%s' is too large (max 10MB)", file.Filename),
+		})
+		return
+	}
+
+	filename := strings.ToLower(file.Filename)
+	if err := c.service.ValidateStatement(filename); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploadPath := filepath.Join(config.AppConfig.UploadDir, utils.GenerateUniqueFilename(filename))
+	if err := ctx.SaveUploadedFile(file, uploadPath); err != nil {
+		log.Printf("Failed to save uploaded statement '%s': %v", file.Filename, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save file '%s'", file.Filename),
+		})
+		return
+	}
+	defer func() {
+		if err := os.Remove(uploadPath); err != nil {
+			log.Printf("Warning: failed to cleanup uploaded file: %v", err)
+		}
+	}()
+
+	result, err := c.service.Import(ctx.Request.Context(), utils.GetUserID(ctx), uploadPath)
+	if err != nil {
+		log.Printf("Statement import failed for '%s': %v", file.Filename, err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to import '%s': %s", file.Filename, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":         fmt.Sprintf("Imported %d transactions, skipped %d already recorded", len(result.Transactions), len(result.Duplicates)),
+		"statement":       result,
+		"success_count":   len(result.Transactions),
+		"duplicate_count": len(result.Duplicates),
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"ocr-api/models"
 	"ocr-api/services"
+	"ocr-api/utils"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -33,6 +34,7 @@ func (c *SubscriptionController) Create(ctx *gin.Context) {
 	}
 
 	sub := &models.Subscription{
+		UserID:          utils.GetUserID(ctx),
 		Name:            req.Name,
 		Amount:          req.Amount,
 		Category:        req.Category,
@@ -50,7 +52,7 @@ func (c *SubscriptionController) Create(ctx *gin.Context) {
 }
 
 func (c *SubscriptionController) GetAll(ctx *gin.Context) {
-	subs, err := c.service.GetAll()
+	subs, err := c.service.GetAll(utils.GetUserID(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -61,7 +63,7 @@ func (c *SubscriptionController) GetAll(ctx *gin.Context) {
 
 func (c *SubscriptionController) Delete(ctx *gin.Context) {
 	id, _ := strconv.ParseUint(ctx.Param("id"), 10, 32)
-	if err := c.service.Delete(uint(id)); err != nil {
+	if err := c.service.Delete(utils.GetUserID(ctx), uint(id)); err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
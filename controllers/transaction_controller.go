@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"ocr-api/models"
 	"ocr-api/services"
 	"ocr-api/utils"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,31 +24,141 @@ func NewTransactionController() *TransactionController {
 	}
 }
 
+// Page sizes for the transaction list
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// GetAll lists transactions a page at a time. Query: the filters read by
+// bindTransactionFilter, sort (amount, date, created_at), order (asc, desc),
+// limit and cursor (next_cursor of the previous page).
 func (c *TransactionController) GetAll(ctx *gin.Context) {
-	transactionType := ctx.Query("type")
-	bank := ctx.Query("bank")
+	filter, err := bindTransactionFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var transactions interface{}
-	var err error
+	sort, err := services.ParseTransactionSort(ctx.Query("sort"), ctx.Query("order"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if transactionType != "" {
-		transactions, err = c.service.GetByType(transactionType)
-	} else if bank != "" {
-		transactions, err = c.service.GetByBank(bank)
-	} else {
-		transactions, err = c.service.GetAll()
+	limit := defaultPageSize
+	if value := ctx.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid limit. Must be between 1 and %d", maxPageSize),
+			})
+			return
+		}
 	}
 
+	page, err := c.service.List(utils.GetUserID(ctx), filter, sort, ctx.Query("cursor"), limit)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"transactions": transactions,
-	})
+	ctx.JSON(http.StatusOK, page)
+}
+
+// bindTransactionFilter reads the list and export filters from the query:
+// from, to, type, category, bank, payee and account (repeatable or
+// comma-separated), channel, min_amount, max_amount, sender, receiver and
+// has_reference.
+func bindTransactionFilter(ctx *gin.Context) (services.TransactionFilter, error) {
+	var filter services.TransactionFilter
+	var err error
+
+	filter.From, filter.To, err = utils.DateRange(ctx.Query("from"), ctx.Query("to"))
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Type = ctx.Query("type")
+	if filter.Type != "" && filter.Type != "transfer" && !utils.ValidateTransactionType(filter.Type) {
+		return filter, fmt.Errorf("invalid type. Must be 'income', 'expense' or 'transfer'")
+	}
+	filter.Channel = ctx.Query("channel")
+	if filter.Channel != "" && !utils.ValidateChannel(filter.Channel) {
+		return filter, fmt.Errorf("invalid channel. Must be 'bank', 'wallet' or 'counter'")
+	}
+
+	filter.Categories = queryList(ctx, "category")
+	filter.Banks = queryList(ctx, "bank")
+	for _, value := range queryList(ctx, "payee") {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid payee %q", value)
+		}
+		filter.Payees = append(filter.Payees, uint(id))
+	}
+	for _, value := range queryList(ctx, "account") {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid account %q", value)
+		}
+		filter.Accounts = append(filter.Accounts, uint(id))
+	}
+	filter.Sender = strings.TrimSpace(ctx.Query("sender"))
+	filter.Receiver = strings.TrimSpace(ctx.Query("receiver"))
+
+	if filter.MinAmount, err = queryAmount(ctx, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = queryAmount(ctx, "max_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, fmt.Errorf("min_amount must not be greater than max_amount")
+	}
+
+	if raw := ctx.Query("has_reference"); raw != "" {
+		hasReference, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid has_reference %q. Must be true or false", raw)
+		}
+		filter.HasReference = &hasReference
+	}
+
+	return filter, nil
+}
+
+// queryAmount reads an optional numeric parameter, nil when absent
+func queryAmount(ctx *gin.Context, key string) (*float64, error) {
+	raw := ctx.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return &amount, nil
+}
+
+// queryList reads a parameter given repeatedly (?bank=SCB&bank=KBank) or
+// comma-separated (?bank=SCB,KBank)
+func queryList(ctx *gin.Context, key string) []string {
+	var values []string
+	for _, value := range ctx.QueryArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
 }
 
 func (c *TransactionController) GetByID(ctx *gin.Context) {
@@ -57,7 +171,7 @@ func (c *TransactionController) GetByID(ctx *gin.Context) {
 		return
 	}
 
-	transaction, err := c.service.GetByID(uint(id))
+	transaction, err := c.service.GetByID(utils.GetUserID(ctx), uint(id))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": "Transaction not found",
@@ -80,7 +194,7 @@ func (c *TransactionController) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err = c.service.Delete(uint(id))
+	err = c.service.Delete(utils.GetUserID(ctx), uint(id))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
@@ -100,10 +214,38 @@ type CreateTransactionRequest struct {
 	Time      string  `json:"time"`
 	Reference string  `json:"reference"`
 	Bank      string  `json:"bank"`
+	Channel   string  `json:"channel"`
 	Sender    string  `json:"sender"`
 	Receiver  string  `json:"receiver"`
 	Category  string  `json:"category"`
 	Detail    string  `json:"detail"`
+
+	Splits []SplitRequest `json:"splits"` // optional lines that add up to amount
+
+	// Own accounts; found from the account numbers or bank when not given.
+	// A transfer needs both.
+	AccountID         *uint  `json:"account_id"`
+	TransferAccountID *uint  `json:"transfer_account_id"`
+	SenderAccount     string `json:"sender_account"`
+	ReceiverAccount   string `json:"receiver_account"`
+}
+
+type SplitRequest struct {
+	Amount   float64 `json:"amount"`
+	Category string  `json:"category"`
+	Note     string  `json:"note"`
+}
+
+type SetSplitsRequest struct {
+	Splits []SplitRequest `json:"splits" binding:"required"` // empty removes the splits
+}
+
+func splitLines(requests []SplitRequest) []models.TransactionSplit {
+	splits := make([]models.TransactionSplit, 0, len(requests))
+	for _, r := range requests {
+		splits = append(splits, models.TransactionSplit{Amount: r.Amount, Category: r.Category, Note: r.Note})
+	}
+	return splits
 }
 
 func (c *TransactionController) Create(ctx *gin.Context) {
@@ -115,27 +257,60 @@ func (c *TransactionController) Create(ctx *gin.Context) {
 		return
 	}
 
-	if !utils.ValidateTransactionType(req.Type) {
+	if req.Type != "transfer" && !utils.ValidateTransactionType(req.Type) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid transaction type. Must be 'income', 'expense' or 'transfer'",
+		})
+		return
+	}
+
+	if req.Channel != "" && !utils.ValidateChannel(req.Channel) {
 		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid transaction type. Must be 'income' or 'expense'",
+			"error": "Invalid channel. Must be 'bank', 'wallet' or 'counter'",
 		})
 		return
 	}
 
+	// A manual entry without a date is for now, as printed in Thailand
+	if strings.TrimSpace(req.Date) == "" {
+		now := time.Now().In(utils.BangkokLocation)
+		req.Date = now.Format("02/01/2006")
+		if req.Time == "" {
+			req.Time = now.Format("15:04")
+		}
+	}
+
 	transaction := &models.Transaction{
+		UserID:    utils.GetUserID(ctx),
 		Type:      req.Type,
 		Amount:    req.Amount,
 		Date:      req.Date,
 		Time:      req.Time,
 		Reference: req.Reference,
 		Bank:      req.Bank,
+		Channel:   req.Channel,
+		Source:    models.SourceManual,
 		Sender:    req.Sender,
 		Receiver:  req.Receiver,
 		Category:  req.Category,
 		Detail:    req.Detail,
+		Splits:    splitLines(req.Splits),
+
+		AccountID:         req.AccountID,
+		TransferAccountID: req.TransferAccountID,
+		SenderAccount:     req.SenderAccount,
+		ReceiverAccount:   req.ReceiverAccount,
 	}
+	services.NewPayeeService().Resolve(transaction)
+	services.NewRuleService().Categorize(transaction)
 
 	if err := c.service.Create(transaction); err != nil {
+		if errors.Is(err, services.ErrInvalidSplits) || errors.Is(err, services.ErrInvalidAccount) || errors.Is(err, services.ErrInvalidDate) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create transaction",
 		})
@@ -154,10 +329,14 @@ type UpdateTransactionRequest struct {
 	Time      *string  `json:"time"`
 	Reference *string  `json:"reference"`
 	Bank      *string  `json:"bank"`
+	Channel   *string  `json:"channel"`
 	Sender    *string  `json:"sender"`
 	Receiver  *string  `json:"receiver"`
 	Category  *string  `json:"category"`
 	Detail    *string  `json:"detail"`
+	PayeeID   *uint    `json:"payee_id"` // 0 unlinks the payee
+
+	NeedsReview *bool `json:"needs_review"` // set false once a flagged transaction has been checked
 }
 
 func (c *TransactionController) Update(ctx *gin.Context) {
@@ -194,6 +373,15 @@ func (c *TransactionController) Update(ctx *gin.Context) {
 	if req.Bank != nil {
 		updates["bank"] = *req.Bank
 	}
+	if req.Channel != nil {
+		if *req.Channel != "" && !utils.ValidateChannel(*req.Channel) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid channel. Must be 'bank', 'wallet' or 'counter'",
+			})
+			return
+		}
+		updates["channel"] = *req.Channel
+	}
 	if req.Sender != nil {
 		updates["sender"] = *req.Sender
 	}
@@ -206,6 +394,12 @@ func (c *TransactionController) Update(ctx *gin.Context) {
 	if req.Detail != nil {
 		updates["detail"] = *req.Detail
 	}
+	if req.PayeeID != nil {
+		updates["payee_id"] = *req.PayeeID
+	}
+	if req.NeedsReview != nil {
+		updates["needs_review"] = *req.NeedsReview
+	}
 
 	if len(updates) == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{
@@ -214,7 +408,13 @@ func (c *TransactionController) Update(ctx *gin.Context) {
 		return
 	}
 
-	transaction, err := c.service.Update(uint(id), updates)
+	transaction, err := c.service.Update(utils.GetUserID(ctx), uint(id), updates)
+	if errors.Is(err, services.ErrInvalidSplits) || errors.Is(err, services.ErrInvalidDate) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
@@ -228,6 +428,87 @@ func (c *TransactionController) Update(ctx *gin.Context) {
 	})
 }
 
+// SetSplits replaces the category split of a transaction
+func (c *TransactionController) SetSplits(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid transaction ID",
+		})
+		return
+	}
+
+	var req SetSplitsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Transaction not found",
+		})
+		return
+	}
+
+	transaction, err := c.service.SetSplits(userID, uint(id), splitLines(req.Splits))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSplits) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":     "Transaction splits updated successfully",
+		"transaction": transaction,
+	})
+}
+
+// UnlinkTransfer turns a transfer back into the expense and income it was
+// recorded as, for two transactions wrongly taken as one transfer
+func (c *TransactionController) UnlinkTransfer(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid transaction ID",
+		})
+		return
+	}
+
+	userID := utils.GetUserID(ctx)
+	if _, err := c.service.GetByID(userID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Transaction not found",
+		})
+		return
+	}
+
+	transaction, err := services.NewAccountService().UnlinkTransfer(userID, uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidAccount) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":     "Transfer unlinked successfully",
+		"transaction": transaction,
+	})
+}
+
 func (c *TransactionController) GetMonthlySummary(ctx *gin.Context) {
 	yearParam := ctx.Query("year")
 	monthParam := ctx.Query("month")
@@ -255,7 +536,7 @@ func (c *TransactionController) GetMonthlySummary(ctx *gin.Context) {
 		return
 	}
 
-	summary, categories, err := c.service.GetMonthlySummary(year, month)
+	summary, categories, err := c.service.GetMonthlySummary(utils.GetUserID(ctx), year, month)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get summary",
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	stderrors "errors"
 	"fmt"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"ocr-api/config"
 	"ocr-api/services"
@@ -14,14 +17,14 @@ import (
 )
 
 type UploadController struct {
-	ocrService         *services.OCRService
-	transactionService *services.TransactionService
+	ocrService *services.OCRService
+	jobService *services.JobService
 }
 
 func NewUploadController() *UploadController {
 	return &UploadController{
-		ocrService:         services.NewOCRService(),
-		transactionService: services.NewTransactionService(),
+		ocrService: services.NewOCRService(),
+		jobService: services.NewJobService(),
 	}
 }
 
@@ -45,6 +48,8 @@ func (c *UploadController) UploadSlip(ctx *gin.Context) {
 		return
 	}
 
+	userID := utils.GetUserID(ctx)
+
 	// Get multipart form
 	form, err := ctx.MultipartForm()
 	if err != nil {
@@ -67,79 +72,111 @@ func (c *UploadController) UploadSlip(ctx *gin.Context) {
 		return
 	}
 
+	async := ctx.Query("async") == "true"
+
 	var transactions []interface{}
+	var drafts []interface{}
 	var errors []string
-	var uploadPaths []string
+	var jobFiles []services.JobFileInput
 
 	// Process each file
 	for _, file := range files {
 		if file.Size > config.AppConfig.MaxUploadSize {
-			errors = append(errors, fmt.Sprintf("File '%s' is too large (max 10MB)", file.Filename))
+			msg := fmt.Sprintf("File '%s' is too large (max 10MB)", file.Filename)
+			errors = append(errors, msg)
+			jobFiles = append(jobFiles, services.JobFileInput{Filename: file.Filename, Error: msg})
 			continue
 		}
 
 		filename := strings.ToLower(file.Filename)
 		if err := c.ocrService.ValidateImage(filename); err != nil {
-			errors = append(errors, fmt.Sprintf("File '%s': %s", file.Filename, err.Error()))
+			msg := fmt.Sprintf("File '%s': %s", file.Filename, err.Error())
+			errors = append(errors, msg)
+			jobFiles = append(jobFiles, services.JobFileInput{Filename: file.Filename, Error: msg})
 			continue
 		}
 
-		uniqueFilename := utils.GenerateUniqueFilename(filename)
-		uploadPath := filepath.Join(config.AppConfig.UploadDir, uniqueFilename)
+		// Async uploads leave the file on disk for the job workers
+		if async {
+			uniqueFilename := utils.GenerateUniqueFilename(filename)
+			uploadPath := filepath.Join(config.AppConfig.UploadDir, uniqueFilename)
+
+			if err := ctx.SaveUploadedFile(file, uploadPath); err != nil {
+				log.Printf("Failed to save uploaded file '%s': %v", file.Filename, err)
+				msg := fmt.Sprintf("Failed to save file '%s'", file.Filename)
+				errors = append(errors, msg)
+				jobFiles = append(jobFiles, services.JobFileInput{Filename: file.Filename, Error: msg})
+				continue
+			}
 
-		if err := ctx.SaveUploadedFile(file, uploadPath); err != nil {
-			log.Printf("Failed to save uploaded file '%s': %v", file.Filename, err)
-			errors = append(errors, fmt.Sprintf("Failed to save file '%s'", file.Filename))
+			log.Printf("File uploaded: %s (%.2f KB)", uniqueFilename, float64(file.Size)/1024)
+			jobFiles = append(jobFiles, services.JobFileInput{Filename: file.Filename, StoredPath: uploadPath})
 			continue
 		}
 
-		uploadPaths = append(uploadPaths, uploadPath)
-		log.Printf("File uploaded: %s (%.2f KB)", uniqueFilename, float64(file.Size)/1024)
-
-		transaction, detectedSub, err := c.ocrService.ProcessSlip(uploadPath, req.Type)
+		// Others are read in memory
+		imageData, err := readUploadedFile(file)
 		if err != nil {
-			log.Printf("OCR processing failed for '%s': %v", file.Filename, err)
-			errors = append(errors, fmt.Sprintf("Failed to process '%s': %s", file.Filename, err.Error()))
+			log.Printf("Failed to read uploaded file '%s': %v", file.Filename, err)
+			errors = append(errors, fmt.Sprintf("Failed to read file '%s'", file.Filename))
 			continue
 		}
 
-		// Check for duplicates
-		duplicate, _ := c.transactionService.CheckDuplicate(transaction)
-		if duplicate != nil {
-			log.Printf("Duplicate transaction detected for '%s'", file.Filename)
-			errors = append(errors, fmt.Sprintf("Duplicate slip '%s' (already exists as transaction #%d)", file.Filename, duplicate.ID))
-			continue
-		}
+		log.Printf("File uploaded: %s (%.2f KB)", file.Filename, float64(file.Size)/1024)
 
-		if err := c.transactionService.Create(transaction); err != nil {
-			log.Printf("Failed to save transaction for '%s': %v", file.Filename, err)
-			errors = append(errors, fmt.Sprintf("Failed to save transaction for '%s'", file.Filename))
+		transaction, draft, err := c.ocrService.ImportSlip(ctx.Request.Context(), userID, imageData, file.Filename, req.Type)
+		if err != nil {
+			// Slips queue for OCR; a client that gave up waiting gets no more processed
+			if ctx.Request.Context().Err() != nil {
+				log.Printf("Client disconnected, stopping at '%s': %v", file.Filename, err)
+				break
+			}
+			var duplicateErr *services.DuplicateSlipError
+			if stderrors.As(err, &duplicateErr) {
+				log.Printf("Duplicate transaction detected for '%s'", file.Filename)
+				errors = append(errors, fmt.Sprintf("Duplicate slip '%s' (already exists as transaction #%d)", file.Filename, duplicateErr.ExistingID))
+			} else {
+				log.Printf("OCR processing failed for '%s': %v", file.Filename, err)
+				errors = append(errors, fmt.Sprintf("Failed to process '%s': %s", file.Filename, err.Error()))
+			}
 			continue
 		}
 
-		// Auto-save detected subscription
-		if detectedSub != nil {
-			detectedSub.NextBillingDate = transaction.Date
-			subscriptionService := services.NewSubscriptionService()
-			if err := subscriptionService.Create(detectedSub); err != nil {
-				log.Printf("Failed to save auto-detected subscription: %v", err)
-			} else {
-				log.Printf("Auto-detected subscription: %s", detectedSub.Name)
-			}
+		if draft != nil {
+			log.Printf("Slip '%s' is incomplete (missing %s), saved as draft #%d", file.Filename, draft.MissingFields, draft.ID)
+			drafts = append(drafts, draft)
+			continue
 		}
 
 		transactions = append(transactions, transaction)
 	}
 
-	// Cleanup all uploaded files
-	for _, uploadPath := range uploadPaths {
-		if err := c.ocrService.CleanupUploadedFile(uploadPath); err != nil {
-			log.Printf("Warning: failed to cleanup uploaded file: %v", err)
+	if async {
+		job, err := c.jobService.Create(userID, req.Type, jobFiles)
+		if err != nil {
+			log.Printf("Failed to create upload job: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to queue slips for processing",
+			})
+			return
 		}
+
+		response := gin.H{
+			"message":     fmt.Sprintf("Queued %d out of %d slips for processing", len(files)-len(errors), len(files)),
+			"job_id":      job.ID,
+			"status_url":  fmt.Sprintf("/api/v1/jobs/%d", job.ID),
+			"total_count": len(files),
+		}
+		if len(errors) > 0 {
+			response["errors"] = errors
+		}
+
+		ctx.JSON(http.StatusAccepted, response)
+		return
 	}
 
 	// Return response
-	if len(transactions) == 0 {
+	if len(transactions) == 0 && len(drafts) == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":  "No slips were processed successfully",
 			"errors": errors,
@@ -154,9 +191,29 @@ func (c *UploadController) UploadSlip(ctx *gin.Context) {
 		"total_count":   len(files),
 	}
 
+	if len(drafts) > 0 {
+		response["message"] = fmt.Sprintf("Processed %d out of %d slips successfully, %d need review", len(transactions), len(files), len(drafts))
+		response["drafts"] = drafts
+		response["draft_count"] = len(drafts)
+	}
+
 	if len(errors) > 0 {
 		response["errors"] = errors
 	}
 
 	ctx.JSON(http.StatusCreated, response)
 }
+
+func readUploadedFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+// OCRStats reports OCR concurrency, queueing and timing since startup
+func (c *UploadController) OCRStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"ocr": c.ocrService.PoolStats()})
+}
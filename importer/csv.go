@@ -0,0 +1,321 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"gopkg.in/yaml.v3"
+
+	"ocr-api/utils"
+)
+
+// CSVMapping describes one bank's internet banking CSV export. Columns are
+// matched by header name, ignoring case and surrounding spaces. Name selects
+// the mapping (the bank form field); Bank should match a bank template name so
+// rows reconcile against slips from that bank.
+type CSVMapping struct {
+	Name       string `yaml:"name"`
+	Bank       string `yaml:"bank"`        // bank template name given to imported rows, empty for generic exports
+	Delimiter  string `yaml:"delimiter"`   // default ","
+	Encoding   string `yaml:"encoding"`    // utf-8 (default) or windows-874
+	DateOrder  string `yaml:"date_order"`  // dmy (default) or mdy
+	DateLocale string `yaml:"date_locale"` // th or en, as for bank templates
+
+	Columns struct {
+		Date        string `yaml:"date"`
+		Time        string `yaml:"time"`
+		Description string `yaml:"description"`
+		Reference   string `yaml:"reference"`
+		Amount      string `yaml:"amount"`     // one signed column, negative for withdrawals
+		Withdrawal  string `yaml:"withdrawal"` // or separate withdrawal and deposit columns
+		Deposit     string `yaml:"deposit"`
+	} `yaml:"columns"`
+
+	File string `yaml:"-"`
+}
+
+// csvMappings are the mappings used by Parse, loaded by LoadCSVMappings
+var csvMappings []*CSVMapping
+
+// LoadCSVMappings loads and validates every *.yaml / *.yml file in dir
+func LoadCSVMappings(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read CSV mapping directory: %w", err)
+	}
+
+	var mappings []*CSVMapping
+	names := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		file := filepath.Join(dir, entry.Name())
+		mapping, err := parseCSVMappingFile(file)
+		if err != nil {
+			return err
+		}
+
+		key := strings.ToLower(mapping.Name)
+		if other, exists := names[key]; exists {
+			return fmt.Errorf("%s: bank %q is already mapped in %s", file, mapping.Name, other)
+		}
+		names[key] = file
+		mappings = append(mappings, mapping)
+	}
+
+	// Mappings requiring more columns are more specific and are tried first
+	sort.Slice(mappings, func(i, j int) bool {
+		if len(mappings[i].required()) != len(mappings[j].required()) {
+			return len(mappings[i].required()) > len(mappings[j].required())
+		}
+		return mappings[i].Name < mappings[j].Name
+	})
+	csvMappings = mappings
+
+	log.Printf("Loaded %d CSV import mappings from %s", len(mappings), dir)
+	return nil
+}
+
+func parseCSVMappingFile(file string) (*CSVMapping, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var m CSVMapping
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	m.File = file
+
+	if strings.TrimSpace(m.Name) == "" {
+		return nil, fmt.Errorf("%s: name is required", file)
+	}
+	if utf8.RuneCountInString(m.Delimiter) > 1 {
+		return nil, fmt.Errorf("%s: delimiter must be a single character", file)
+	}
+	switch strings.ToLower(m.Encoding) {
+	case "", "utf-8", "utf8", "windows-874", "tis-620":
+	default:
+		return nil, fmt.Errorf("%s: unknown encoding %q (use utf-8 or windows-874)", file, m.Encoding)
+	}
+	switch m.DateOrder {
+	case "", dayFirst, monthFirst:
+	default:
+		return nil, fmt.Errorf("%s: unknown date_order %q (use %s or %s)", file, m.DateOrder, dayFirst, monthFirst)
+	}
+	switch m.DateLocale {
+	case "", utils.DateLocaleTH, utils.DateLocaleEN:
+	default:
+		return nil, fmt.Errorf("%s: unknown date_locale %q (use %s or %s)", file, m.DateLocale, utils.DateLocaleTH, utils.DateLocaleEN)
+	}
+
+	if m.Columns.Date == "" {
+		return nil, fmt.Errorf("%s: columns.date is required", file)
+	}
+	hasAmount := m.Columns.Amount != ""
+	hasSplit := m.Columns.Withdrawal != "" && m.Columns.Deposit != ""
+	if hasAmount == hasSplit {
+		return nil, fmt.Errorf("%s: map either columns.amount or both columns.withdrawal and columns.deposit", file)
+	}
+
+	return &m, nil
+}
+
+// CSVMappingNames lists the banks that have a CSV mapping
+func CSVMappingNames() []string {
+	names := make([]string, len(csvMappings))
+	for i, m := range csvMappings {
+		names[i] = m.Name
+	}
+	return names
+}
+
+func (m *CSVMapping) delimiter() rune {
+	if m.Delimiter == "" {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(m.Delimiter)
+	return r
+}
+
+func (m *CSVMapping) decode(content []byte) ([]byte, error) {
+	switch strings.ToLower(m.Encoding) {
+	case "windows-874", "tis-620":
+		return charmap.Windows874.NewDecoder().Bytes(content)
+	}
+	return bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")), nil
+}
+
+// required lists the mapped header names that must all be present
+func (m *CSVMapping) required() []string {
+	columns := []string{m.Columns.Date}
+	if m.Columns.Amount != "" {
+		return append(columns, m.Columns.Amount)
+	}
+	return append(columns, m.Columns.Withdrawal, m.Columns.Deposit)
+}
+
+// csvTable is a decoded CSV file
+type csvTable struct {
+	records [][]string
+	lines   []int // file line of each record
+}
+
+func readCSV(content []byte, delimiter rune) (*csvTable, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // preamble lines have fewer fields than rows
+	reader.LazyQuotes = true
+
+	table := &csvTable{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		table.records = append(table.records, record)
+		table.lines = append(table.lines, line)
+	}
+	return table, nil
+}
+
+// findHeader returns the index of the first record containing every required
+// column, and the position of each header name in it
+func (t *csvTable) findHeader(required []string) (int, map[string]int) {
+	for i, record := range t.records {
+		positions := make(map[string]int)
+		for j, cell := range record {
+			positions[normalizeHeader(cell)] = j
+		}
+
+		found := true
+		for _, name := range required {
+			if _, ok := positions[normalizeHeader(name)]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i, positions
+		}
+	}
+	return -1, nil
+}
+
+func normalizeHeader(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(name, "\ufeff")), " "))
+}
+
+// parseCSV reads a CSV export with the named bank's mapping, or with the first
+// mapping whose columns all appear in the file
+func parseCSV(content []byte, bank string) (*Statement, error) {
+	candidates := csvMappings
+	if bank != "" {
+		candidates = nil
+		for _, m := range csvMappings {
+			if strings.EqualFold(m.Name, bank) {
+				candidates = []*CSVMapping{m}
+			}
+		}
+		if candidates == nil {
+			return nil, fmt.Errorf("no CSV mapping for bank %q (available: %s)", bank, strings.Join(CSVMappingNames(), ", "))
+		}
+	}
+
+	for _, m := range candidates {
+		decoded, err := m.decode(content)
+		if err != nil {
+			continue
+		}
+		table, err := readCSV(decoded, m.delimiter())
+		if err != nil {
+			continue
+		}
+		header, positions := table.findHeader(m.required())
+		if header < 0 {
+			continue
+		}
+		return m.parseRows(table, header, positions)
+	}
+
+	if bank != "" {
+		return nil, fmt.Errorf("CSV does not have the columns of the %s mapping", bank)
+	}
+	return nil, fmt.Errorf("CSV columns do not match any bank mapping (available: %s)", strings.Join(CSVMappingNames(), ", "))
+}
+
+func (m *CSVMapping) parseRows(table *csvTable, header int, positions map[string]int) (*Statement, error) {
+	cell := func(record []string, column string) string {
+		if column == "" {
+			return ""
+		}
+		i, ok := positions[normalizeHeader(column)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	statement := &Statement{Bank: m.Bank}
+	for i := header + 1; i < len(table.records); i++ {
+		record := table.records[i]
+		line := table.lines[i]
+
+		dateValue := cell(record, m.Columns.Date)
+		if dateValue == "" {
+			continue // blank or summary line
+		}
+		date, err := normalizeDate(dateValue, m.DateOrder, m.DateLocale)
+		if err != nil {
+			// Footers such as "Total" share the date column
+			log.Printf("CSV import: skipping line %d: %v", line, err)
+			continue
+		}
+
+		var signed float64
+		if m.Columns.Amount != "" {
+			if signed, err = parseAmount(cell(record, m.Columns.Amount)); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		} else {
+			withdrawal, err := parseAmount(cell(record, m.Columns.Withdrawal))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			deposit, err := parseAmount(cell(record, m.Columns.Deposit))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			signed = math.Abs(deposit) - math.Abs(withdrawal)
+		}
+		if signed == 0 {
+			continue
+		}
+
+		row := newRow(line, date, cell(record, m.Columns.Description), signed, cell(record, m.Columns.Reference))
+		row.Time = strings.Replace(cell(record, m.Columns.Time), ".", ":", 1)
+		statement.Rows = append(statement.Rows, row)
+	}
+
+	return statement, nil
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// loadMappings loads the mappings shipped in imports/
+func loadMappings(t *testing.T) {
+	t.Helper()
+	if err := LoadCSVMappings("../imports"); err != nil {
+		t.Fatalf("LoadCSVMappings: %v", err)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	loadMappings(t)
+
+	ktb, err := charmap.Windows874.NewEncoder().String("วันที่ทำรายการ,เวลา,รายการ,จำนวนเงิน\n" +
+		"05/01/2568,09:15,ค่าน้ำ,-250.00\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		bank     string
+		wantBank string
+		want     []Row
+		wantErr  string
+	}{
+		{
+			name: "KBank detected from header, with preamble and footer",
+			content: "Account No.,xxx-x-x1234-x\n" +
+				"\n" +
+				"Transaction Date,Time,Details,Withdrawal (THB),Deposit (THB)\n" +
+				"23/11/2025,14.05,Transfer to 7-Eleven,\"1,250.50\",\n" +
+				"24/11/2025,08:00,Salary,,\"30,000.00\"\n" +
+				"25/11/2025,09:00,Fee waived,0.00,0.00\n" +
+				"Total,,,\"1,250.50\",\"30,000.00\"\n",
+			wantBank: "KBank",
+			want: []Row{
+				{Line: 4, Date: "23/11/2025", Time: "14:05", Description: "Transfer to 7-Eleven", Amount: 1250.50, Type: "expense"},
+				{Line: 5, Date: "24/11/2025", Time: "08:00", Description: "Salary", Amount: 30000, Type: "income"},
+			},
+		},
+		{
+			name: "generic mapping chosen by bank, signed amounts",
+			content: "Date,Description,Reference,Amount\n" +
+				"2025-03-01,Coffee  shop,REF1,(85.00)\n" +
+				"2/3/2025,Refund,REF2,85.00\n" +
+				"3/3/2025,Card,REF3,120.00-\n",
+			bank: "generic",
+			want: []Row{
+				{Line: 2, Date: "01/03/2025", Description: "Coffee shop", Amount: 85, Type: "expense", Reference: "REF1"},
+				{Line: 3, Date: "02/03/2025", Description: "Refund", Amount: 85, Type: "income", Reference: "REF2"},
+				{Line: 4, Date: "03/03/2025", Description: "Card", Amount: 120, Type: "expense", Reference: "REF3"},
+			},
+		},
+		{
+			name: "SCB two-digit Buddhist-era years",
+			content: "วันที่,เวลา,รายละเอียด,ถอนเงิน,ฝากเงิน\n" +
+				"23/11/68,10:30,โอนเงิน,500.00,\n",
+			bank:     "scb",
+			wantBank: "SCB",
+			want: []Row{
+				{Line: 2, Date: "23/11/2025", Time: "10:30", Description: "โอนเงิน", Amount: 500, Type: "expense"},
+			},
+		},
+		{
+			name:     "KTB windows-874 with four-digit Buddhist-era years",
+			content:  ktb,
+			bank:     "KTB",
+			wantBank: "KTB",
+			want: []Row{
+				{Line: 2, Date: "05/01/2025", Time: "09:15", Description: "ค่าน้ำ", Amount: 250, Type: "expense"},
+			},
+		},
+		{
+			name:    "unknown bank",
+			content: "Date,Amount\n01/01/2025,1\n",
+			bank:    "nobank",
+			wantErr: `no CSV mapping for bank "nobank"`,
+		},
+		{
+			name:    "columns of the chosen mapping missing",
+			content: "Date,Amount\n01/01/2025,1\n",
+			bank:    "KBank",
+			wantErr: "CSV does not have the columns of the KBank mapping",
+		},
+		{
+			name:    "no mapping matches",
+			content: "When,What\n01/01/2025,1\n",
+			wantErr: "CSV columns do not match any bank mapping",
+		},
+		{
+			name:    "invalid amount",
+			content: "Date,Amount\n01/01/2025,abc\n",
+			bank:    "generic",
+			wantErr: `line 2: invalid amount "abc"`,
+		},
+		{
+			name:    "only footers",
+			content: "Date,Amount\nTotal,0\n",
+			bank:    "generic",
+			wantErr: "no transactions found in csv file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, err := Parse(FormatCSV, []byte(tt.content), tt.bank)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if statement.Format != FormatCSV || statement.Bank != tt.wantBank {
+				t.Errorf("Parse() format, bank = %q, %q, want %q, %q", statement.Format, statement.Bank, FormatCSV, tt.wantBank)
+			}
+			if !reflect.DeepEqual(statement.Rows, tt.want) {
+				t.Errorf("Parse() rows =\n%+v\nwant\n%+v", statement.Rows, tt.want)
+			}
+		})
+	}
+}
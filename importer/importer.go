@@ -0,0 +1,184 @@
+// Package importer reads transactions from bank statement exports (CSV, OFX
+// and QIF) into a common row format for reconciliation.
+package importer
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ocr-api/utils"
+)
+
+// Supported file formats
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+	FormatQIF = "qif"
+)
+
+// Row is one transaction line of an imported file
+type Row struct {
+	Line        int     `json:"line"` // line (CSV, QIF) or entry (OFX) number in the file
+	Date        string  `json:"date"` // DD/MM/YYYY
+	Time        string  `json:"time,omitempty"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+	Type        string  `json:"type"` // income or expense
+	Reference   string  `json:"reference,omitempty"`
+}
+
+// Statement is the parsed content of an imported file
+type Statement struct {
+	Format string
+	Bank   string // from the CSV mapping or the OFX header, may be empty
+	Rows   []Row
+}
+
+// FormatFromFilename picks the format from a file extension
+func FormatFromFilename(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ofx", ".qfx":
+		return FormatOFX, nil
+	case ".qif":
+		return FormatQIF, nil
+	}
+	return "", fmt.Errorf("unsupported file type %q. Allowed types: csv, ofx, qfx, qif", filepath.Ext(filename))
+}
+
+// Parse reads a statement file. bank selects the CSV column mapping; when empty
+// the mapping is chosen from the CSV header.
+func Parse(format string, content []byte, bank string) (*Statement, error) {
+	var statement *Statement
+	var err error
+
+	switch format {
+	case FormatCSV:
+		statement, err = parseCSV(content, bank)
+	case FormatOFX:
+		statement, err = parseOFX(content)
+	case FormatQIF:
+		statement, err = parseQIF(content)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statement.Rows) == 0 {
+		return nil, fmt.Errorf("no transactions found in %s file", format)
+	}
+	statement.Format = format
+	return statement, nil
+}
+
+// newRow turns a signed amount into a row with a positive amount and a type
+func newRow(line int, date, description string, signed float64, reference string) Row {
+	row := Row{
+		Line:        line,
+		Date:        date,
+		Description: strings.Join(strings.Fields(description), " "),
+		Amount:      math.Abs(signed),
+		Type:        "income",
+		Reference:   strings.TrimSpace(reference),
+	}
+	if signed < 0 {
+		row.Type = "expense"
+	}
+	return row
+}
+
+var amountNoise = strings.NewReplacer(",", "", " ", "", "\u00a0", "", "฿", "", "THB", "")
+
+// parseAmount reads amounts such as 1,500.00, -1,500.00, 1,500.00- and (1,500.00)
+func parseAmount(value string) (float64, error) {
+	value = amountNoise.Replace(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+	if strings.HasSuffix(value, "-") {
+		negative = true
+		value = strings.TrimSuffix(value, "-")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+var (
+	isoDate     = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})`)
+	numericDate = regexp.MustCompile(`^(\d{1,2})[/.\-'](\d{1,2})[/.\-'](\d{2,4})`)
+)
+
+// Day orders for numeric dates
+const (
+	dayFirst   = "dmy"
+	monthFirst = "mdy"
+)
+
+// normalizeDate converts the date formats found in exports to DD/MM/YYYY with
+// a Gregorian year. With utils.DateLocaleTH, 2-digit years are Buddhist era.
+func normalizeDate(value, order, locale string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if m := isoDate.FindStringSubmatch(value); m != nil {
+		return formatDate(m[3], m[2], m[1], locale)
+	}
+
+	if m := numericDate.FindStringSubmatch(value); m != nil {
+		day, month := m[1], m[2]
+		if order == monthFirst {
+			day, month = month, day
+		}
+		return formatDate(day, month, m[3], locale)
+	}
+
+	// Thai month abbreviations (23 พ.ย. 68) are handled like slip dates
+	if normalized := utils.NormalizeDateLocale(value, locale); normalized != value {
+		if m := numericDate.FindStringSubmatch(normalized); m != nil {
+			return formatDate(m[1], m[2], m[3], locale)
+		}
+	}
+
+	return "", fmt.Errorf("unrecognized date %q", value)
+}
+
+func formatDate(day, month, year, locale string) (string, error) {
+	d, _ := strconv.Atoi(day)
+	m, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+
+	if len(year) == 2 {
+		if locale == utils.DateLocaleTH {
+			y += 2500
+		} else {
+			y += 2000
+		}
+	}
+	if y > 2400 {
+		y -= 543
+	}
+
+	if d < 1 || d > 31 || m < 1 || m > 12 {
+		return "", fmt.Errorf("invalid date %s/%s/%s", day, month, year)
+	}
+	return fmt.Sprintf("%02d/%02d/%04d", d, m, y), nil
+}
@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"testing"
+
+	"ocr-api/utils"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1,500.00", want: 1500},
+		{value: "-1,500.00", want: -1500},
+		{value: "1,500.00-", want: -1500},
+		{value: "(1,500.00)", want: -1500},
+		{value: "฿ 99.50", want: 99.50},
+		{value: "99.50 THB", want: 99.50},
+		{value: "1 000", want: 1000},
+		{value: "abc", wantErr: true},
+		{value: "1.2.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAmount(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAmount(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAmount(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		order   string
+		locale  string
+		want    string
+		wantErr bool
+	}{
+		{name: "day first", value: "3/1/2025", order: dayFirst, want: "03/01/2025"},
+		{name: "month first", value: "1/3/2025", order: monthFirst, want: "03/01/2025"},
+		{name: "ISO with time", value: "2025-01-03T10:00:00", order: monthFirst, want: "03/01/2025"},
+		{name: "dots", value: "03.01.2025", order: dayFirst, want: "03/01/2025"},
+		{name: "QIF apostrophe", value: "3/1'25", order: dayFirst, want: "03/01/2025"},
+		{name: "two-digit year", value: "03/01/25", order: dayFirst, want: "03/01/2025"},
+		{name: "two-digit Buddhist-era year", value: "03/01/68", order: dayFirst, locale: utils.DateLocaleTH, want: "03/01/2025"},
+		{name: "four-digit Buddhist-era year", value: "03/01/2568", order: dayFirst, want: "03/01/2025"},
+		{name: "Thai month", value: "3 ม.ค. 68", order: dayFirst, locale: utils.DateLocaleTH, want: "03/01/2025"},
+		{name: "month out of range", value: "03/13/2025", order: dayFirst, wantErr: true},
+		{name: "day zero", value: "00/01/2025", order: dayFirst, wantErr: true},
+		{name: "text", value: "Total", order: dayFirst, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeDate(tt.value, tt.order, tt.locale)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeDate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ofxTransaction    = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxTransactionEnd = regexp.MustCompile(`(?i)</STMTTRN>|<STMTTRN>|</BANKTRANLIST>`)
+	ofxDate           = regexp.MustCompile(`^(\d{4})(\d{2})(\d{2})(?:(\d{2})(\d{2}))?`)
+)
+
+// ofxField reads a tag's value from an OFX block. OFX 1.x (SGML) leaves leaf
+// tags unclosed, so the value runs to the next tag or line break.
+func ofxField(block, tag string) string {
+	re := regexp.MustCompile(`(?i)<` + tag + `>([^<\r\n]*)`)
+	if m := re.FindStringSubmatch(block); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+// parseOFX reads the bank transaction list of an OFX 1.x or 2.x file
+func parseOFX(content []byte) (*Statement, error) {
+	text := string(content)
+	if !strings.Contains(strings.ToUpper(text), "<OFX>") {
+		return nil, fmt.Errorf("not an OFX file")
+	}
+
+	// ORG is free text such as "KASIKORNBANK"; callers map it to a bank
+	// template name
+	statement := &Statement{Bank: ofxField(text, "ORG")}
+
+	// A transaction runs to its closing tag or, when that is left out, to
+	// the next transaction
+	for i, start := range ofxTransaction.FindAllStringIndex(text, -1) {
+		block := text[start[1]:]
+		if end := ofxTransactionEnd.FindStringIndex(block); end != nil {
+			block = block[:end[0]]
+		}
+		entry := i + 1
+
+		posted := ofxDate.FindStringSubmatch(ofxField(block, "DTPOSTED"))
+		if posted == nil {
+			return nil, fmt.Errorf("transaction %d: invalid DTPOSTED", entry)
+		}
+		date, err := formatDate(posted[3], posted[2], posted[1], "")
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", entry, err)
+		}
+
+		signed, err := parseAmount(ofxField(block, "TRNAMT"))
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", entry, err)
+		}
+		if signed == 0 {
+			continue
+		}
+
+		description := ofxField(block, "NAME")
+		if memo := ofxField(block, "MEMO"); memo != "" && memo != description {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+
+		row := newRow(entry, date, description, signed, ofxField(block, "FITID"))
+		// Midnight is how OFX writes "no time"
+		if posted[4] != "" && posted[4]+posted[5] != "0000" {
+			row.Time = posted[4] + ":" + posted[5]
+		}
+		statement.Rows = append(statement.Rows, row)
+	}
+
+	return statement, nil
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOFX(t *testing.T) {
+	// OFX 1.x leaves leaf tags unclosed; 2.x closes them
+	sgml := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><FI><ORG>SOMEBANK</FI></SONRS></SIGNONMSGSRSV1>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20251123143000[+7:ICT]
+<TRNAMT>-1,250.50
+<FITID>TX001
+<NAME>7-ELEVEN
+<MEMO>Store 123
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20251124
+<TRNAMT>30000.00
+<FITID>TX002
+<NAME>SALARY
+<MEMO>SALARY
+<STMTTRN>
+<TRNTYPE>OTHER
+<DTPOSTED>20251125000000
+<TRNAMT>0.00
+<FITID>TX003
+</BANKTRANLIST>
+</OFX>`
+
+	xml := `<?xml version="1.0"?>
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN><DTPOSTED>20250101000000</DTPOSTED><TRNAMT>-99.00</TRNAMT><FITID>A1</FITID><NAME>Netflix</NAME></STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	tests := []struct {
+		name     string
+		content  string
+		wantBank string
+		want     []Row
+		wantErr  string
+	}{
+		{
+			name:     "OFX 1.x",
+			content:  sgml,
+			wantBank: "SOMEBANK",
+			want: []Row{
+				{Line: 1, Date: "23/11/2025", Time: "14:30", Description: "7-ELEVEN Store 123", Amount: 1250.50, Type: "expense", Reference: "TX001"},
+				{Line: 2, Date: "24/11/2025", Description: "SALARY", Amount: 30000, Type: "income", Reference: "TX002"},
+			},
+		},
+		{
+			name:    "OFX 2.x, midnight means no time",
+			content: xml,
+			want: []Row{
+				{Line: 1, Date: "01/01/2025", Description: "Netflix", Amount: 99, Type: "expense", Reference: "A1"},
+			},
+		},
+		{
+			name:    "not OFX",
+			content: "Date,Amount\n",
+			wantErr: "not an OFX file",
+		},
+		{
+			name:    "invalid date",
+			content: "<OFX><STMTTRN><DTPOSTED>yesterday<TRNAMT>1</STMTTRN></OFX>",
+			wantErr: "transaction 1: invalid DTPOSTED",
+		},
+		{
+			name:    "out-of-range date",
+			content: "<OFX><STMTTRN><DTPOSTED>20251340<TRNAMT>1</STMTTRN></OFX>",
+			wantErr: "transaction 1: invalid date",
+		},
+		{
+			name:    "invalid amount",
+			content: "<OFX><STMTTRN><DTPOSTED>20250101<TRNAMT>1.2.3</STMTTRN></OFX>",
+			wantErr: `transaction 1: invalid amount "1.2.3"`,
+		},
+		{
+			name:    "no transactions",
+			content: "<OFX><BANKTRANLIST></BANKTRANLIST></OFX>",
+			wantErr: "no transactions found in ofx file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, err := Parse(FormatOFX, []byte(tt.content), "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if statement.Bank != tt.wantBank {
+				t.Errorf("Parse() bank = %q, want %q", statement.Bank, tt.wantBank)
+			}
+			if !reflect.DeepEqual(statement.Rows, tt.want) {
+				t.Errorf("Parse() rows =\n%+v\nwant\n%+v", statement.Rows, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// parseQIF reads a QIF bank register. Dates are read day first, as Thai banks
+// write them, and month first only when that fails (e.g. 12/25'25).
+func parseQIF(content []byte) (*Statement, error) {
+	statement := &Statement{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	var date, payee, memo, number, amount string
+	start, line := 0, 0
+	header := false
+
+	flush := func() error {
+		defer func() { date, payee, memo, number, amount = "", "", "", "", "" }()
+		if date == "" && amount == "" {
+			return nil
+		}
+
+		normalized, err := normalizeDate(date, dayFirst, "")
+		if err != nil {
+			normalized, err = normalizeDate(date, monthFirst, "")
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", start, err)
+		}
+		signed, err := parseAmount(amount)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", start, err)
+		}
+		if signed == 0 {
+			return nil
+		}
+
+		description := payee
+		if memo != "" && memo != payee {
+			description = strings.TrimSpace(payee + " " + memo)
+		}
+		statement.Rows = append(statement.Rows, newRow(start, normalized, description, signed, number))
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "!") {
+			header = true
+			continue
+		}
+		if start == 0 {
+			start = line
+		}
+
+		value := strings.TrimSpace(text[1:])
+		switch text[0] {
+		case 'D':
+			date = value
+		case 'T', 'U':
+			amount = value
+		case 'P':
+			payee = value
+		case 'M':
+			memo = value
+		case 'N':
+			number = value
+		case '^':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			start = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if !header && len(statement.Rows) == 0 {
+		return nil, fmt.Errorf("not a QIF file")
+	}
+	return statement, nil
+}
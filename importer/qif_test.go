@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Row
+		wantErr string
+	}{
+		{
+			name: "bank register",
+			content: "!Type:Bank\n" +
+				"D23/11/2025\n" +
+				"T-1,250.50\n" +
+				"P7-ELEVEN\n" +
+				"MStore 123\n" +
+				"N1001\n" +
+				"^\n" +
+				"D24/11'25\n" +
+				"U30,000.00\n" +
+				"PSALARY\n" +
+				"MSALARY\n" +
+				"^\n",
+			want: []Row{
+				{Line: 2, Date: "23/11/2025", Description: "7-ELEVEN Store 123", Amount: 1250.50, Type: "expense", Reference: "1001"},
+				{Line: 8, Date: "24/11/2025", Description: "SALARY", Amount: 30000, Type: "income"},
+			},
+		},
+		{
+			name:    "month-first date when day-first fails",
+			content: "!Type:Bank\nD12/25'25\nT-10\nPGift\n^\n",
+			want: []Row{
+				{Line: 2, Date: "25/12/2025", Description: "Gift", Amount: 10, Type: "expense"},
+			},
+		},
+		{
+			name:    "last entry without terminator, zero amounts skipped",
+			content: "!Type:Bank\nD01/01/2025\nT0\n^\nD02/01/2025\nT5\nPCashback",
+			want: []Row{
+				{Line: 5, Date: "02/01/2025", Description: "Cashback", Amount: 5, Type: "income"},
+			},
+		},
+		{
+			name:    "invalid date",
+			content: "!Type:Bank\nDsoon\nT5\n^\n",
+			wantErr: `line 2: unrecognized date "soon"`,
+		},
+		{
+			name:    "invalid amount",
+			content: "!Type:Bank\nD01/01/2025\nTfive\n^\n",
+			wantErr: `line 2: invalid amount "five"`,
+		},
+		{
+			name:    "not QIF",
+			content: "hello\n",
+			wantErr: "not a QIF file",
+		},
+		{
+			name:    "header only",
+			content: "!Type:Bank\n",
+			wantErr: "no transactions found in qif file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, err := Parse(FormatQIF, []byte(tt.content), "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if !reflect.DeepEqual(statement.Rows, tt.want) {
+				t.Errorf("Parse() rows =\n%+v\nwant\n%+v", statement.Rows, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"log"
 	"ocr-api/config"
+	"ocr-api/importer"
+	"ocr-api/ocr"
 	"ocr-api/routes"
+	"ocr-api/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +19,32 @@ func main() {
 	// Initialize database
 	config.InitDatabase()
 
+	// Load bank slip templates, reloading them when the files change
+	reload := time.Duration(config.AppConfig.BankTemplatesReload) * time.Second
+	if err := ocr.LoadBankTemplates(config.AppConfig.BankTemplatesDir, reload); err != nil {
+		log.Fatalf("Failed to load bank templates: %v", err)
+	}
+
+	// Share long-lived Tesseract clients and cap how many reads run at once
+	ocr.InitClientPool(config.AppConfig.OCRConcurrency)
+
+	// Load CSV column mappings for statement imports
+	if err := importer.LoadCSVMappings(config.AppConfig.ImportMappingsDir); err != nil {
+		log.Fatalf("Failed to load import mappings: %v", err)
+	}
+
+	// Give categories to users and rows from before categories were rows
+	services.LinkCategories()
+
+	// Link transactions created before the payee directory to their payees
+	services.LinkPayees()
+
+	// Start background workers for async slip uploads
+	services.StartJobWorkers(config.AppConfig.JobWorkers)
+
+	// Remove attachments past their retention and those of deleted transactions
+	services.StartAttachmentPurge()
+
 	// Set Gin mode (release/debug)
 	gin.SetMode(gin.DebugMode)
 
@@ -33,6 +63,7 @@ func main() {
 	log.Printf("Upload directory: %s", config.AppConfig.UploadDir)
 	log.Printf("Database path: %s", config.AppConfig.DatabasePath)
 	log.Printf("Tesseract language: %s", config.AppConfig.TesseractLang)
+	log.Printf("OCR concurrency: %d", config.AppConfig.OCRConcurrency)
 
 	if err := router.Run(serverAddr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Account is one of the user's own bank or wallet accounts. Transactions are
+// linked to it by the account numbers printed on slips, which are usually
+// masked (xxx-x-x1234-x), or by its bank.
+type Account struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	UserID         uint           `gorm:"index;not null" json:"user_id"`
+	User           *User          `gorm:"foreignKey:UserID" json:"-"`
+	Name           string         `gorm:"type:varchar(100)" json:"name"`
+	Bank           string         `gorm:"type:varchar(50)" json:"bank"`
+	Number         string         `gorm:"type:varchar(30)" json:"number"` // full or masked, e.g. xxx-x-x1234-x
+	Currency       string         `gorm:"type:varchar(3);not null;default:THB" json:"currency"`
+	OpeningBalance float64        `json:"opening_balance"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Account) TableName() string {
+	return "accounts"
+}
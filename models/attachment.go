@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Attachment is a file kept as evidence for a transaction: the uploaded slip,
+// or a receipt or invoice added later. The content is stored once per SHA-256
+// hash, however many attachments share it.
+type Attachment struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	User          *User     `gorm:"foreignKey:UserID" json:"-"`
+	TransactionID *uint     `gorm:"index" json:"transaction_id,omitempty"`
+	DraftID       *uint     `gorm:"index" json:"draft_id,omitempty"`             // slip of a draft not yet approved
+	Kind          string    `gorm:"type:varchar(20);not null" json:"kind"`       // see Attachment* constants
+	Hash          string    `gorm:"type:varchar(64);index;not null" json:"hash"` // SHA-256 of the content, hex
+	Filename      string    `gorm:"type:varchar(255)" json:"filename"`           // as uploaded
+	ContentType   string    `gorm:"type:varchar(100)" json:"content_type"`
+	Size          int64     `json:"size"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// What an attachment is
+const (
+	AttachmentSlip    = "slip"
+	AttachmentReceipt = "receipt"
+	AttachmentInvoice = "invoice"
+	AttachmentOther   = "other"
+)
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
@@ -8,7 +8,10 @@ import (
 
 type Budget struct {
 	ID           uint           `gorm:"primarykey" json:"id"`
+	UserID       uint           `gorm:"index;not null;default:0" json:"user_id"`
+	User         *User          `gorm:"foreignKey:UserID" json:"-"`
 	Category     string         `gorm:"type:varchar(100);not null" json:"category"`
+	CategoryID   *uint          `gorm:"index" json:"category_id,omitempty"` // spending in its subcategories counts too
 	MonthlyLimit float64        `gorm:"not null" json:"monthly_limit"`
 	Month        int            `gorm:"not null" json:"month"` // 1-12
 	Year         int            `gorm:"not null" json:"year"`
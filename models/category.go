@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Category is a node in a user's category tree. Transactions, budgets and
+// subscriptions link to it with CategoryID and keep its name in Category.
+type Category struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	UserID    uint           `gorm:"index;not null" json:"user_id"`
+	User      *User          `gorm:"foreignKey:UserID" json:"-"`
+	ParentID  *uint          `gorm:"index" json:"parent_id,omitempty"`
+	Key       string         `gorm:"type:varchar(50);index" json:"key,omitempty"` // built-in category this was created from, kept when renamed
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`      // Thai label
+	NameEN    string         `gorm:"type:varchar(100)" json:"name_en,omitempty"`
+	Kind      string         `gorm:"type:varchar(10);not null" json:"kind"` // income or expense, same as the parent
+	Icon      string         `gorm:"type:varchar(50)" json:"icon,omitempty"`
+	Color     string         `gorm:"type:varchar(7)" json:"color,omitempty"` // #RRGGBB
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Category) TableName() string {
+	return "categories"
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CategoryRule assigns a category (and optionally a detail) to transactions
+// matching all of its conditions. Empty conditions match anything.
+type CategoryRule struct {
+	ID       uint   `gorm:"primarykey" json:"id"`
+	UserID   uint   `gorm:"index;not null" json:"user_id"`
+	User     *User  `gorm:"foreignKey:UserID" json:"-"`
+	Name     string `gorm:"type:varchar(100)" json:"name"`
+	Priority int    `gorm:"not null;default:0" json:"priority"` // higher is tried first; the first matching rule wins
+	Enabled  bool   `gorm:"not null" json:"enabled"`
+
+	// Conditions
+	Type            string   `gorm:"type:varchar(10)" json:"type,omitempty"`              // income or expense
+	ReceiverPattern string   `gorm:"type:varchar(255)" json:"receiver_pattern,omitempty"` // case-insensitive regexp
+	SenderPattern   string   `gorm:"type:varchar(255)" json:"sender_pattern,omitempty"`   // case-insensitive regexp
+	Bank            string   `gorm:"type:varchar(50)" json:"bank,omitempty"`
+	PayeeID         *uint    `json:"payee_id,omitempty"`
+	MinAmount       *float64 `json:"min_amount,omitempty"`
+	MaxAmount       *float64 `json:"max_amount,omitempty"`
+	TimeFrom        string   `gorm:"type:varchar(5)" json:"time_from,omitempty"` // HH:MM, Bangkok time; may wrap past midnight
+	TimeTo          string   `gorm:"type:varchar(5)" json:"time_to,omitempty"`
+	Weekdays        []string `gorm:"serializer:json;type:text" json:"weekdays,omitempty"` // mon, tue, ... sun
+
+	// Actions
+	Category string `gorm:"type:varchar(100);not null" json:"category"`
+	Detail   string `gorm:"type:text" json:"detail,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (CategoryRule) TableName() string {
+	return "category_rules"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Draft transaction states
+const (
+	DraftPending  = "pending"
+	DraftApproved = "approved"
+	DraftRejected = "rejected"
+)
+
+// DraftTransaction holds an OCR upload whose extraction was incomplete until
+// the user corrects and approves it (becoming a Transaction) or rejects it.
+type DraftTransaction struct {
+	ID              uint               `gorm:"primarykey" json:"id"`
+	UserID          uint               `gorm:"index;not null" json:"user_id"`
+	User            *User              `gorm:"foreignKey:UserID" json:"-"`
+	Status          string             `gorm:"type:varchar(20);index;not null;default:pending" json:"status"` // pending, approved, rejected
+	Type            string             `gorm:"type:varchar(10);not null" json:"type"`
+	Amount          float64            `json:"amount"`
+	Date            string             `gorm:"type:varchar(20)" json:"date"`
+	Time            string             `gorm:"type:varchar(20)" json:"time,omitempty"`
+	Reference       string             `gorm:"type:varchar(100)" json:"reference,omitempty"`
+	Bank            string             `gorm:"type:varchar(50)" json:"bank,omitempty"`
+	Channel         string             `gorm:"type:varchar(20)" json:"channel,omitempty"`
+	Sender          string             `gorm:"type:varchar(200)" json:"sender,omitempty"`
+	Receiver        string             `gorm:"type:varchar(200)" json:"receiver,omitempty"`
+	SenderAccount   string             `gorm:"type:varchar(30)" json:"sender_account,omitempty"`
+	ReceiverAccount string             `gorm:"type:varchar(30)" json:"receiver_account,omitempty"`
+	Category        string             `gorm:"type:varchar(100)" json:"category"`
+	Detail          string             `gorm:"type:text" json:"detail"`
+	RawOCRText      string             `gorm:"type:text" json:"raw_ocr_text,omitempty"`
+	Confidence      map[string]float64 `gorm:"serializer:json;type:text" json:"confidence,omitempty"`
+	OCRExtraction   map[string]string  `gorm:"serializer:json;type:text" json:"ocr_extraction,omitempty"`
+	OCRDiagnostics  json.RawMessage    `gorm:"type:text" json:"ocr_diagnostics,omitempty"` // how the slip was read: preprocessing and OCR passes
+	MissingFields   string             `gorm:"type:varchar(200)" json:"missing_fields"`    // comma-separated
+	ImagePath       string             `gorm:"type:varchar(500)" json:"-"`                 // preprocessed slip image
+	TransactionID   *uint              `json:"transaction_id,omitempty"`                   // set once approved
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+func (DraftTransaction) TableName() string {
+	return "draft_transactions"
+}
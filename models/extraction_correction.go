@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ExtractionCorrection records a user fixing a value that OCR extracted from a slip
+type ExtractionCorrection struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	TransactionID uint      `gorm:"index;not null" json:"transaction_id"`
+	Bank          string    `gorm:"type:varchar(50);index" json:"bank"` // bank detected at extraction time
+	Field         string    `gorm:"type:varchar(20);index;not null" json:"field"`
+	WrongValue    string    `gorm:"type:varchar(255)" json:"wrong_value"`
+	RightValue    string    `gorm:"type:varchar(255)" json:"right_value"`
+	RawText       string    `gorm:"type:text" json:"raw_text,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (ExtractionCorrection) TableName() string {
+	return "extraction_corrections"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payee is a canonical merchant or person that transactions are paid to or
+// received from. Aliases are the other spellings seen on slips.
+type Payee struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	UserID          uint           `gorm:"index;not null" json:"user_id"`
+	User            *User          `gorm:"foreignKey:UserID" json:"-"`
+	Name            string         `gorm:"type:varchar(200);not null" json:"name"`
+	Aliases         []string       `gorm:"serializer:json;type:text" json:"aliases"`
+	DefaultCategory string         `gorm:"type:varchar(100)" json:"default_category,omitempty"` // used when no categorization rule matches
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Payee) TableName() string {
+	return "payees"
+}
@@ -8,9 +8,12 @@ import (
 
 type Subscription struct {
 	ID              uint           `gorm:"primarykey" json:"id"`
+	UserID          uint           `gorm:"index;not null;default:0" json:"user_id"`
+	User            *User          `gorm:"foreignKey:UserID" json:"-"`
 	Name            string         `gorm:"type:varchar(200);not null" json:"name"`
 	Amount          float64        `gorm:"not null" json:"amount"`
 	Category        string         `gorm:"type:varchar(100)" json:"category"`
+	CategoryID      *uint          `gorm:"index" json:"category_id,omitempty"`
 	BillingCycle    string         `gorm:"type:varchar(20);not null" json:"billing_cycle"` // monthly, yearly
 	NextBillingDate string         `gorm:"type:varchar(20)" json:"next_billing_date"`      // DD/MM/YYYY
 	IsActive        bool           `gorm:"default:true" json:"is_active"`
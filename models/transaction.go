@@ -1,29 +1,56 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Transaction struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	Type       string         `gorm:"type:varchar(10);not null" json:"type"`
-	Amount     float64        `gorm:"not null" json:"amount"`
-	Date       string         `gorm:"type:varchar(20)" json:"date"`
-	Time       string         `gorm:"type:varchar(20)" json:"time,omitempty"`
-	Reference  string         `gorm:"type:varchar(100)" json:"reference,omitempty"`
-	Bank       string         `gorm:"type:varchar(50)" json:"bank,omitempty"`
-	Sender     string         `gorm:"type:varchar(200)" json:"sender,omitempty"`
-	Receiver   string         `gorm:"type:varchar(200)" json:"receiver,omitempty"`
-	Category   string         `gorm:"type:varchar(100)" json:"category"`
-	Detail     string         `gorm:"type:text" json:"detail"`
-	RawOCRText string         `gorm:"type:text" json:"raw_ocr_text,omitempty"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint               `gorm:"primarykey" json:"id"`
+	UserID            uint               `gorm:"index;not null;default:0" json:"user_id"`
+	User              *User              `gorm:"foreignKey:UserID" json:"-"`
+	Type              string             `gorm:"type:varchar(10);not null" json:"type"` // income, expense or transfer; a transfer moves money between own accounts and counts as neither
+	Amount            float64            `gorm:"not null" json:"amount"`
+	Date              string             `gorm:"type:varchar(20)" json:"date"`
+	Time              string             `gorm:"type:varchar(20)" json:"time,omitempty"`
+	OccurredAt        time.Time          `gorm:"index" json:"occurred_at"` // Date + Time as an instant, stored in UTC
+	Reference         string             `gorm:"type:varchar(100)" json:"reference,omitempty"`
+	Bank              string             `gorm:"type:varchar(50)" json:"bank,omitempty"`
+	Channel           string             `gorm:"type:varchar(20);index" json:"channel,omitempty"` // bank, wallet or counter; empty for manual entries
+	Source            string             `gorm:"type:varchar(20);index" json:"source"`            // see Source* constants
+	Sender            string             `gorm:"type:varchar(200)" json:"sender,omitempty"`
+	Receiver          string             `gorm:"type:varchar(200)" json:"receiver,omitempty"`
+	SenderAccount     string             `gorm:"type:varchar(30)" json:"sender_account,omitempty"`   // as printed, usually masked
+	ReceiverAccount   string             `gorm:"type:varchar(30)" json:"receiver_account,omitempty"` // as printed, usually masked
+	AccountID         *uint              `gorm:"index" json:"account_id,omitempty"`                  // own account the money left (expense, transfer) or arrived in (income)
+	TransferAccountID *uint              `gorm:"index" json:"transfer_account_id,omitempty"`         // own account a transfer arrived in
+	TransferPeerID    *uint              `json:"transfer_peer_id,omitempty"`                         // on the incoming half of a transfer recorded twice: the outgoing half
+	PayeeID           *uint              `gorm:"index" json:"payee_id,omitempty"`                    // canonical payee of the receiver (expense) or sender (income)
+	Category          string             `gorm:"type:varchar(100)" json:"category"`
+	CategoryID        *uint              `gorm:"index" json:"category_id,omitempty"`
+	Detail            string             `gorm:"type:text" json:"detail"`
+	Splits            []TransactionSplit `gorm:"foreignKey:TransactionID" json:"splits,omitempty"` // empty unless split across categories
+	RawOCRText        string             `gorm:"type:text" json:"raw_ocr_text,omitempty"`
+	Confidence        map[string]float64 `gorm:"serializer:json;type:text" json:"confidence,omitempty"` // per-field OCR confidence, 0-1
+	OverallConfidence float64            `json:"overall_confidence,omitempty"`
+	NeedsReview       bool               `gorm:"index;default:false" json:"needs_review"`
+	OCRExtraction     map[string]string  `gorm:"serializer:json;type:text" json:"ocr_extraction,omitempty"` // values as first extracted, nil for manual entries
+	OCRDiagnostics    json.RawMessage    `gorm:"type:text" json:"ocr_diagnostics,omitempty"`                // how the slip was read: preprocessing and OCR passes
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt     `gorm:"index" json:"-"`
 }
 
+// Where a transaction came from
+const (
+	SourceManual    = "manual"
+	SourceSlip      = "slip"      // OCR'd slip, directly or approved from the review queue
+	SourceStatement = "statement" // row of a PDF statement
+	SourceImport    = "import"    // row of a CSV, OFX or QIF file
+)
+
 func (Transaction) TableName() string {
 	return "transactions"
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TransactionSplit is one line of a transaction spread over several
+// categories. The lines of a transaction add up to its amount.
+type TransactionSplit struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	TransactionID uint      `gorm:"index;not null" json:"transaction_id"`
+	UserID        uint      `gorm:"index;not null" json:"-"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	Category      string    `gorm:"type:varchar(100)" json:"category"`
+	CategoryID    *uint     `gorm:"index" json:"category_id,omitempty"`
+	Note          string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (TransactionSplit) TableName() string {
+	return "transaction_splits"
+}
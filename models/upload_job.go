@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Upload job file states
+const (
+	JobFileQueued     = "queued"
+	JobFileProcessing = "processing"
+	JobFileDone       = "done"
+	JobFileFailed     = "failed"
+)
+
+// UploadJob is a batch of slips submitted with POST /upload?async=true
+type UploadJob struct {
+	ID          uint            `gorm:"primarykey" json:"id"`
+	UserID      uint            `gorm:"index;not null" json:"user_id"`
+	User        *User           `gorm:"foreignKey:UserID" json:"-"`
+	Type        string          `gorm:"type:varchar(10);not null" json:"type"`
+	Files       []UploadJobFile `gorm:"foreignKey:JobID" json:"files"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+func (UploadJob) TableName() string {
+	return "upload_jobs"
+}
+
+// UploadJobFile is a single slip inside an UploadJob
+type UploadJobFile struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	JobID         uint      `gorm:"index;not null" json:"job_id"`
+	Filename      string    `gorm:"type:varchar(255)" json:"filename"`
+	StoredPath    string    `gorm:"type:varchar(500)" json:"-"`
+	Status        string    `gorm:"type:varchar(20);index;not null" json:"status"` // queued, processing, done, failed
+	TransactionID *uint     `json:"transaction_id,omitempty"`
+	DraftID       *uint     `json:"draft_id,omitempty"` // set when the slip was sent to the review queue
+	Error         string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (UploadJobFile) TableName() string {
+	return "upload_job_files"
+}
@@ -0,0 +1,142 @@
+package ocr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Field names used as keys in ExtractedData.Confidence
+const (
+	FieldAmount    = "amount"
+	FieldDate      = "date"
+	FieldTime      = "time"
+	FieldReference = "reference"
+	FieldBank      = "bank"
+	FieldSender    = "sender"
+	FieldReceiver  = "receiver"
+
+	// Masked account numbers of the sender and receiver, e.g. xxx-x-x1234-x
+	FieldSenderAccount   = "sender_account"
+	FieldReceiverAccount = "receiver_account"
+
+	// Wallet and counter slips
+	FieldWalletPhone   = "wallet_phone"
+	FieldMerchantID    = "merchant_id"
+	FieldTransactionID = "transaction_id"
+)
+
+// RequiredFields are the fields a transaction cannot do without; the overall
+// confidence of a slip is the lowest score among them.
+var RequiredFields = []string{FieldAmount, FieldDate}
+
+const (
+	// bankIdentifierScore is used when the bank was recognised from the slip text
+	bankIdentifierScore = 0.9
+	// fallbackPenalty applies when the bank was unknown and the fallback template was used
+	fallbackPenalty = 0.85
+)
+
+// patternScore rates a match by its pattern's position: patterns are listed
+// from most to least specific, so later ones are more likely to be a guess.
+func patternScore(index int, fallback bool) float64 {
+	if index < 0 {
+		return 0
+	}
+	score := 1.0 - 0.15*float64(index)
+	if score < 0.4 {
+		score = 0.4
+	}
+	if fallback {
+		score *= fallbackPenalty
+	}
+	return score
+}
+
+// ScoreConfidence scales each field's pattern score by Tesseract's confidence
+// in the words that make up the extracted value. Fields read from their
+// layout region were already scored by their own reading.
+func ScoreConfidence(data *ExtractedData, result *OCRResult) {
+	if data.Confidence == nil || result == nil || len(result.Words) == 0 {
+		return
+	}
+
+	values := map[string]string{
+		FieldDate:     data.Date,
+		FieldTime:     data.Time,
+		FieldSender:   data.Sender,
+		FieldReceiver: data.Receiver,
+
+		FieldSenderAccount:   data.SenderAccount,
+		FieldReceiverAccount: data.ReceiverAccount,
+
+		FieldWalletPhone:   data.WalletPhone,
+		FieldMerchantID:    data.MerchantID,
+		FieldTransactionID: data.TransactionID,
+	}
+	if !data.FromQR {
+		values[FieldReference] = data.Reference
+	}
+
+	for field, value := range values {
+		if value == "" || data.Confidence[field] == 0 || data.FromRegion[field] {
+			continue
+		}
+		data.Confidence[field] *= result.wordConfidence(value, false)
+	}
+
+	// Amounts are matched on digits only, since "1,500.00" parses to 1500
+	if data.Amount > 0 && data.Confidence[FieldAmount] > 0 && !data.FromRegion[FieldAmount] {
+		data.Confidence[FieldAmount] *= result.wordConfidence(fmt.Sprintf("%.2f", data.Amount), true)
+	}
+}
+
+// OverallConfidence is the lowest confidence among RequiredFields
+func OverallConfidence(confidence map[string]float64) float64 {
+	overall := 1.0
+	for _, field := range RequiredFields {
+		if c := confidence[field]; c < overall {
+			overall = c
+		}
+	}
+	return overall
+}
+
+// wordConfidence averages the confidence (0-1) of the OCR words that make up
+// value, falling back to the page average when no word can be located.
+func (r *OCRResult) wordConfidence(value string, digitsOnly bool) float64 {
+	var total float64
+	var count int
+	if digitsOnly {
+		value = onlyDigits(value)
+	}
+	for _, token := range strings.Fields(value) {
+		for _, word := range r.Words {
+			w := strings.TrimSpace(word.Text)
+			if digitsOnly {
+				w = onlyDigits(w)
+			}
+			if w == "" {
+				continue
+			}
+			if strings.Contains(w, token) || (len(w) > 1 && strings.Contains(token, w)) {
+				total += word.Confidence
+				count++
+			}
+		}
+	}
+
+	if count == 0 {
+		return r.MeanConfidence() / 100
+	}
+	return total / float64(count) / 100
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"ocr-api/utils"
 )
 
 type ExtractedData struct {
@@ -16,322 +18,201 @@ type ExtractedData struct {
 	Bank      string
 	Sender    string
 	Receiver  string
+	BankCode  string // sending bank code, only available from the slip QR
+	Country   string // country code, only available from the slip QR
+	FromQR    bool   // Reference/Bank were taken from the slip QR
+
+	// Account numbers as printed, usually masked (xxx-x-x1234-x)
+	SenderAccount   string
+	ReceiverAccount string
+
+	// DateLocale is the date_locale of the template used; see utils.NormalizeDateLocale
+	DateLocale string
+
+	// Channel is bank, wallet or counter; empty when the slip was not recognised
+	Channel       string
+	WalletPhone   string // wallet owner's phone number, often masked (081-xxx-1234)
+	MerchantID    string
+	TransactionID string // wallet/counter transaction ID, also used as Reference when there is none
+
+	// Confidence holds a 0-1 score per field (see Field* constants)
+	Confidence map[string]float64
+
+	// FromRegion marks fields read from their layout region rather than
+	// matched in the full text
+	FromRegion map[string]bool
 }
 
-type BankPattern struct {
-	Name             string
-	Identifiers      []string 
-	AmountPatterns   []string
-	DatePatterns     []string
-	TimePatterns     []string
-	RefPatterns      []string
-	SenderPatterns   []string
-	ReceiverPatterns []string
+func ExtractData(ocrText string) (*ExtractedData, error) {
+	data, err := ExtractDataWithQR(ocrText, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Amount == 0 {
+		return nil, fmt.Errorf("failed to extract amount from OCR text")
+	}
+
+	return data, nil
 }
 
-var bankPatterns = []BankPattern{
-	{
-		Name: "SCB",
-		Identifiers: []string{
-			"(?i)siam\\s*commercial\\s*bank",
-			"(?i)scb",
-			"(?i)ธนาคารไทยพาณิชย์",
-		},
-		AmountPatterns: []string{
-			`(?i)(?:amount|จำนวนเงิน|ยอดเงิน|จํานวน)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`(?i)(?:THB|บาท)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`([0-9,]+\.\d{2})\s*(?:THB|บาท|BAHT)`,
-		},
-		DatePatterns: []string{
-			`(\d{1,2}\s+(?:ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+\d{2,4})`,
-			`(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-			`(?i)(?:date|วันที่)[:\s]*(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-		},
-		TimePatterns: []string{
-			`(\d{1,2}:\d{2}(?::\d{2})?)`,
-			`(?i)(?:time|เวลา)[:\s]*(\d{1,2}:\d{2}(?::\d{2})?)`,
-		},
-		RefPatterns: []string{
-			`(?i)(?:ref(?:erence)?|อ้างอิง|เลขที่อ้างอิง|เลขที่รายการ)[:\s#]*([A-Z0-9]+)`,
-			`(?i)transaction\s*(?:ref|id)[:\s]*([A-Z0-9]+)`,
-		},
-		SenderPatterns: []string{
-			`(?i)(?:from|จาก)[:\s]*([^\n]+)`,
-			`(?i)sender[:\s]*([^\n]+)`,
-		},
-		ReceiverPatterns: []string{
-			`(?i)(?:to|ถึง|ไปยัง)[:\s]*([^\n]+)`,
-			`(?i)(?:receiver|ผู้รับ)[:\s]*([^\n]+)`,
-		},
-	},
-	{
-		Name: "KBank",
-		Identifiers: []string{
-			"(?i)kasikorn\\s*bank",
-			"(?i)kbank",
-			"(?i)k-bank",
-			"(?i)ธนาคารกสิกรไทย",
-			"(?i)กสิกรไทย",
-		},
-		AmountPatterns: []string{
-			`(?i)(?:amount|จำนวนเงิน|ยอดเงิน|จํานวน)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`(?i)(?:THB|บาท)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`([0-9,]+\.\d{2})\s*(?:THB|บาท|BAHT)`,
-		},
-		DatePatterns: []string{
-			`(\d{1,2}\s+(?:ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+\d{2,4})`,
-			`(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-			`(?i)(?:date|วันที่)[:\s]*(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-		},
-		TimePatterns: []string{
-			`(\d{1,2}:\d{2}(?::\d{2})?)`,
-			`(?i)(?:time|เวลา)[:\s]*(\d{1,2}:\d{2}(?::\d{2})?)`,
-		},
-		RefPatterns: []string{
-			`(?i)(?:ref(?:erence)?|อ้างอิง|เลขที่อ้างอิง|เลขที่รายการ)[:\s#]*([A-Z0-9]+)`,
-			`(?i)transaction\s*(?:ref|no)[:\s]*([A-Z0-9]+)`,
-		},
-		SenderPatterns: []string{
-			`(?i)(?:from|จาก)[:\s]*([^\n]+)`,
-		},
-		ReceiverPatterns: []string{
-			`(?i)(?:to|ถึง)[:\s]*([^\n]+)`,
-		},
-	},
-	{
-		Name: "BBL",
-		Identifiers: []string{
-			"(?i)bangkok\\s*bank",
-			"(?i)bbl",
-			"(?i)ธนาคารกรุงเทพ",
-		},
-		AmountPatterns: []string{
-			`(?i)(?:amount|จำนวนเงิน|ยอดเงิน|จํานวน)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`(?i)(?:THB|บาท)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`([0-9,]+\.\d{2})\s*(?:THB|บาท|BAHT)`,
-		},
-		DatePatterns: []string{
-			`(\d{1,2}\s+(?:ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+\d{2,4})`,
-			`(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-			`(?i)(?:date|วันที่)[:\s]*(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-		},
-		TimePatterns: []string{
-			`(\d{1,2}:\d{2}(?::\d{2})?)`,
-		},
-		RefPatterns: []string{
-			`(?i)(?:ref(?:erence)?|อ้างอิง|เลขที่รายการ)[:\s#]*([A-Z0-9]+)`,
-		},
-		SenderPatterns: []string{
-			`(?i)(?:from|จาก)[:\s]*([^\n]+)`,
-		},
-		ReceiverPatterns: []string{
-			`(?i)(?:to|ถึง)[:\s]*([^\n]+)`,
-		},
-	},
-	{
-		Name: "KTB",
-		Identifiers: []string{
-			"(?i)krung\\s*thai\\s*bank",
-			"(?i)ktb",
-			"(?i)ธนาคารกรุงไทย",
-		},
-		AmountPatterns: []string{
-			`(?i)(?:amount|จำนวนเงิน|ยอดเงิน|จํานวน)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`(?i)(?:THB|บาท)[:\s]*([0-9,]+\.?\d{0,2})`,
-			`([0-9,]+\.\d{2})\s*(?:THB|บาท|BAHT)`,
-		},
-		DatePatterns: []string{
-			`(\d{1,2}\s+(?:ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+\d{2,4})`,
-			`(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`,
-		},
-		TimePatterns: []string{
-			`(\d{1,2}:\d{2}(?::\d{2})?)`,
-		},
-		RefPatterns: []string{
-			`(?i)(?:ref(?:erence)?|อ้างอิง|เลขที่รายการ)[:\s#]*([A-Z0-9]+)`,
-		},
-		SenderPatterns: []string{
-			`(?i)(?:from|จาก)[:\s]*([^\n]+)`,
-		},
-		ReceiverPatterns: []string{
-			`(?i)(?:to|ถึง)[:\s]*([^\n]+)`,
-		},
-	},
+// ExtractDataWithQR extracts slip fields from OCR text. When the slip QR was
+// decoded its bank and reference take priority over the regex matches.
+// Unlike ExtractData it returns partial results; see MissingFields.
+func ExtractDataWithQR(ocrText string, qr *SlipQR) (*ExtractedData, error) {
+	return ExtractDataWithRegions(ocrText, qr, nil)
 }
 
-func ExtractData(ocrText string) (*ExtractedData, error) {
+// ExtractDataWithRegions is ExtractDataWithQR with the values read from the
+// bank's layout regions (see ReadRegions) taking priority over the full-text
+// patterns. The slip QR still has the last word on bank and reference.
+func ExtractDataWithRegions(ocrText string, qr *SlipQR, regions []RegionReading) (*ExtractedData, error) {
 	if ocrText == "" {
 		return nil, fmt.Errorf("OCR text is empty")
 	}
 
-	data := &ExtractedData{}
+	data := &ExtractedData{Confidence: make(map[string]float64)}
+
+	if bankTemplates == nil {
+		return nil, fmt.Errorf("bank templates are not loaded")
+	}
 
-	data.Bank = detectBank(ocrText)
+	data.Bank = "Unknown"
+	template := bankTemplates.Detect(ocrText)
+	if template != nil {
+		data.Bank = template.Name
+		data.Confidence[FieldBank] = bankIdentifierScore
+	}
+	if qr != nil && qr.BankName() != "" {
+		data.Bank = qr.BankName()
+		template = bankTemplates.Lookup(data.Bank)
+	}
 	log.Printf("Detected bank: %s", data.Bank)
 
-	var patterns BankPattern
-	for _, bp := range bankPatterns {
-		if bp.Name == data.Bank {
-			patterns = bp
-			break
-		}
+	fallback := template == nil
+	if fallback {
+		template = bankTemplates.Fallback()
+	} else {
+		data.Channel = template.Channel
 	}
-	if patterns.Name == "" {
-		patterns = bankPatterns[0] 
+	if qr != nil {
+		data.Channel = ChannelBank // only bank e-slips carry the verification QR
 	}
+	if template == nil {
+		return data, nil
+	}
+	data.DateLocale = template.DateLocale
 
-	data.Amount = extractAmount(ocrText, patterns.AmountPatterns)
+	var idx int
+	data.Amount, idx = template.MatchAmount(ocrText)
+	data.Confidence[FieldAmount] = patternScore(idx, fallback)
 
-	data.Date = extractField(ocrText, patterns.DatePatterns)
+	data.Date, idx = template.MatchField(FieldDate, ocrText)
+	data.Confidence[FieldDate] = patternScore(idx, fallback)
 
-	data.Time = extractField(ocrText, patterns.TimePatterns)
+	data.Time, idx = template.MatchField(FieldTime, ocrText)
+	data.Confidence[FieldTime] = patternScore(idx, fallback)
 
-	data.Reference = extractField(ocrText, patterns.RefPatterns)
+	data.Reference, idx = template.MatchField(FieldReference, ocrText)
+	data.Confidence[FieldReference] = patternScore(idx, fallback)
 
-	data.Sender = extractField(ocrText, patterns.SenderPatterns)
+	data.Sender, idx = template.MatchField(FieldSender, ocrText)
+	data.Confidence[FieldSender] = patternScore(idx, fallback)
 
-	data.Receiver = extractField(ocrText, patterns.ReceiverPatterns)
+	data.Receiver, idx = template.MatchField(FieldReceiver, ocrText)
+	data.Confidence[FieldReceiver] = patternScore(idx, fallback)
 
-	if data.Amount == 0 {
-		return nil, fmt.Errorf("failed to extract amount from OCR text")
+	data.SenderAccount, idx = template.MatchField(FieldSenderAccount, ocrText)
+	data.Confidence[FieldSenderAccount] = patternScore(idx, fallback)
+
+	data.ReceiverAccount, idx = template.MatchField(FieldReceiverAccount, ocrText)
+	data.Confidence[FieldReceiverAccount] = patternScore(idx, fallback)
+
+	data.WalletPhone, idx = template.MatchField(FieldWalletPhone, ocrText)
+	data.Confidence[FieldWalletPhone] = patternScore(idx, fallback)
+
+	data.MerchantID, idx = template.MatchField(FieldMerchantID, ocrText)
+	data.Confidence[FieldMerchantID] = patternScore(idx, fallback)
+
+	data.TransactionID, idx = template.MatchField(FieldTransactionID, ocrText)
+	data.Confidence[FieldTransactionID] = patternScore(idx, fallback)
+
+	applyRegions(data, regions)
+
+	// Wallet and counter slips have no bank reference; their transaction ID
+	// serves the same purpose for duplicate detection
+	if data.Reference == "" && data.TransactionID != "" {
+		data.Reference = data.TransactionID
+		data.Confidence[FieldReference] = data.Confidence[FieldTransactionID]
+	}
+
+	if qr != nil {
+		applySlipQR(data, qr)
 	}
 
 	return data, nil
 }
 
-func detectBank(text string) string {
-	for _, bp := range bankPatterns {
-		for _, identifier := range bp.Identifiers {
-			re := regexp.MustCompile(identifier)
-			if re.MatchString(text) {
-				return bp.Name
-			}
-		}
+// MissingFields lists the RequiredFields that could not be extracted
+func (d *ExtractedData) MissingFields() []string {
+	var missing []string
+	if d.Amount == 0 {
+		missing = append(missing, FieldAmount)
+	}
+	if d.Date == "" {
+		missing = append(missing, FieldDate)
+	}
+	return missing
+}
+
+func applySlipQR(data *ExtractedData, qr *SlipQR) {
+	if data.Reference != "" && data.Reference != qr.TransactionRef {
+		log.Printf("QR reference %s overrides OCR reference %s", qr.TransactionRef, data.Reference)
+	}
+	data.Reference = qr.TransactionRef
+	data.BankCode = qr.BankCode
+	data.Country = qr.Country
+	data.FromQR = true
+
+	// QR payloads are checksummed, so these are as good as it gets
+	data.Confidence[FieldReference] = 1
+	if qr.BankName() != "" {
+		data.Confidence[FieldBank] = 1
 	}
-	return "Unknown"
 }
 
-func extractAmount(text string, patterns []string) float64 {
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
+// extractAmount returns the amount and the index of the pattern that matched, or -1
+func extractAmount(text string, patterns []*regexp.Regexp) (float64, int) {
+	for i, re := range patterns {
 		matches := re.FindStringSubmatch(text)
 		if len(matches) > 1 {
 			// Remove commas and parse
 			amountStr := strings.ReplaceAll(matches[1], ",", "")
 			amount, err := strconv.ParseFloat(amountStr, 64)
 			if err == nil && amount > 0 {
-				log.Printf("Extracted amount: %.2f using pattern: %s", amount, pattern)
-				return amount
+				log.Printf("Extracted amount: %.2f using pattern: %s", amount, re)
+				return amount, i
 			}
 		}
 	}
-	return 0
+	return 0, -1
 }
 
-func extractField(text string, patterns []string) string {
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
+// extractField returns the first non-empty match and the index of its pattern, or -1
+func extractField(text string, patterns []*regexp.Regexp) (string, int) {
+	for i, re := range patterns {
 		matches := re.FindStringSubmatch(text)
 		if len(matches) > 1 {
 			result := strings.TrimSpace(matches[1])
 			if result != "" {
-				log.Printf("Extracted field: %s using pattern: %s", result, pattern)
-				return result
+				log.Printf("Extracted field: %s using pattern: %s", result, re)
+				return result, i
 			}
 		}
 	}
-	return ""
+	return "", -1
 }
 
 func NormalizeDate(dateStr string) string {
-	if dateStr == "" {
-		return ""
-	}
-
-	dateStr = strings.TrimSpace(dateStr)
-
-	// Thai month abbreviation map
-	thaiMonths := map[string]string{
-		"ม.ค.":  "01", // มกราคม
-		"ก.พ.":  "02", // กุมภาพันธ์
-		"มี.ค.": "03", // มีนาคม
-		"เม.ย.": "04", // เมษายน
-		"พ.ค.":  "05", // พฤษภาคม
-		"มิ.ย.": "06", // มิถุนายน
-		"ก.ค.":  "07", // กรกฎาคม
-		"ส.ค.":  "08", // สิงหาคม
-		"ก.ย.":  "09", // กันยายน
-		"ต.ค.":  "10", // ตุลาคม
-		"พ.ย.":  "11", // พฤศจิกายน
-		"ธ.ค.":  "12", // ธันวาคม
-	}
-
-	// Try Thai date format first (e.g., "23 พ.ย. 68")
-	reThai := regexp.MustCompile(`(\d{1,2})\s+(ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+(\d{2,4})`)
-	matchesThai := reThai.FindStringSubmatch(dateStr)
-
-	if len(matchesThai) == 4 {
-		day := matchesThai[1]
-		monthAbbr := matchesThai[2]
-		year := matchesThai[3]
-
-		month, exists := thaiMonths[monthAbbr]
-		if !exists {
-			month = "01" // default
-		}
-
-		if len(day) == 1 {
-			day = "0" + day
-		}
-
-		// Convert Thai Buddhist year to Western year
-		if len(year) == 2 {
-			yearInt, _ := strconv.Atoi(year)
-			// Thai Buddhist calendar is 543 years ahead
-			// 68 (2568 BE) = 2025 CE
-			if yearInt < 100 {
-				yearInt += 2500 // 68 + 2500 = 2568
-				yearInt -= 543  // 2568 - 543 = 2025
-			}
-			year = fmt.Sprintf("%04d", yearInt)
-		} else if len(year) == 4 {
-			yearInt, _ := strconv.Atoi(year)
-			if yearInt > 2400 { // Likely Buddhist year
-				yearInt -= 543
-			}
-			year = fmt.Sprintf("%04d", yearInt)
-		}
-
-		return fmt.Sprintf("%s/%s/%s", day, month, year)
-	}
-
-	// Try numeric date format (DD/MM/YYYY or DD-MM-YYYY)
-	re := regexp.MustCompile(`(\d{1,2})[/-](\d{1,2})[/-](\d{2,4})`)
-	matches := re.FindStringSubmatch(dateStr)
-
-	if len(matches) == 4 {
-		day := matches[1]
-		month := matches[2]
-		year := matches[3]
-
-		if len(day) == 1 {
-			day = "0" + day
-		}
-		if len(month) == 1 {
-			month = "0" + month
-		}
-
-		if len(year) == 2 {
-			yearInt, _ := strconv.Atoi(year)
-			if yearInt > 50 {
-				year = "19" + year
-			} else {
-				year = "20" + year
-			}
-		}
-
-		return fmt.Sprintf("%s/%s/%s", day, month, year)
-	}
-
-	return dateStr
+	return utils.NormalizeDateLocale(dateStr, "")
 }
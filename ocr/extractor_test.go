@@ -0,0 +1,13 @@
+package ocr
+
+import (
+	"testing"
+
+	"ocr-api/utils"
+)
+
+func TestValidDateAcceptsBENumericSlips(t *testing.T) {
+	if !validDate(utils.NormalizeDateLocale("23/11/2568", "")) {
+		t.Error("BE numeric date 23/11/2568 should normalize to a valid date")
+	}
+}
@@ -0,0 +1,286 @@
+package ocr
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// toGray returns img as 8-bit grayscale, without copying if it already is
+func toGray(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok && g.Bounds().Min == (image.Point{}) {
+		return g
+	}
+	b := img.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(g, g.Bounds(), img, b.Min, draw.Src)
+	return g
+}
+
+// otsuThreshold picks the gray level that best separates ink from paper
+func otsuThreshold(g *image.Gray) uint8 {
+	var hist [256]int
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+
+	total := len(g.Pix)
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+
+	var sumBack float64
+	var back int
+	var best float64
+	var threshold uint8
+	for i, n := range hist {
+		back += n
+		if back == 0 {
+			continue
+		}
+		front := total - back
+		if front == 0 {
+			break
+		}
+		sumBack += float64(i * n)
+		meanBack := sumBack / float64(back)
+		meanFront := (sum - sumBack) / float64(front)
+		between := float64(back) * float64(front) * (meanBack - meanFront) * (meanBack - meanFront)
+		if between > best {
+			best = between
+			threshold = uint8(i)
+		}
+	}
+	return threshold
+}
+
+// binarizeOtsu turns pixels at or below the Otsu threshold black and the rest white
+func binarizeOtsu(g *image.Gray) (*image.Gray, uint8) {
+	threshold := otsuThreshold(g)
+	out := image.NewGray(g.Bounds())
+	for i, p := range g.Pix {
+		if p > threshold {
+			out.Pix[i] = 255
+		}
+	}
+	return out, threshold
+}
+
+// binarizeAdaptive compares each pixel with the mean of the window around it
+// (Bradley's method), so shadows and uneven lighting across a photo do not
+// swallow the text. A pixel turns black when it is offset percent darker
+// than its window.
+func binarizeAdaptive(g *image.Gray, window int, offset float64) *image.Gray {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+
+	// Integral image, one row and column larger so sums need no bounds checks
+	stride := w + 1
+	integral := make([]int64, stride*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(g.Pix[y*g.Stride+x])
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + row
+		}
+	}
+
+	half := window / 2
+	factor := 1 - offset/100
+	out := image.NewGray(g.Bounds())
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-half, 0), min(y+half+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-half, 0), min(x+half+1, w)
+			sum := integral[y1*stride+x1] - integral[y0*stride+x1] - integral[y1*stride+x0] + integral[y0*stride+x0]
+			count := int64((y1 - y0) * (x1 - x0))
+			if float64(int64(g.Pix[y*g.Stride+x])*count) > float64(sum)*factor {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
+
+// medianFilter replaces each pixel with the median of the window around it,
+// removing speckle while keeping the edges of characters
+func medianFilter(g *image.Gray, window int) *image.Gray {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	half := window / 2
+	out := image.NewGray(g.Bounds())
+	values := make([]uint8, 0, window*window)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			values = values[:0]
+			for yy := max(y-half, 0); yy < min(y+half+1, h); yy++ {
+				row := g.Pix[yy*g.Stride:]
+				for xx := max(x-half, 0); xx < min(x+half+1, w); xx++ {
+					values = append(values, row[xx])
+				}
+			}
+			// Insertion sort; windows are a handful of pixels
+			for i := 1; i < len(values); i++ {
+				for j := i; j > 0 && values[j] < values[j-1]; j-- {
+					values[j], values[j-1] = values[j-1], values[j]
+				}
+			}
+			out.Pix[y*out.Stride+x] = values[len(values)/2]
+		}
+	}
+	return out
+}
+
+// skewSampleWidth is the width images are scaled down to for estimating skew
+const skewSampleWidth = 1000
+
+// estimateSkew finds the angle, within ±maxAngle degrees, that lines the
+// text up with the rows of the image. Text rows rotated level make the
+// horizontal projection profile (dark pixels per row) peaky, so the angle
+// whose profile has the largest differences between neighbouring rows wins.
+// The result is the counter-clockwise rotation that straightens the image.
+func estimateSkew(g *image.Gray, maxAngle float64) float64 {
+	sample := g
+	if g.Bounds().Dx() > skewSampleWidth {
+		sample = toGray(imaging.Resize(g, skewSampleWidth, 0, imaging.Box))
+	}
+	threshold := otsuThreshold(sample)
+
+	w, h := sample.Bounds().Dx(), sample.Bounds().Dy()
+	var xs, ys []float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if sample.Pix[y*sample.Stride+x] <= threshold {
+				xs = append(xs, float64(x))
+				ys = append(ys, float64(y))
+			}
+		}
+	}
+	// Nothing but ink or nothing but paper has no lines to level
+	if len(xs) == 0 || len(xs) > w*h/2 {
+		return 0
+	}
+
+	diagonal := int(math.Hypot(float64(w), float64(h))) + 2
+	profile := make([]int, 2*diagonal)
+	score := func(angle float64) float64 {
+		for i := range profile {
+			profile[i] = 0
+		}
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		for i := range xs {
+			row := int(ys[i]*cos+xs[i]*sin) + diagonal
+			profile[row]++
+		}
+		var s float64
+		for i := 1; i < len(profile); i++ {
+			d := float64(profile[i] - profile[i-1])
+			s += d * d
+		}
+		return s
+	}
+
+	// Coarse search, then refine around the best coarse angle
+	best, bestScore := 0.0, score(0)
+	for angle := -maxAngle; angle <= maxAngle; angle += 0.5 {
+		if s := score(angle); s > bestScore {
+			best, bestScore = angle, s
+		}
+	}
+	coarse := best
+	for angle := coarse - 0.5; angle <= coarse+0.5; angle += 0.1 {
+		if s := score(angle); s > bestScore {
+			best, bestScore = angle, s
+		}
+	}
+	// The profile levels the text by turning it back by best
+	return -math.Round(best*10) / 10
+}
+
+// slipBounds finds the slip in a photo by comparing pixels with the
+// background, taken as the median of the image's outer frame. Rows and
+// columns with enough pixels unlike the background are the slip. It reports
+// false when the slip could not be told from the background or already fills
+// the image.
+func slipBounds(g *image.Gray, margin int) (image.Rectangle, bool) {
+	const tolerance = 48     // gray levels a pixel must differ from the background by
+	const minContent = 0.005 // share of a row or column that must differ
+
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	background := int(backgroundLevel(g))
+
+	rows := make([]int, h)
+	cols := make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := int(g.Pix[y*g.Stride+x]) - background
+			if d > tolerance || d < -tolerance {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+
+	first, last := contentSpan(rows, max(int(float64(w)*minContent), 2))
+	left, right := contentSpan(cols, max(int(float64(h)*minContent), 2))
+	if first > last || left > right {
+		return image.Rectangle{}, false
+	}
+
+	bounds := image.Rect(left-margin, first-margin, right+margin+1, last+margin+1).Intersect(g.Bounds())
+	area := bounds.Dx() * bounds.Dy()
+	if bounds == g.Bounds() || area < w*h/10 {
+		return image.Rectangle{}, false
+	}
+	return bounds, true
+}
+
+// backgroundLevel is the median gray level of the image's outer frame
+func backgroundLevel(g *image.Gray) uint8 {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	frame := max(min(w, h)/50, 1)
+
+	var hist [256]int
+	var count int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if y >= frame && y < h-frame && x >= frame && x < w-frame {
+				x = w - frame - 1 // skip to the right edge
+				continue
+			}
+			hist[g.Pix[y*g.Stride+x]]++
+			count++
+		}
+	}
+
+	var seen int
+	for level, n := range hist {
+		if seen += n; seen > count/2 {
+			return uint8(level)
+		}
+	}
+	return 255
+}
+
+// contentSpan returns the first and last index whose count reaches threshold
+func contentSpan(counts []int, threshold int) (int, int) {
+	first, last := len(counts), -1
+	for i, n := range counts {
+		if n >= threshold {
+			if i < first {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last
+}
+
+// rotate turns img counter-clockwise by angle degrees, filling the corners
+// with the background so they do not read as content
+func rotate(img image.Image, angle float64) image.Image {
+	background := backgroundLevel(toGray(img))
+	return imaging.Rotate(img, angle, color.Gray{Y: background})
+}
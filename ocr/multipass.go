@@ -0,0 +1,349 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocr-api/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/otiai10/gosseract/v2"
+)
+
+// OCRPass is one way of reading a slip in multi-pass OCR
+type OCRPass struct {
+	Name        string
+	Raw         bool // read the image as uploaded instead of preprocessed
+	PageSegMode gosseract.PageSegMode
+}
+
+// SlipPasses are tried in order until one reads the slip completely. The
+// first is the single-pass reading.
+var SlipPasses = []OCRPass{
+	{Name: "preprocessed/auto", PageSegMode: gosseract.PSM_AUTO},
+	{Name: "preprocessed/single_column", PageSegMode: gosseract.PSM_SINGLE_COLUMN},
+	{Name: "raw/auto", Raw: true, PageSegMode: gosseract.PSM_AUTO},
+	{Name: "preprocessed/sparse", PageSegMode: gosseract.PSM_SPARSE_TEXT},
+	{Name: "raw/single_column", Raw: true, PageSegMode: gosseract.PSM_SINGLE_COLUMN},
+}
+
+// AmountPass names the digit-only reading of the amount region
+const AmountPass = "amount/digits"
+
+// amountWhitelist is what amounts are written with
+const amountWhitelist = "0123456789.,"
+
+// amountLabels find the amount region when the text passes found no amount
+var amountLabels = []string{"จำนวนเงิน", "ยอดเงิน", "ยอดชำระ", "amount", "total", "บาท", "thb"}
+
+var twoDecimalAmount = regexp.MustCompile(`\d[\d,]*\.\d{2}`)
+
+// Attempt is one OCR pass over a slip and what was extracted from it
+type Attempt struct {
+	Pass       string   `json:"pass"`
+	Score      float64  `json:"score"`
+	Winner     bool     `json:"winner,omitempty"`
+	Amount     float64  `json:"amount,omitempty"`
+	Date       string   `json:"date,omitempty"`
+	Reference  string   `json:"reference,omitempty"`
+	Missing    []string `json:"missing,omitempty"`
+	Characters int      `json:"characters"`
+	DurationMS float64  `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+
+	Result *OCRResult     `json:"-"`
+	Data   *ExtractedData `json:"-"`
+
+	raw bool // read from the image as uploaded
+}
+
+// Diagnostics records how a slip was read, for debugging poor readings
+type Diagnostics struct {
+	Preprocessing *PreprocessReport `json:"preprocessing,omitempty"`
+	Regions       []RegionReading   `json:"regions,omitempty"`
+	Attempts      []Attempt         `json:"attempts,omitempty"`
+	DurationMS    float64           `json:"duration_ms"`
+}
+
+// SlipReader runs multi-pass OCR on a slip within a time budget
+type SlipReader struct {
+	Raw          image.Image // slip as uploaded
+	Preprocessed image.Image
+	Lang         string
+	QR           *SlipQR
+	Regions      []RegionReading // applied to every pass; see ExtractDataWithRegions
+	Budget       time.Duration   // for the whole slip, from Started
+	Started      time.Time
+}
+
+// Read scores first, the single-pass reading, then runs the other
+// SlipPasses until one reads the slip completely or the budget would run
+// out. The best scoring attempt is the winner; its amount is then read again
+// with only digits allowed. It returns the winner, or nil when every pass
+// failed, and all attempts in the order they ran.
+func (r *SlipReader) Read(ctx context.Context, first *OCRResult, firstDuration time.Duration) (*Attempt, []Attempt) {
+	attempts := []Attempt{r.evaluate(SlipPasses[0], first, firstDuration)}
+	passTime, passesRun := firstDuration, 1
+
+	for _, pass := range SlipPasses[1:] {
+		if best := bestAttempt(attempts); best >= 0 && completeReading(attempts[best].Data, attempts[best].Result) {
+			break
+		}
+		// Passes cannot be interrupted, so one that would likely overrun is not started
+		average := passTime / time.Duration(passesRun)
+		if time.Since(r.Started)+average > r.Budget {
+			attempts = append(attempts, Attempt{Pass: pass.Name, Error: "skipped: time budget"})
+			continue
+		}
+
+		started := time.Now()
+		img := r.Preprocessed
+		if pass.Raw {
+			img = r.Raw
+		}
+		result, err := PerformOCRWith(ctx, img, r.Lang, OCROptions{PageSegMode: pass.PageSegMode})
+		duration := time.Since(started)
+		passTime += duration
+		passesRun++
+		if err != nil {
+			attempts = append(attempts, Attempt{Pass: pass.Name, Error: err.Error(), DurationMS: milliseconds(duration)})
+			continue
+		}
+		attempts = append(attempts, r.evaluate(pass, result, duration))
+	}
+
+	best := bestAttempt(attempts)
+	if best < 0 {
+		return nil, attempts
+	}
+	attempts[best].Winner = true
+
+	switch {
+	case attempts[best].Data.FromRegion[FieldAmount]:
+		// Already read from its region with only digits allowed
+	case time.Since(r.Started) < r.Budget:
+		if amount := r.readAmount(ctx, &attempts[best]); amount != nil {
+			attempts = append(attempts, *amount)
+		}
+	default:
+		attempts = append(attempts, Attempt{Pass: AmountPass, Error: "skipped: time budget"})
+	}
+
+	for _, a := range attempts {
+		log.Printf("OCR pass %s: score %.2f, %d characters, %.0fms%s", a.Pass, a.Score, a.Characters, a.DurationMS, attemptNote(a))
+	}
+	return &attempts[best], attempts
+}
+
+func attemptNote(a Attempt) string {
+	switch {
+	case a.Error != "":
+		return " (" + a.Error + ")"
+	case a.Winner:
+		return " (winner)"
+	}
+	return ""
+}
+
+// evaluate extracts and scores the fields of one reading
+func (r *SlipReader) evaluate(pass OCRPass, result *OCRResult, duration time.Duration) Attempt {
+	attempt := Attempt{
+		Pass:       pass.Name,
+		Characters: len(result.Text),
+		DurationMS: milliseconds(duration),
+		Result:     result,
+		raw:        pass.Raw,
+	}
+
+	data, err := ExtractDataWithRegions(result.Text, r.QR, r.Regions)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	ScoreConfidence(data, result)
+
+	attempt.Data = data
+	attempt.Score = ScoreReading(data, result)
+	attempt.Amount = data.Amount
+	attempt.Date = data.Date
+	attempt.Reference = data.Reference
+	attempt.Missing = data.MissingFields()
+	return attempt
+}
+
+// bestAttempt is the index of the highest scoring attempt, or -1; earlier
+// attempts win ties
+func bestAttempt(attempts []Attempt) int {
+	best := -1
+	for i, a := range attempts {
+		if a.Data != nil && (best < 0 || a.Score > attempts[best].Score) {
+			best = i
+		}
+	}
+	return best
+}
+
+// ScoreReading rates how well a slip was read. Required fields count most,
+// then whether they are consistent: the date is a real date and the amount
+// is printed with two decimals. Other fields add a little, and Tesseract's
+// mean word confidence breaks ties.
+func ScoreReading(data *ExtractedData, result *OCRResult) float64 {
+	var score float64
+	if data.Amount > 0 {
+		score += 3
+		if amountHasDecimals(result.Text, data.Amount) {
+			score++
+		}
+	}
+	if data.Date != "" {
+		score += 3
+		if validDate(utils.NormalizeDateLocale(data.Date, data.DateLocale)) {
+			score++
+		}
+	}
+	if validClock(data.Time) {
+		score += 0.5
+	}
+	if data.Reference != "" {
+		score++
+	}
+	if data.Bank != "" && data.Bank != "Unknown" {
+		score += 0.5
+	}
+	if data.Sender != "" {
+		score += 0.25
+	}
+	if data.Receiver != "" {
+		score += 0.25
+	}
+	return score + result.MeanConfidence()/200
+}
+
+// completeReading is a reading no other pass can improve on: required fields
+// found and consistent, and the reference read
+func completeReading(data *ExtractedData, result *OCRResult) bool {
+	return data.Amount > 0 && amountHasDecimals(result.Text, data.Amount) &&
+		validDate(utils.NormalizeDateLocale(data.Date, data.DateLocale)) &&
+		data.Reference != ""
+}
+
+// amountHasDecimals reports whether text shows amount with two decimals,
+// with or without thousands separators
+func amountHasDecimals(text string, amount float64) bool {
+	plain := strconv.FormatFloat(amount, 'f', 2, 64)
+	return strings.Contains(text, plain) || strings.Contains(text, groupThousands(plain))
+}
+
+// groupThousands adds commas to a number formatted with two decimals
+func groupThousands(plain string) string {
+	whole, decimals := plain[:len(plain)-3], plain[len(plain)-3:]
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String() + decimals
+}
+
+// validDate reports whether a normalized DD/MM/YYYY date is a real, recent one
+func validDate(date string) bool {
+	t, err := time.Parse("02/01/2006", date)
+	return err == nil && t.Year() >= 2000 && t.Year() <= time.Now().Year()+1
+}
+
+func validClock(clock string) bool {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if _, err := time.Parse(layout, clock); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readAmount crops the amount from the image the winner was read from and
+// reads it again with only digits allowed, which avoids letters read in
+// place of digits (O for 0, l for 1). The amount region is where the winner
+// read its amount, or else the line of an amount label. A two-decimal amount
+// read this way replaces the winner's.
+func (r *SlipReader) readAmount(ctx context.Context, winner *Attempt) *Attempt {
+	region, ok := amountRegion(winner.Data.Amount, winner.Result.Words)
+	if !ok {
+		return nil
+	}
+	img := r.Preprocessed
+	if winner.raw {
+		img = r.Raw
+	}
+	region = region.Intersect(img.Bounds())
+	if region.Empty() {
+		return nil
+	}
+
+	started := time.Now()
+	result, err := PerformOCRWith(ctx, imaging.Crop(img, region), r.Lang, OCROptions{
+		PageSegMode: gosseract.PSM_SINGLE_LINE,
+		Whitelist:   amountWhitelist,
+	})
+	attempt := &Attempt{Pass: AmountPass, DurationMS: milliseconds(time.Since(started))}
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	attempt.Characters = len(result.Text)
+
+	match := twoDecimalAmount.FindString(result.Text)
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	if match == "" || err != nil || amount <= 0 {
+		attempt.Error = "no amount read"
+		return attempt
+	}
+	attempt.Amount = amount
+
+	data := winner.Data
+	if amount != data.Amount {
+		log.Printf("Digit-only pass read amount %.2f, replacing %.2f", amount, data.Amount)
+		data.Amount = amount
+		data.Confidence[FieldAmount] = patternScore(0, false)
+		if result.MeanConfidence() > 0 {
+			data.Confidence[FieldAmount] *= result.MeanConfidence() / 100
+		}
+		winner.Amount = amount
+		winner.Missing = data.MissingFields()
+	}
+	return attempt
+}
+
+// amountRegion is the box around the word showing amount, or else the line
+// of the first amount label and the line below it
+func amountRegion(amount float64, words []OCRWord) (image.Rectangle, bool) {
+	if amount > 0 {
+		plain := strconv.FormatFloat(amount, 'f', 2, 64)
+		for _, w := range words {
+			digits := strings.ReplaceAll(w.Text, ",", "")
+			if !w.Box.Empty() && strings.Contains(digits, plain) {
+				return padRegion(w.Box, w.Box.Dy()/2, w.Box.Dy()), true
+			}
+		}
+	}
+	for _, w := range words {
+		lower := strings.ToLower(w.Text)
+		for _, label := range amountLabels {
+			if !w.Box.Empty() && strings.Contains(lower, label) {
+				line := image.Rect(0, w.Box.Min.Y, 1<<20, w.Box.Max.Y+2*w.Box.Dy())
+				return padRegion(line, w.Box.Dy()/2, 0), true
+			}
+		}
+	}
+	return image.Rectangle{}, false
+}
+
+func padRegion(r image.Rectangle, vertical, horizontal int) image.Rectangle {
+	return image.Rect(r.Min.X-horizontal, r.Min.Y-vertical, r.Max.X+horizontal, r.Max.Y+vertical)
+}
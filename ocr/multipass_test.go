@@ -0,0 +1,89 @@
+package ocr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGroupThousands(t *testing.T) {
+	tests := []struct {
+		plain string
+		want  string
+	}{
+		{plain: "0.50", want: "0.50"},
+		{plain: "999.00", want: "999.00"},
+		{plain: "1000.00", want: "1,000.00"},
+		{plain: "12345.67", want: "12,345.67"},
+		{plain: "123456.00", want: "123,456.00"},
+		{plain: "1234567.89", want: "1,234,567.89"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plain, func(t *testing.T) {
+			if got := groupThousands(tt.plain); got != tt.want {
+				t.Errorf("groupThousands(%q) = %q, want %q", tt.plain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreReading(t *testing.T) {
+	complete := ExtractedData{
+		Amount:    1250.50,
+		Date:      "23/11/2025",
+		Time:      "14:05",
+		Reference: "0123456789ABCD",
+		Bank:      "KBank",
+		Sender:    "นาย สมชาย ใจดี",
+		Receiver:  "7-Eleven",
+	}
+	words := []OCRWord{{Confidence: 80}, {Confidence: 100}}
+
+	tests := []struct {
+		name   string
+		data   ExtractedData
+		text   string
+		words  []OCRWord
+		want   float64
+		change func(*ExtractedData)
+	}{
+		{name: "nothing read", want: 0},
+		{name: "every field, consistent", data: complete, text: "1,250.50", words: words, want: 10.5 + 90.0/200},
+		{name: "amount without thousands separator", data: complete, text: "1250.50", want: 10.5},
+		{name: "amount not printed with decimals", data: complete, text: "1250.5", want: 9.5},
+		{name: "impossible date", data: complete, text: "1,250.50", want: 9.5,
+			change: func(d *ExtractedData) { d.Date = "31/02/2025" }},
+		{name: "Buddhist-era date", data: complete, text: "1,250.50", want: 10.5,
+			change: func(d *ExtractedData) { d.Date = "23/11/2568" }},
+		{name: "invalid time", data: complete, text: "1,250.50", want: 10,
+			change: func(d *ExtractedData) { d.Time = "25:61" }},
+		{name: "unknown bank", data: complete, text: "1,250.50", want: 10,
+			change: func(d *ExtractedData) { d.Bank = "Unknown" }},
+		{name: "no amount", data: complete, text: "1,250.50", want: 6.5,
+			change: func(d *ExtractedData) { d.Amount = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if tt.change != nil {
+				tt.change(&data)
+			}
+			got := ScoreReading(&data, &OCRResult{Text: tt.text, Words: tt.words})
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ScoreReading() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreReadingPrefersRequiredFields(t *testing.T) {
+	// Every optional field at full confidence is worth less than the amount
+	optional := ScoreReading(&ExtractedData{
+		Date: "23/11/2025", Time: "14:05", Reference: "REF", Bank: "KBank", Sender: "A", Receiver: "B",
+	}, &OCRResult{Words: []OCRWord{{Confidence: 100}}})
+	required := ScoreReading(&ExtractedData{Amount: 100, Date: "23/11/2025"}, &OCRResult{Text: "100.00"})
+	if optional >= required {
+		t.Errorf("reading without amount scored %v, not below %v for one with amount and date", optional, required)
+	}
+}
@@ -0,0 +1,57 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PDF handling shells out to poppler-utils (pdftotext, pdftoppm), the same way
+// OCR relies on a system Tesseract install.
+
+// ExtractPDFText returns the text layer of each page, keeping the column
+// layout so statement rows stay on one line. Scanned pages come back empty.
+func ExtractPDFText(pdfPath string) ([]string, error) {
+	cmd := exec.Command("pdftotext", "-layout", "-enc", "UTF-8", pdfPath, "-")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftotext failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// pdftotext ends every page with a form feed
+	pages := strings.Split(stdout.String(), "\f")
+	if len(pages) > 1 && strings.TrimSpace(pages[len(pages)-1]) == "" {
+		pages = pages[:len(pages)-1]
+	}
+	return pages, nil
+}
+
+// RasterizePDFPage renders one page (1-based) to a JPEG next to the PDF for OCR
+func RasterizePDFPage(pdfPath string, page int) (string, error) {
+	ext := filepath.Ext(pdfPath)
+	prefix := fmt.Sprintf("%s_page%d", strings.TrimSuffix(pdfPath, ext), page)
+
+	cmd := exec.Command("pdftoppm",
+		"-f", strconv.Itoa(page), "-l", strconv.Itoa(page),
+		"-r", "300", "-gray", "-jpeg", "-singlefile",
+		pdfPath, prefix)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pdftoppm failed on page %d: %w: %s", page, err, strings.TrimSpace(stderr.String()))
+	}
+
+	imagePath := prefix + ".jpg"
+	if _, err := os.Stat(imagePath); err != nil {
+		return "", fmt.Errorf("pdftoppm produced no image for page %d: %w", page, err)
+	}
+	return imagePath, nil
+}
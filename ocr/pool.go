@@ -0,0 +1,198 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// clientKey is what a Tesseract client was set up with. Changing the language
+// or a variable such as the whitelist makes a client load its traineddata
+// again, so clients are only reused for reads with the same settings.
+type clientKey struct {
+	lang        string
+	pageSegMode gosseract.PageSegMode
+	whitelist   string
+}
+
+type idleClient struct {
+	key    clientKey
+	client *gosseract.Client
+}
+
+// ClientPool keeps Tesseract clients between reads and bounds how many reads
+// run at once. Reads beyond the limit queue until a slot frees up or their
+// context is done.
+type ClientPool struct {
+	slots chan struct{}
+
+	mu      sync.Mutex
+	idle    []idleClient // oldest first, at most cap(slots)
+	waiting int
+	stats   PoolStats
+}
+
+// PoolStats are counters of a ClientPool since it was created
+type PoolStats struct {
+	MaxConcurrency int     `json:"max_concurrency"`
+	Active         int     `json:"active"`
+	Waiting        int     `json:"waiting"`
+	IdleClients    int     `json:"idle_clients"`
+	ClientsCreated int     `json:"clients_created"`
+	Reads          int     `json:"reads"`
+	Failed         int     `json:"failed"`
+	Canceled       int     `json:"canceled"` // gave up while queued
+	WaitMeanMS     float64 `json:"wait_mean_ms"`
+	WaitMaxMS      float64 `json:"wait_max_ms"`
+	OCRMeanMS      float64 `json:"ocr_mean_ms"`
+	OCRMaxMS       float64 `json:"ocr_max_ms"`
+
+	waitTotal time.Duration
+	ocrTotal  time.Duration
+}
+
+// NewClientPool creates a pool running at most maxConcurrency reads at once;
+// values below 1 use the number of CPUs
+func NewClientPool(maxConcurrency int) *ClientPool {
+	if maxConcurrency < 1 {
+		maxConcurrency = runtime.NumCPU()
+	}
+	return &ClientPool{
+		slots: make(chan struct{}, maxConcurrency),
+		stats: PoolStats{MaxConcurrency: maxConcurrency},
+	}
+}
+
+// ocrPool is the pool every OCR read goes through
+var ocrPool = NewClientPool(0)
+
+// InitClientPool replaces the OCR client pool with one running at most
+// maxConcurrency reads at once. Call it before any OCR runs.
+func InitClientPool(maxConcurrency int) {
+	ocrPool = NewClientPool(maxConcurrency)
+}
+
+// ClientPoolStats returns the OCR client pool's counters
+func ClientPoolStats() PoolStats {
+	return ocrPool.Stats()
+}
+
+// Stats returns a snapshot of the pool's counters
+func (p *ClientPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Waiting = p.waiting
+	stats.IdleClients = len(p.idle)
+	if started := stats.Reads + stats.Failed; started > 0 {
+		stats.WaitMeanMS = milliseconds(stats.waitTotal / time.Duration(started))
+		stats.OCRMeanMS = milliseconds(stats.ocrTotal / time.Duration(started))
+	}
+	return stats
+}
+
+// acquire waits for a free slot and returns a client set up for key, reusing
+// an idle one when there is one
+func (p *ClientPool) acquire(ctx context.Context, key clientKey) (*gosseract.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	p.mu.Lock()
+	p.waiting++
+	p.mu.Unlock()
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.waiting--
+		p.stats.Canceled++
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	wait := time.Since(started)
+
+	p.mu.Lock()
+	p.waiting--
+	p.stats.Active++
+	p.stats.waitTotal += wait
+	p.stats.WaitMaxMS = max(p.stats.WaitMaxMS, milliseconds(wait))
+	var client *gosseract.Client
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		if p.idle[i].key == key {
+			client = p.idle[i].client
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if client != nil {
+		return client, nil
+	}
+	client, err := newClient(key)
+	if err != nil {
+		p.release(key, nil, false, 0)
+		return nil, err
+	}
+	p.mu.Lock()
+	p.stats.ClientsCreated++
+	p.mu.Unlock()
+	return client, nil
+}
+
+// release frees the slot of a read and keeps its client for the next read
+// with the same settings. Clients of failed reads are closed in case Tesseract
+// was left in a bad state. When the pool already holds as many idle clients as
+// it has slots, the oldest is closed.
+func (p *ClientPool) release(key clientKey, client *gosseract.Client, ok bool, duration time.Duration) {
+	var closing *gosseract.Client
+
+	p.mu.Lock()
+	p.stats.Active--
+	p.stats.ocrTotal += duration
+	p.stats.OCRMaxMS = max(p.stats.OCRMaxMS, milliseconds(duration))
+	if ok {
+		p.stats.Reads++
+		if len(p.idle) == cap(p.slots) {
+			closing = p.idle[0].client
+			p.idle = p.idle[1:]
+		}
+		p.idle = append(p.idle, idleClient{key: key, client: client})
+	} else {
+		p.stats.Failed++
+		closing = client
+	}
+	p.mu.Unlock()
+
+	<-p.slots
+	if closing != nil {
+		closing.Close()
+	}
+}
+
+func newClient(key clientKey) (*gosseract.Client, error) {
+	client := gosseract.NewClient()
+	if err := client.SetLanguage(key.lang); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to set language: %w", err)
+	}
+	if err := client.SetPageSegMode(key.pageSegMode); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to set page segmentation mode: %w", err)
+	}
+	if key.whitelist != "" {
+		if err := client.SetWhitelist(key.whitelist); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to set whitelist: %w", err)
+		}
+	}
+	return client, nil
+}
@@ -1,115 +1,207 @@
 package ocr
 
 import (
+	"bytes"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
 	"log"
-	"os"
-	"path/filepath"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/disintegration/imaging"
 )
 
-func PreprocessImage(inputPath string) (string, error) {
-	img, err := imaging.Open(inputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open image: %w", err)
-	}
+// Preprocessing steps a bank template can list
+const (
+	StepGrayscale = "grayscale"
+	StepCrop      = "crop"      // crop a photo to the slip, dropping the background around it
+	StepDeskew    = "deskew"    // straighten text photographed at an angle
+	StepNormalize = "normalize" // scale to a consistent resolution, about 300 DPI for a printed slip
+	StepDenoise   = "denoise"   // median filter against speckle and paper texture
+	StepContrast  = "contrast"
+	StepSharpen   = "sharpen"
+	StepBinarize  = "binarize" // black text on white, globally (otsu) or per neighbourhood (adaptive)
+)
 
-	log.Printf("Original image size: %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+// Binarization methods
+const (
+	BinarizeOtsu     = "otsu"
+	BinarizeAdaptive = "adaptive"
+)
 
-	img = imaging.Grayscale(img)
-	log.Println("Converted to grayscale")
+var preprocessSteps = []string{
+	StepGrayscale, StepCrop, StepDeskew, StepNormalize, StepDenoise, StepContrast, StepSharpen, StepBinarize,
+}
 
-	img = imaging.AdjustContrast(img, 30)
-	log.Println("Adjusted contrast")
+// PreprocessStep is one step of a preprocessing pipeline. Parameters a step
+// does not use are ignored; zero values take the step's default.
+type PreprocessStep struct {
+	Step     string  `yaml:"step" json:"step"`
+	Amount   float64 `yaml:"amount,omitempty" json:"amount,omitempty"`       // contrast: percent (default 30); sharpen: sigma (default 2)
+	MaxAngle float64 `yaml:"max_angle,omitempty" json:"max_angle,omitempty"` // deskew: largest skew corrected, in degrees (default 10)
+	MinAngle float64 `yaml:"min_angle,omitempty" json:"min_angle,omitempty"` // deskew: smaller skews are left alone (default 0.3)
+	Method   string  `yaml:"method,omitempty" json:"method,omitempty"`       // binarize: otsu or adaptive (default)
+	Window   int     `yaml:"window,omitempty" json:"window,omitempty"`       // binarize: adaptive window in px (default width/16); denoise: median window (default 3)
+	Offset   float64 `yaml:"offset,omitempty" json:"offset,omitempty"`       // binarize: percent darker than its window a pixel must be to turn black (default 15)
+	Size     int     `yaml:"size,omitempty" json:"size,omitempty"`           // normalize: long side in px (default 1500)
+	Margin   int     `yaml:"margin,omitempty" json:"margin,omitempty"`       // crop: px kept around the slip (default 8)
+}
 
-	img = imaging.Sharpen(img, 2.0)
-	log.Println("Sharpened image")
+// DefaultProfile names DefaultPreprocessing in reports
+const DefaultProfile = "default"
 
-	width := img.Bounds().Dx()
-	height := img.Bounds().Dy()
+// DefaultPreprocessing is used when no bank template lists steps
+var DefaultPreprocessing = []PreprocessStep{
+	{Step: StepGrayscale},
+	{Step: StepContrast, Amount: 30},
+	{Step: StepSharpen, Amount: 2},
+	{Step: StepNormalize, Size: 1500},
+}
 
-	if width < 1000 || height < 1000 {
-		if width > height {
-			img = imaging.Resize(img, 1500, 0, imaging.Lanczos)
-		} else {
-			img = imaging.Resize(img, 0, 1500, imaging.Lanczos)
-		}
-		log.Printf("Resized image to: %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+// validate checks a step's name and parameters
+func (s PreprocessStep) validate() error {
+	known := false
+	for _, step := range preprocessSteps {
+		known = known || step == s.Step
 	}
-
-	outputPath := getPreprocessedPath(inputPath)
-	err = imaging.Save(img, outputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to save preprocessed image: %w", err)
+	if !known {
+		return fmt.Errorf("unknown step %q (use %s)", s.Step, strings.Join(preprocessSteps, ", "))
 	}
-
-	log.Printf("Preprocessed image saved to: %s", outputPath)
-	return outputPath, nil
+	if s.Amount < 0 || s.MaxAngle < 0 || s.MinAngle < 0 || s.Window < 0 || s.Offset < 0 || s.Size < 0 || s.Margin < 0 {
+		return fmt.Errorf("%s parameters must not be negative", s.Step)
+	}
+	if s.Step == StepBinarize && s.Method != "" && s.Method != BinarizeOtsu && s.Method != BinarizeAdaptive {
+		return fmt.Errorf("unknown binarize method %q (use %s or %s)", s.Method, BinarizeOtsu, BinarizeAdaptive)
+	}
+	if s.Step == StepDenoise && s.Window != 0 && s.Window%2 == 0 {
+		return fmt.Errorf("denoise window must be odd")
+	}
+	return nil
 }
 
-func getPreprocessedPath(originalPath string) string {
-	dir := filepath.Dir(originalPath)
-	ext := filepath.Ext(originalPath)
-	base := filepath.Base(originalPath)
-	name := base[:len(base)-len(ext)]
-
-	return filepath.Join(dir, name+"_processed"+ext)
+// StepReport is what a preprocessing step did and how long it took
+type StepReport struct {
+	Step       string  `json:"step"`
+	Detail     string  `json:"detail,omitempty"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
 }
 
-// ConvertToJPEG converts an image to JPEG format if it's not already
-func ConvertToJPEG(inputPath string) (string, error) {
-	ext := filepath.Ext(inputPath)
+// PreprocessReport lists the steps run on an image, in order
+type PreprocessReport struct {
+	Profile    string       `json:"profile"` // template whose steps ran, or DefaultProfile
+	Steps      []StepReport `json:"steps"`
+	DurationMS float64      `json:"duration_ms"`
+}
 
-	// If already JPEG, return as is
-	if ext == ".jpg" || ext == ".jpeg" {
-		return inputPath, nil
+func (r *PreprocessReport) String() string {
+	parts := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		parts[i] = fmt.Sprintf("%s %.0fms", step.Step, step.DurationMS)
+		if step.Detail != "" {
+			parts[i] += " (" + step.Detail + ")"
+		}
 	}
+	return fmt.Sprintf("%s: %s; total %.0fms", r.Profile, strings.Join(parts, ", "), r.DurationMS)
+}
 
-	// Open the image
-	file, err := os.Open(inputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open image: %w", err)
+// Preprocess runs the steps on img in order
+func Preprocess(img image.Image, profile string, steps []PreprocessStep) (image.Image, *PreprocessReport) {
+	report := &PreprocessReport{Profile: profile}
+	started := time.Now()
+	for _, step := range steps {
+		stepStarted := time.Now()
+		var stepReport StepReport
+		img, stepReport = runStep(img, step)
+		stepReport.Step = step.Step
+		stepReport.DurationMS = milliseconds(time.Since(stepStarted))
+		report.Steps = append(report.Steps, stepReport)
 	}
-	defer file.Close()
+	report.DurationMS = milliseconds(time.Since(started))
+	return img, report
+}
 
-	var img image.Image
+func runStep(img image.Image, step PreprocessStep) (image.Image, StepReport) {
+	switch step.Step {
+	case StepGrayscale:
+		return toGray(img), StepReport{}
 
-	// Decode based on extension
-	switch ext {
-	case ".png":
-		img, err = png.Decode(file)
-	default:
-		img, _, err = image.Decode(file)
-	}
+	case StepCrop:
+		bounds, ok := slipBounds(toGray(img), orDefault(step.Margin, 8))
+		if !ok {
+			return img, StepReport{Skipped: true, Detail: "no background to crop"}
+		}
+		img = imaging.Crop(img, bounds.Add(img.Bounds().Min))
+		return img, StepReport{Detail: fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy())}
 
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
+	case StepDeskew:
+		angle := estimateSkew(toGray(img), orDefaultFloat(step.MaxAngle, 10))
+		if math.Abs(angle) < orDefaultFloat(step.MinAngle, 0.3) {
+			return img, StepReport{Skipped: true, Detail: fmt.Sprintf("%.1f°", angle)}
+		}
+		return rotate(img, angle), StepReport{Detail: fmt.Sprintf("%.1f°", angle)}
+
+	case StepNormalize:
+		size := orDefault(step.Size, 1500)
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		long := max(width, height)
+		if long >= size && long <= 2*size {
+			return img, StepReport{Skipped: true, Detail: fmt.Sprintf("%dx%d", width, height)}
+		}
+		if width >= height {
+			img = imaging.Resize(img, size, 0, imaging.Lanczos)
+		} else {
+			img = imaging.Resize(img, 0, size, imaging.Lanczos)
+		}
+		return img, StepReport{Detail: fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())}
 
-	// Create output path
-	outputPath := inputPath[:len(inputPath)-len(ext)] + ".jpg"
+	case StepDenoise:
+		return medianFilter(toGray(img), orDefault(step.Window, 3)), StepReport{}
 
-	// Create output file
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+	case StepContrast:
+		return imaging.AdjustContrast(img, orDefaultFloat(step.Amount, 30)), StepReport{}
+
+	case StepSharpen:
+		return imaging.Sharpen(img, orDefaultFloat(step.Amount, 2)), StepReport{}
+
+	case StepBinarize:
+		gray := toGray(img)
+		if step.Method == BinarizeOtsu {
+			out, threshold := binarizeOtsu(gray)
+			return out, StepReport{Detail: fmt.Sprintf("otsu threshold %d", threshold)}
+		}
+		window := orDefault(step.Window, max(gray.Bounds().Dx()/16, 15))
+		return binarizeAdaptive(gray, window, orDefaultFloat(step.Offset, 15)), StepReport{Detail: fmt.Sprintf("adaptive window %d", window)}
 	}
-	defer outFile.Close()
+	return img, StepReport{Skipped: true, Detail: "unknown step"}
+}
 
-	// Encode as JPEG
-	err = jpeg.Encode(outFile, img, &jpeg.Options{Quality: 95})
+// DecodeImage decodes an uploaded image: JPEG, PNG, or another format the
+// image package has a decoder for
+func DecodeImage(data []byte) (image.Image, error) {
+	img, err := imaging.Decode(bytes.NewReader(data))
 	if err != nil {
-		return "", fmt.Errorf("failed to encode JPEG: %w", err)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
+	log.Printf("Decoded image: %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	return img, nil
+}
 
-	log.Printf("Converted image to JPEG: %s", outputPath)
+func orDefault(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
 
-	// Remove original file
-	os.Remove(inputPath)
+func orDefaultFloat(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
 
-	return outputPath, nil
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/10) / 100
 }
@@ -0,0 +1,173 @@
+package ocr
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// SlipQR is the verification payload printed as a mini-QR on Thai bank e-slips
+type SlipQR struct {
+	Payload        string
+	APIID          string
+	BankCode       string
+	TransactionRef string
+	Country        string
+}
+
+// Sending bank codes used in slip QR payloads (Bank of Thailand institution codes)
+var slipQRBankCodes = map[string]string{
+	"002": "BBL",
+	"004": "KBank",
+	"006": "KTB",
+	"011": "TTB",
+	"014": "SCB",
+	"025": "BAY",
+	"030": "GSB",
+	"034": "BAAC",
+	"069": "KKP",
+}
+
+// DecodeSlipQR locates the QR code on a slip image and parses its payload
+func DecodeSlipQR(img image.Image) (*SlipQR, error) {
+	payload, err := readQRCode(img)
+	if err != nil {
+		return nil, err
+	}
+
+	qr, err := ParseSlipQRPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Decoded slip QR: bank=%s ref=%s country=%s", qr.BankCode, qr.TransactionRef, qr.Country)
+	return qr, nil
+}
+
+// readQRCode tries the image as-is, then upscaled, since slip mini-QRs are often tiny
+func readQRCode(img image.Image) (string, error) {
+	reader := qrcode.NewQRCodeReader()
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	candidates := []image.Image{img}
+	if img.Bounds().Dx() < 2000 {
+		candidates = append(candidates, imaging.Resize(img, img.Bounds().Dx()*2, 0, imaging.NearestNeighbor))
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		bmp, err := gozxing.NewBinaryBitmapFromImage(candidate)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		result, err := reader.Decode(bmp, hints)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return result.GetText(), nil
+	}
+
+	return "", fmt.Errorf("no QR code found: %w", lastErr)
+}
+
+// ParseSlipQRPayload parses the EMVCo-style TLV payload of a slip verification QR.
+//
+// Layout: 00 holds nested tags (00 API ID, 01 sending bank code, 02 transaction
+// reference), 51 is the country code and 91 is a CRC-16/CCITT over the payload.
+func ParseSlipQRPayload(payload string) (*SlipQR, error) {
+	tags, err := parseTLV(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if crc, ok := tags["91"]; ok {
+		body := payload[:len(payload)-len(crc)]
+		if expected := fmt.Sprintf("%04X", crc16CCITT([]byte(body))); expected != crc {
+			return nil, fmt.Errorf("QR checksum mismatch: got %s, expected %s", crc, expected)
+		}
+	}
+
+	inner, ok := tags["00"]
+	if !ok {
+		return nil, fmt.Errorf("QR payload has no slip data")
+	}
+	fields, err := parseTLV(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &SlipQR{
+		Payload:        payload,
+		APIID:          fields["00"],
+		BankCode:       fields["01"],
+		TransactionRef: fields["02"],
+		Country:        tags["51"],
+	}
+	if qr.TransactionRef == "" {
+		return nil, fmt.Errorf("QR payload has no transaction reference")
+	}
+
+	return qr, nil
+}
+
+// BankName maps the QR's sending bank code to the name used by the bank templates
+func (qr *SlipQR) BankName() string {
+	if name, ok := slipQRBankCodes[qr.BankCode]; ok {
+		return name
+	}
+	return ""
+}
+
+// parseTLV splits a string of 2-digit tag, 2-digit length, value triples
+func parseTLV(data string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for i := 0; i < len(data); {
+		if i+4 > len(data) {
+			return nil, fmt.Errorf("truncated TLV header at offset %d", i)
+		}
+		tag := data[i : i+2]
+		// Atoi would also take a sign, and a negative length would slice backwards
+		digits := data[i+2 : i+4]
+		if !isDigit(digits[0]) || !isDigit(digits[1]) {
+			return nil, fmt.Errorf("invalid TLV length at offset %d", i)
+		}
+		length, _ := strconv.Atoi(digits)
+		i += 4
+		if i+length > len(data) {
+			return nil, fmt.Errorf("TLV value for tag %s overruns payload", tag)
+		}
+		tags[tag] = data[i : i+length]
+		i += length
+	}
+	return tags, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// crc16CCITT computes CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF) as used by EMVCo QR
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,67 @@
+package ocr
+
+import (
+	"fmt"
+	"testing"
+)
+
+// withCRC appends tag 91 with the checksum of payload
+func withCRC(payload string) string {
+	payload += "9104"
+	return payload + fmt.Sprintf("%04X", crc16CCITT([]byte(payload)))
+}
+
+func TestParseSlipQRPayload(t *testing.T) {
+	// 00: API ID 000001, bank 004, reference 0123456789ABCD
+	inner := "0006000001" + "0103004" + "0214" + "0123456789ABCD"
+	valid := withCRC(fmt.Sprintf("00%02d%s", len(inner), inner) + "5102TH")
+
+	tests := []struct {
+		name    string
+		payload string
+		wantRef string
+		wantErr bool
+	}{
+		{name: "valid", payload: valid, wantRef: "0123456789ABCD"},
+		{name: "valid without checksum", payload: fmt.Sprintf("00%02d%s", len(inner), inner), wantRef: "0123456789ABCD"},
+		{name: "empty", payload: "", wantErr: true},
+		{name: "truncated header", payload: "000", wantErr: true},
+		{name: "truncated value", payload: "0010abc", wantErr: true},
+		{name: "negative length", payload: "00-1abcdef", wantErr: true},
+		{name: "signed length", payload: "00+1abcdef", wantErr: true},
+		{name: "non-digit length", payload: "00a1abcdef", wantErr: true},
+		{name: "overrunning length", payload: "0099abc", wantErr: true},
+		{name: "negative inner length", payload: "0006" + "00-1ab", wantErr: true},
+		{name: "checksum mismatch", payload: valid[:len(valid)-4] + "0000", wantErr: true},
+		{name: "no slip data", payload: withCRC("5102TH"), wantErr: true},
+		{name: "no reference", payload: withCRC("0010" + "0006000001"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, err := ParseSlipQRPayload(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseSlipQRPayload(%q) = %+v, want error", tt.payload, qr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSlipQRPayload(%q) error: %v", tt.payload, err)
+			}
+			if qr.TransactionRef != tt.wantRef {
+				t.Errorf("TransactionRef = %q, want %q", qr.TransactionRef, tt.wantRef)
+			}
+			if qr.BankName() != "KBank" {
+				t.Errorf("BankName() = %q, want KBank", qr.BankName())
+			}
+		})
+	}
+}
+
+func TestCRC16CCITT(t *testing.T) {
+	// Standard check value of CRC-16/CCITT-FALSE
+	if got := crc16CCITT([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("crc16CCITT(123456789) = %04X, want 29B1", got)
+	}
+}
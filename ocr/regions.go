@@ -0,0 +1,255 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocr-api/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/otiai10/gosseract/v2"
+)
+
+// Region reading modes
+const (
+	RegionLine  = "line"  // one line of text (default)
+	RegionBlock = "block" // a few lines, e.g. a name wrapped over two
+	RegionWord  = "word"
+)
+
+// Region is where a field is printed on a bank's slips, as fractions (0-1)
+// of the preprocessed slip's width and height, and how to read it
+type Region struct {
+	X         float64 `yaml:"x"`
+	Y         float64 `yaml:"y"`
+	Width     float64 `yaml:"width"`
+	Height    float64 `yaml:"height"`
+	Mode      string  `yaml:"mode"`      // line, block or word
+	Whitelist string  `yaml:"whitelist"` // characters allowed; the field's default when empty
+}
+
+// regionWhitelists are the characters each field is read with unless its
+// region says otherwise. Fields with Thai text have none.
+var regionWhitelists = map[string]string{
+	FieldAmount:          "0123456789.,",
+	FieldTime:            "0123456789:.",
+	FieldReference:       "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	FieldTransactionID:   "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	FieldMerchantID:      "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	FieldSenderAccount:   "0123456789-xX*",
+	FieldReceiverAccount: "0123456789-xX*",
+	FieldWalletPhone:     "0123456789-xX*",
+}
+
+// regionScore is the confidence of a value read from its region, before
+// scaling by Tesseract's confidence in the region's words
+const regionScore = 0.95
+
+var (
+	regionIdentifier = regexp.MustCompile(`[A-Za-z0-9]{6,}`)
+	regionAccount    = regexp.MustCompile(`[xX*\d]{3}-[xX*\d-]{4,10}[xX*\d]`)
+)
+
+func (r Region) validate() error {
+	if r.Width <= 0 || r.Height <= 0 {
+		return fmt.Errorf("width and height must be positive")
+	}
+	if r.X < 0 || r.Y < 0 || r.X+r.Width > 1.0001 || r.Y+r.Height > 1.0001 {
+		return fmt.Errorf("must lie within the slip (fractions 0-1)")
+	}
+	switch r.Mode {
+	case "", RegionLine, RegionBlock, RegionWord:
+	default:
+		return fmt.Errorf("unknown mode %q (use %s, %s or %s)", r.Mode, RegionLine, RegionBlock, RegionWord)
+	}
+	return nil
+}
+
+// bounds is the region in pixels on an image
+func (r Region) bounds(img image.Rectangle) image.Rectangle {
+	w, h := float64(img.Dx()), float64(img.Dy())
+	return image.Rect(
+		int(r.X*w), int(r.Y*h),
+		int((r.X+r.Width)*w+0.5), int((r.Y+r.Height)*h+0.5),
+	).Add(img.Min).Intersect(img)
+}
+
+func (r Region) options(field string) OCROptions {
+	opts := OCROptions{PageSegMode: gosseract.PSM_SINGLE_LINE, Whitelist: r.Whitelist}
+	switch r.Mode {
+	case RegionBlock:
+		opts.PageSegMode = gosseract.PSM_SINGLE_BLOCK
+	case RegionWord:
+		opts.PageSegMode = gosseract.PSM_SINGLE_WORD
+	}
+	if opts.Whitelist == "" {
+		opts.Whitelist = regionWhitelists[field]
+	}
+	return opts
+}
+
+// RegionReading is what was read from one field's region
+type RegionReading struct {
+	Field      string  `json:"field"`
+	Text       string  `json:"text"`
+	Value      string  `json:"value,omitempty"` // empty when the text held no valid value
+	Confidence float64 `json:"confidence,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// ReadRegions crops each field region of a bank's template from a
+// preprocessed slip image and reads it. It returns nothing for banks whose
+// template has no regions.
+func ReadRegions(ctx context.Context, img image.Image, bank, lang string) []RegionReading {
+	if bankTemplates == nil {
+		return nil
+	}
+	template := bankTemplates.Lookup(bank)
+	if template == nil || len(template.Regions) == 0 {
+		return nil
+	}
+
+	var readings []RegionReading
+	for _, field := range templateFields {
+		region, ok := template.Regions[field]
+		if !ok {
+			continue
+		}
+		readings = append(readings, template.readRegion(ctx, img, field, region, lang))
+	}
+	return readings
+}
+
+func (t *BankTemplate) readRegion(ctx context.Context, img image.Image, field string, region Region, lang string) RegionReading {
+	reading := RegionReading{Field: field}
+	bounds := region.bounds(img.Bounds())
+	if bounds.Empty() {
+		reading.Error = "region is outside the image"
+		return reading
+	}
+
+	started := time.Now()
+	result, err := PerformOCRWith(ctx, imaging.Crop(img, bounds), lang, region.options(field))
+	reading.DurationMS = milliseconds(time.Since(started))
+	if err != nil {
+		reading.Error = err.Error()
+		return reading
+	}
+
+	reading.Text = strings.TrimSpace(result.Text)
+	reading.Value = t.regionValue(field, reading.Text)
+	if reading.Value == "" {
+		reading.Error = "no valid value"
+		return reading
+	}
+	reading.Confidence = regionScore
+	if len(result.Words) > 0 {
+		reading.Confidence *= result.MeanConfidence() / 100
+	}
+	return reading
+}
+
+// regionValue picks the field's value out of a region's text: with the
+// template's patterns when they match, otherwise by the field's form. Values
+// that are not valid for the field are dropped, so a misplaced region falls
+// back to the full-text patterns.
+func (t *BankTemplate) regionValue(field, text string) string {
+	if text == "" {
+		return ""
+	}
+
+	switch field {
+	case FieldAmount:
+		match := twoDecimalAmount.FindString(text)
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+		if match == "" || err != nil || amount <= 0 {
+			return ""
+		}
+		return strconv.FormatFloat(amount, 'f', 2, 64)
+	case FieldDate:
+		date, _ := t.MatchField(field, text)
+		if !validDate(utils.NormalizeDateLocale(date, t.DateLocale)) {
+			return ""
+		}
+		return date
+	case FieldTime:
+		clock, _ := t.MatchField(field, text)
+		if !validClock(clock) {
+			return ""
+		}
+		return clock
+	}
+
+	if value, idx := t.MatchField(field, text); idx >= 0 {
+		return value
+	}
+	switch field {
+	case FieldReference, FieldTransactionID, FieldMerchantID:
+		return longestMatch(regionIdentifier, text)
+	case FieldSenderAccount, FieldReceiverAccount:
+		return regionAccount.FindString(text)
+	}
+	// Names and phone numbers: the region is the value
+	return strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+}
+
+func longestMatch(re *regexp.Regexp, text string) string {
+	var longest string
+	for _, match := range re.FindAllString(text, -1) {
+		if len(match) > len(longest) {
+			longest = match
+		}
+	}
+	return longest
+}
+
+// applyRegions puts values read from layout regions in place of those the
+// full-text patterns found
+func applyRegions(data *ExtractedData, readings []RegionReading) {
+	for _, reading := range readings {
+		if reading.Value == "" {
+			continue
+		}
+		switch reading.Field {
+		case FieldAmount:
+			amount, err := strconv.ParseFloat(reading.Value, 64)
+			if err != nil {
+				continue
+			}
+			data.Amount = amount
+		case FieldDate:
+			data.Date = reading.Value
+		case FieldTime:
+			data.Time = reading.Value
+		case FieldReference:
+			data.Reference = reading.Value
+		case FieldSender:
+			data.Sender = reading.Value
+		case FieldReceiver:
+			data.Receiver = reading.Value
+		case FieldSenderAccount:
+			data.SenderAccount = reading.Value
+		case FieldReceiverAccount:
+			data.ReceiverAccount = reading.Value
+		case FieldWalletPhone:
+			data.WalletPhone = reading.Value
+		case FieldMerchantID:
+			data.MerchantID = reading.Value
+		case FieldTransactionID:
+			data.TransactionID = reading.Value
+		default:
+			continue
+		}
+		data.Confidence[reading.Field] = reading.Confidence
+		if data.FromRegion == nil {
+			data.FromRegion = make(map[string]bool)
+		}
+		data.FromRegion[reading.Field] = true
+	}
+}
@@ -0,0 +1,187 @@
+package ocr
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ocr-api/utils"
+)
+
+// StatementRow is one transaction line of a bank statement
+type StatementRow struct {
+	Date        string // normalized like NormalizeDate
+	Time        string
+	Description string
+	Amount      float64
+	Type        string  // income or expense
+	Balance     float64 // running balance, 0 when the row has none
+	Line        string  // the row as it appeared in the statement
+}
+
+var (
+	// Rows start with a date, optionally followed by a time
+	statementRowStart = regexp.MustCompile(`^\s*(\d{1,2}[/-]\d{1,2}[/-]\d{2,4}|\d{1,2}\s+(?:ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+\d{2,4})\s+(?:(\d{1,2}[:.]\d{2})(?::\d{2})?\s+)?`)
+
+	// Money columns always have two decimals; a sign may be written as
+	// -1,500.00, 1,500.00-, (1,500.00) or +1,500.00
+	statementAmount = regexp.MustCompile(`([-+(]?)\b(\d{1,3}(?:,\d{3})+\.\d{2}|\d+\.\d{2})\b(\)|-)?`)
+
+	statementOpeningBalance = regexp.MustCompile(`(?i)ยอดยกมา|ยอดคงเหลือยกมา|balance\s*(?:brought\s*forward|b/f)|opening\s*balance|beginning\s*balance`)
+	statementIncomeWords    = regexp.MustCompile(`(?i)deposit|credit|interest|refund|รับโอน|เงินเข้า|ฝาก|ดอกเบี้ย|คืนเงิน`)
+)
+
+type statementAmountMatch struct {
+	value  float64
+	signed int // -1 or +1 when the statement marked the sign, else 0
+	start  int
+}
+
+// ParseStatement pulls transaction rows out of statement text. Direction is
+// taken from an explicit sign, then from the running balance, then from
+// keywords in the description.
+func ParseStatement(text, dateLocale string) []StatementRow {
+	var rows []StatementRow
+	balance, hasBalance := 0.0, false
+
+	for _, line := range strings.Split(text, "\n") {
+		if statementOpeningBalance.MatchString(line) {
+			if amounts := findStatementAmounts(line); len(amounts) > 0 {
+				balance, hasBalance = amounts[len(amounts)-1].value, true
+			}
+			continue
+		}
+
+		start := statementRowStart.FindStringSubmatchIndex(line)
+		if start == nil {
+			continue
+		}
+		rest := line[start[1]:]
+		amounts := findStatementAmounts(rest)
+		if len(amounts) == 0 {
+			continue
+		}
+
+		row := StatementRow{
+			Date: utils.NormalizeDateLocale(line[start[2]:start[3]], dateLocale),
+			Line: strings.TrimSpace(line),
+		}
+		if start[4] >= 0 {
+			row.Time = strings.Replace(line[start[4]:start[5]], ".", ":", 1)
+		}
+		row.Description = strings.Join(strings.Fields(rest[:amounts[0].start]), " ")
+
+		var amount statementAmountMatch
+		rowHasBalance := len(amounts) >= 2
+		switch {
+		case len(amounts) >= 3 && amounts[len(amounts)-3].value > 0 && amounts[len(amounts)-2].value == 0:
+			// Withdrawal, deposit and balance columns all printed
+			amount = amounts[len(amounts)-3]
+			amount.signed = -1
+		case len(amounts) >= 3 && amounts[len(amounts)-3].value == 0 && amounts[len(amounts)-2].value > 0:
+			amount = amounts[len(amounts)-2]
+			amount.signed = 1
+		case rowHasBalance:
+			amount = amounts[len(amounts)-2]
+		default:
+			amount = amounts[0]
+		}
+		if amount.value <= 0 {
+			continue
+		}
+		row.Amount = amount.value
+
+		if rowHasBalance {
+			row.Balance = amounts[len(amounts)-1].value
+		}
+
+		switch {
+		case amount.signed < 0:
+			row.Type = "expense"
+		case amount.signed > 0:
+			row.Type = "income"
+		case rowHasBalance && hasBalance && nearlyEqual(balance+row.Amount, row.Balance):
+			row.Type = "income"
+		case rowHasBalance && hasBalance && nearlyEqual(balance-row.Amount, row.Balance):
+			row.Type = "expense"
+		case statementIncomeWords.MatchString(row.Description):
+			row.Type = "income"
+		default:
+			row.Type = "expense"
+		}
+
+		if rowHasBalance {
+			balance, hasBalance = row.Balance, true
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func findStatementAmounts(text string) []statementAmountMatch {
+	var amounts []statementAmountMatch
+	for _, m := range statementAmount.FindAllStringSubmatchIndex(text, -1) {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(text[m[4]:m[5]], ",", ""), 64)
+		if err != nil {
+			continue
+		}
+
+		match := statementAmountMatch{value: value, start: m[0]}
+		prefix := text[m[2]:m[3]]
+		suffix := ""
+		if m[6] >= 0 {
+			suffix = text[m[6]:m[7]]
+		}
+		switch {
+		case prefix == "-" || suffix == "-" || (prefix == "(" && suffix == ")"):
+			match.signed = -1
+		case prefix == "+":
+			match.signed = 1
+		}
+		amounts = append(amounts, match)
+	}
+	return amounts
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// statementHeaderLines caps how much of the text before the first row is used
+// to identify the bank; rows name other banks and wallets as counterparties
+const statementHeaderLines = 15
+
+// DetectStatementBank names the bank a statement belongs to from its header
+// (the lines before the first row) using the bank templates' identifiers, and
+// returns the date locale to read it with.
+func DetectStatementBank(text string) (string, string) {
+	if bankTemplates == nil {
+		return "", utils.DateLocaleTH
+	}
+
+	var header []string
+	for _, line := range strings.Split(text, "\n") {
+		if statementRowStart.MatchString(line) || statementOpeningBalance.MatchString(line) {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if header = append(header, line); len(header) == statementHeaderLines {
+			break
+		}
+	}
+	headerText := strings.Join(header, "\n")
+
+	for _, t := range bankTemplates.Templates() {
+		if t.Channel == ChannelBank && t.Matches(headerText) {
+			return t.Name, t.DateLocale
+		}
+	}
+	if t := bankTemplates.Fallback(); t != nil {
+		return "", t.DateLocale
+	}
+	return "", utils.DateLocaleTH
+}
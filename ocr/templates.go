@@ -0,0 +1,467 @@
+package ocr
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ocr-api/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Channels a slip can come from
+const (
+	ChannelBank    = "bank"
+	ChannelWallet  = "wallet"  // e-wallets such as TrueMoney or ShopeePay
+	ChannelCounter = "counter" // payment counters such as 7-Eleven Counter Service
+)
+
+// templateFields are the fields a bank template can define patterns for
+var templateFields = []string{
+	FieldAmount, FieldDate, FieldTime, FieldReference, FieldSender, FieldReceiver,
+	FieldSenderAccount, FieldReceiverAccount,
+	FieldWalletPhone, FieldMerchantID, FieldTransactionID,
+}
+
+// BankTemplate describes how to recognise a bank's (or wallet's, or payment
+// counter's) slips and extract their fields. Templates are loaded from YAML;
+// see banks/ for examples.
+type BankTemplate struct {
+	Name        string              `yaml:"name"`
+	Priority    int                 `yaml:"priority"`    // higher is tried first when detecting the bank
+	DateLocale  string              `yaml:"date_locale"` // th or en
+	Channel     string              `yaml:"channel"`     // bank, wallet or counter
+	Extends     string              `yaml:"extends"`     // template to take missing patterns, locale and channel from
+	Fallback    bool                `yaml:"fallback"`    // used when no bank is recognised
+	Identifiers []string            `yaml:"identifiers"`
+	Patterns    map[string][]string `yaml:"patterns"`   // keyed by Field* name
+	Preprocess  []PreprocessStep    `yaml:"preprocess"` // image preprocessing for the bank's slips, in order
+	Regions     map[string]Region   `yaml:"regions"`    // where fields are printed, keyed by Field* name
+
+	File string `yaml:"-"`
+
+	preprocessFrom string // template the preprocessing steps were defined in
+
+	identifiers []*regexp.Regexp
+	patterns    map[string][]*regexp.Regexp
+}
+
+// Matches reports whether any of the template's identifiers occur in text
+func (t *BankTemplate) Matches(text string) bool {
+	for _, re := range t.identifiers {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAmount returns the amount and the index of the pattern that matched, or -1
+func (t *BankTemplate) MatchAmount(text string) (float64, int) {
+	return extractAmount(text, t.patterns[FieldAmount])
+}
+
+// MatchField returns the first non-empty match for field and the index of its pattern, or -1
+func (t *BankTemplate) MatchField(field, text string) (string, int) {
+	return extractField(text, t.patterns[field])
+}
+
+// TemplateRegistry holds the loaded bank templates and reloads them when the
+// directory changes. A reload that fails validation keeps the previous set.
+type TemplateRegistry struct {
+	dir string
+
+	mu        sync.RWMutex
+	templates []*BankTemplate // detection order
+	fallback  *BankTemplate
+	signature string
+}
+
+// bankTemplates is the registry used by ExtractDataWithQR
+var bankTemplates *TemplateRegistry
+
+// LoadBankTemplates loads the templates used for extraction and, when
+// reloadInterval is positive, watches dir for changes.
+func LoadBankTemplates(dir string, reloadInterval time.Duration) error {
+	registry, err := NewTemplateRegistry(dir)
+	if err != nil {
+		return err
+	}
+	bankTemplates = registry
+
+	if reloadInterval > 0 {
+		go registry.Watch(reloadInterval)
+	}
+	return nil
+}
+
+// NewTemplateRegistry loads and validates every *.yaml / *.yml file in dir
+func NewTemplateRegistry(dir string) (*TemplateRegistry, error) {
+	r := &TemplateRegistry{dir: dir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the template directory
+func (r *TemplateRegistry) Reload() error {
+	signature, err := templateDirSignature(r.dir)
+	if err != nil {
+		return err
+	}
+
+	templates, err := loadTemplateDir(r.dir)
+	if err != nil {
+		r.mu.Lock()
+		r.signature = signature // don't retry until the files change again
+		r.mu.Unlock()
+		return err
+	}
+
+	var fallback *BankTemplate
+	for _, t := range templates {
+		if t.Fallback {
+			fallback = t
+		}
+	}
+
+	r.mu.Lock()
+	r.templates = templates
+	r.fallback = fallback
+	r.signature = signature
+	r.mu.Unlock()
+
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.Name
+	}
+	log.Printf("Loaded %d bank templates from %s: %s", len(templates), r.dir, strings.Join(names, ", "))
+	return nil
+}
+
+// Watch polls the template directory and reloads it when files change
+func (r *TemplateRegistry) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		signature, err := templateDirSignature(r.dir)
+		if err != nil {
+			log.Printf("Warning: failed to check bank templates: %v", err)
+			continue
+		}
+
+		r.mu.RLock()
+		changed := signature != r.signature
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Printf("Warning: bank templates not reloaded, keeping previous set: %v", err)
+		}
+	}
+}
+
+// Templates returns the loaded templates in detection order
+func (r *TemplateRegistry) Templates() []*BankTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.templates
+}
+
+// Lookup finds a template by name, ignoring case
+func (r *TemplateRegistry) Lookup(name string) *BankTemplate {
+	for _, t := range r.Templates() {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Detect returns the highest-priority template whose identifiers match text, or nil
+func (r *TemplateRegistry) Detect(text string) *BankTemplate {
+	for _, t := range r.Templates() {
+		if t.Matches(text) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Fallback returns the template used for unrecognised slips, or nil
+func (r *TemplateRegistry) Fallback() *BankTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fallback
+}
+
+// Preprocessing returns the preprocessing steps for a bank's slips and the
+// template that defined them. Banks without a template, or whose template
+// lists no steps, use the fallback template's, then DefaultPreprocessing.
+func (r *TemplateRegistry) Preprocessing(bank string) (string, []PreprocessStep) {
+	for _, t := range []*BankTemplate{r.Lookup(bank), r.Fallback()} {
+		if t != nil && t.Preprocess != nil {
+			return t.preprocessFrom, t.Preprocess
+		}
+	}
+	return DefaultProfile, DefaultPreprocessing
+}
+
+// PreprocessingFor returns the preprocessing steps for a bank's slips and the
+// profile (template) they come from
+func PreprocessingFor(bank string) (string, []PreprocessStep) {
+	if bankTemplates == nil {
+		return DefaultProfile, DefaultPreprocessing
+	}
+	return bankTemplates.Preprocessing(bank)
+}
+
+// DetectBank returns the name of the bank recognised in text, or ""
+func DetectBank(text string) string {
+	if bankTemplates == nil {
+		return ""
+	}
+	if t := bankTemplates.Detect(text); t != nil {
+		return t.Name
+	}
+	return ""
+}
+
+func templateFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bank template directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// templateDirSignature changes whenever a template file is added, removed or modified
+func templateDirSignature(dir string) (string, error) {
+	files, err := templateFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		fmt.Fprintf(h, "%s|%d|%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// loadTemplateDir parses, validates and compiles every template in dir
+func loadTemplateDir(dir string) ([]*BankTemplate, error) {
+	files, err := templateFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no bank templates found in %s", dir)
+	}
+
+	byName := make(map[string]*BankTemplate)
+	var templates []*BankTemplate
+	for _, file := range files {
+		t, err := parseTemplateFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.ToLower(t.Name)
+		if other, exists := byName[key]; exists {
+			return nil, fmt.Errorf("%s: bank %q is already defined in %s", file, t.Name, other.File)
+		}
+		byName[key] = t
+		templates = append(templates, t)
+	}
+
+	var fallback *BankTemplate
+	for _, t := range templates {
+		if err := resolveTemplate(t, byName, nil); err != nil {
+			return nil, err
+		}
+		if t.Fallback {
+			if fallback != nil {
+				return nil, fmt.Errorf("%s: only one fallback template is allowed, %s is already the fallback", t.File, fallback.Name)
+			}
+			fallback = t
+		}
+	}
+
+	for _, t := range templates {
+		if t.Channel == "" {
+			t.Channel = ChannelBank
+		}
+		if err := compileTemplate(t); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		if templates[i].Priority != templates[j].Priority {
+			return templates[i].Priority > templates[j].Priority
+		}
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+func parseTemplateFile(file string) (*BankTemplate, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var t BankTemplate
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	t.File = file
+
+	if strings.TrimSpace(t.Name) == "" {
+		return nil, fmt.Errorf("%s: name is required", file)
+	}
+	switch t.DateLocale {
+	case "", utils.DateLocaleTH, utils.DateLocaleEN:
+	default:
+		return nil, fmt.Errorf("%s: unknown date_locale %q (use %s or %s)", file, t.DateLocale, utils.DateLocaleTH, utils.DateLocaleEN)
+	}
+	switch t.Channel {
+	case "", ChannelBank, ChannelWallet, ChannelCounter:
+	default:
+		return nil, fmt.Errorf("%s: unknown channel %q (use %s, %s or %s)", file, t.Channel, ChannelBank, ChannelWallet, ChannelCounter)
+	}
+	for field := range t.Patterns {
+		if !isTemplateField(field) {
+			return nil, fmt.Errorf("%s: unknown pattern field %q (use %s)", file, field, strings.Join(templateFields, ", "))
+		}
+	}
+	for i, step := range t.Preprocess {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("%s: preprocess step %d: %w", file, i+1, err)
+		}
+	}
+	if t.Preprocess != nil {
+		t.preprocessFrom = t.Name
+	}
+	for field, region := range t.Regions {
+		if !isTemplateField(field) {
+			return nil, fmt.Errorf("%s: unknown region field %q (use %s)", file, field, strings.Join(templateFields, ", "))
+		}
+		if err := region.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s region: %w", file, field, err)
+		}
+	}
+
+	return &t, nil
+}
+
+// resolveTemplate fills patterns and locale the template leaves out from the one it extends
+func resolveTemplate(t *BankTemplate, byName map[string]*BankTemplate, seen []string) error {
+	if t.Extends == "" {
+		return nil
+	}
+
+	for _, name := range seen {
+		if strings.EqualFold(name, t.Name) {
+			return fmt.Errorf("%s: templates extend each other in a loop: %s", t.File, strings.Join(append(seen, t.Name), " -> "))
+		}
+	}
+
+	parent, ok := byName[strings.ToLower(t.Extends)]
+	if !ok {
+		return fmt.Errorf("%s: extends unknown template %q", t.File, t.Extends)
+	}
+	if err := resolveTemplate(parent, byName, append(seen, t.Name)); err != nil {
+		return err
+	}
+
+	if t.Patterns == nil {
+		t.Patterns = make(map[string][]string)
+	}
+	for field, patterns := range parent.Patterns {
+		if _, ok := t.Patterns[field]; !ok {
+			t.Patterns[field] = patterns
+		}
+	}
+	if t.DateLocale == "" {
+		t.DateLocale = parent.DateLocale
+	}
+	if t.Channel == "" {
+		t.Channel = parent.Channel
+	}
+	if t.Preprocess == nil {
+		t.Preprocess = parent.Preprocess
+		t.preprocessFrom = parent.preprocessFrom
+	}
+	if t.Regions == nil {
+		t.Regions = parent.Regions
+	}
+	t.Extends = ""
+	return nil
+}
+
+func compileTemplate(t *BankTemplate) error {
+	for i, identifier := range t.Identifiers {
+		re, err := regexp.Compile(identifier)
+		if err != nil {
+			return fmt.Errorf("%s: identifier %d: %w", t.File, i, err)
+		}
+		t.identifiers = append(t.identifiers, re)
+	}
+
+	t.patterns = make(map[string][]*regexp.Regexp)
+	for field, patterns := range t.Patterns {
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: %s pattern %d: %w", t.File, field, i, err)
+			}
+			if re.NumSubexp() < 1 {
+				return fmt.Errorf("%s: %s pattern %d has no capture group", t.File, field, i)
+			}
+			t.patterns[field] = append(t.patterns[field], re)
+		}
+	}
+	return nil
+}
+
+func isTemplateField(field string) bool {
+	for _, f := range templateFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateFields lists the fields templates define patterns for, in display order
+func TemplateFields() []string {
+	return append([]string(nil), templateFields...)
+}
@@ -1,34 +1,140 @@
 package ocr
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"image"
+	"image/draw"
 	"log"
+	"time"
 
 	"github.com/otiai10/gosseract/v2"
 )
 
-func PerformOCR(imagePath string, lang string) (string, error) {
-	client := gosseract.NewClient()
-	defer client.Close()
+// OCRWord is a recognized word with Tesseract's confidence (0-100) and its
+// position in the image
+type OCRWord struct {
+	Text       string
+	Confidence float64
+	Box        image.Rectangle
+}
+
+// OCRResult is the full text of a slip plus per-word confidences
+type OCRResult struct {
+	Text     string
+	Words    []OCRWord
+	Duration time.Duration // time Tesseract took, not counting the wait for a client
+}
+
+// MeanConfidence is the average word confidence (0-100) of the page
+func (r *OCRResult) MeanConfidence() float64 {
+	if len(r.Words) == 0 {
+		return 0
+	}
+	var total float64
+	for _, w := range r.Words {
+		total += w.Confidence
+	}
+	return total / float64(len(r.Words))
+}
+
+// OCROptions tune a Tesseract run
+type OCROptions struct {
+	PageSegMode gosseract.PageSegMode
+	Whitelist   string // characters Tesseract may return; empty allows all
+}
+
+// DefaultOCROptions reads a whole slip with automatic page segmentation
+var DefaultOCROptions = OCROptions{PageSegMode: gosseract.PSM_AUTO}
+
+// PerformOCR reads an image. Reads queue for the OCR client pool; ctx ends
+// the wait, but not a read that has started.
+func PerformOCR(ctx context.Context, img image.Image, lang string) (*OCRResult, error) {
+	return PerformOCRWith(ctx, img, lang, DefaultOCROptions)
+}
+
+// PerformOCRWith reads an image with the given options
+func PerformOCRWith(ctx context.Context, img image.Image, lang string, opts OCROptions) (*OCRResult, error) {
+	data := encodePNM(img)
+	return performOCR(ctx, lang, opts, func(client *gosseract.Client) error {
+		return client.SetImageFromBytes(data)
+	})
+}
+
+// encodePNM writes img as a binary PGM when it is grayscale and as a PPM
+// otherwise. Leptonica reads PNM without an image library, and the encoding
+// is a plain copy of the pixels, where PNG would compress them only for
+// Tesseract to decompress them again. Transparent pixels are laid on white.
+func encodePNM(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
 
-	err := client.SetLanguage(lang)
+	if gray, ok := img.(*image.Gray); ok {
+		var buf bytes.Buffer
+		buf.Grow(w*h + 20)
+		fmt.Fprintf(&buf, "P5\n%d %d\n255\n", w, h)
+		for y := 0; y < h; y++ {
+			buf.Write(gray.Pix[y*gray.Stride : y*gray.Stride+w])
+		}
+		return buf.Bytes()
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Over)
+
+	var buf bytes.Buffer
+	buf.Grow(w*h*3 + 20)
+	fmt.Fprintf(&buf, "P6\n%d %d\n255\n", w, h)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		buf.Write(rgba.Pix[i : i+3])
+	}
+	return buf.Bytes()
+}
+
+func performOCR(ctx context.Context, lang string, opts OCROptions, setImage func(*gosseract.Client) error) (*OCRResult, error) {
+	key := clientKey{lang: lang, pageSegMode: opts.PageSegMode, whitelist: opts.Whitelist}
+	client, err := ocrPool.acquire(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("failed to set language: %w", err)
+		return nil, fmt.Errorf("failed to get OCR client: %w", err)
 	}
 
-	err = client.SetImage(imagePath)
+	started := time.Now()
+	result, err := recognizeWith(client, setImage)
+	duration := time.Since(started)
+	ocrPool.release(key, client, err == nil, duration)
 	if err != nil {
-		return "", fmt.Errorf("failed to set image: %w", err)
+		return nil, err
 	}
+	result.Duration = duration
+
+	log.Printf("OCR completed. Extracted %d characters, %d words (mean confidence %.1f)",
+		len(result.Text), len(result.Words), result.MeanConfidence())
 
-	client.SetPageSegMode(gosseract.PSM_AUTO)
+	return result, nil
+}
+
+func recognizeWith(client *gosseract.Client, setImage func(*gosseract.Client) error) (*OCRResult, error) {
+	err := setImage(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set image: %w", err)
+	}
 
 	text, err := client.Text()
 	if err != nil {
-		return "", fmt.Errorf("failed to perform OCR: %w", err)
+		return nil, fmt.Errorf("failed to perform OCR: %w", err)
 	}
 
-	log.Printf("OCR completed. Extracted %d characters", len(text))
+	result := &OCRResult{Text: text}
 
-	return text, nil
+	// Word boxes reuse the recognition above; without them confidences fall back to pattern scores
+	boxes, err := client.GetBoundingBoxes(gosseract.RIL_WORD)
+	if err != nil {
+		log.Printf("Warning: failed to get word confidences: %v", err)
+	}
+	for _, box := range boxes {
+		result.Words = append(result.Words, OCRWord{Text: box.Word, Confidence: box.Confidence, Box: box.Box})
+	}
+	return result, nil
 }
@@ -14,6 +14,18 @@ func SetupRoutes(router *gin.Engine) {
 	budgetController := controllers.NewBudgetController()
 	subscriptionController := controllers.NewSubscriptionController()
 	dashboardController := controllers.NewDashboardController()
+	jobController := controllers.NewJobController()
+	reviewController := controllers.NewReviewController()
+	correctionController := controllers.NewCorrectionController()
+	statementController := controllers.NewStatementController()
+	importController := controllers.NewImportController()
+	exportController := controllers.NewExportController()
+	searchController := controllers.NewSearchController()
+	ruleController := controllers.NewRuleController()
+	payeeController := controllers.NewPayeeController()
+	categoryController := controllers.NewCategoryController()
+	accountController := controllers.NewAccountController()
+	attachmentController := controllers.NewAttachmentController()
 
 	v1 := router.Group("/api/v1")
 	{
@@ -22,33 +34,101 @@ func SetupRoutes(router *gin.Engine) {
 		v1.POST("/auth/login", authController.Login)
 		v1.GET("/auth/profile", utils.AuthMiddleware(), authController.GetProfile)
 
-		// Upload slip (supports multiple files)
-		v1.POST("/upload", uploadController.UploadSlip)
+		// Everything below is scoped to the authenticated user
+		protected := v1.Group("")
+		protected.Use(utils.AuthMiddleware())
+
+		// Upload slip (supports multiple files, ?async=true returns a job ID)
+		protected.POST("/upload", uploadController.UploadSlip)
+		protected.GET("/jobs/:id", jobController.GetByID)
+		protected.GET("/ocr/stats", uploadController.OCRStats)
+		protected.POST("/statements", statementController.Upload)
+
+		// Bank exports (CSV, OFX, QIF) reconciled against uploaded slips
+		protected.POST("/import", importController.Import)
+
+		// Review queue for incomplete or low-confidence OCR results
+		protected.GET("/review", reviewController.GetQueue)
+		protected.GET("/review/:id", reviewController.GetDraft)
+		protected.GET("/review/:id/image", reviewController.GetImage)
+		protected.PATCH("/review/:id", reviewController.Correct)
+		protected.POST("/review/:id/approve", reviewController.Approve)
+		protected.POST("/review/:id/reject", reviewController.Reject)
+
+		// Corrections to OCR-extracted values and extraction accuracy
+		protected.GET("/corrections", correctionController.GetAll)
+		protected.GET("/corrections/accuracy", correctionController.GetAccuracy)
 
 		// Transaction CRUD operations
-		v1.POST("/transactions", transactionController.Create)
-		v1.GET("/transactions", transactionController.GetAll)
-		v1.GET("/transactions/:id", transactionController.GetByID)
-		v1.PUT("/transactions/:id", transactionController.Update)
-		v1.PATCH("/transactions/:id", transactionController.Update)
-		v1.DELETE("/transactions/:id", transactionController.Delete)
+		protected.POST("/transactions", transactionController.Create)
+		protected.GET("/transactions", transactionController.GetAll)
+		protected.GET("/transactions/search", searchController.Search)
+		protected.GET("/transactions/:id", transactionController.GetByID)
+		protected.PUT("/transactions/:id", transactionController.Update)
+		protected.PATCH("/transactions/:id", transactionController.Update)
+		protected.DELETE("/transactions/:id", transactionController.Delete)
+		protected.PUT("/transactions/:id/splits", transactionController.SetSplits)
+		protected.DELETE("/transactions/:id/transfer", transactionController.UnlinkTransfer)
+
+		// Slip images and files attached to transactions
+		protected.GET("/transactions/:id/image", attachmentController.GetImage)
+		protected.GET("/transactions/:id/thumbnail", attachmentController.GetImageThumbnail)
+		protected.GET("/transactions/:id/attachments", attachmentController.List)
+		protected.POST("/transactions/:id/attachments", attachmentController.Upload)
+		protected.GET("/attachments/:id", attachmentController.Get)
+		protected.GET("/attachments/:id/thumbnail", attachmentController.GetThumbnail)
+		protected.DELETE("/attachments/:id", attachmentController.Delete)
+		protected.GET("/export", exportController.Export)
+
+		// Categorization rules
+		protected.POST("/rules", ruleController.Create)
+		protected.GET("/rules", ruleController.GetAll)
+		protected.POST("/rules/dry-run", ruleController.DryRun)
+		protected.POST("/rules/apply", ruleController.Apply)
+		protected.GET("/rules/:id", ruleController.GetByID)
+		protected.PUT("/rules/:id", ruleController.Update)
+		protected.DELETE("/rules/:id", ruleController.Delete)
+
+		// Category taxonomy
+		protected.POST("/categories", categoryController.Create)
+		protected.GET("/categories", categoryController.GetAll)
+		protected.GET("/categories/:id", categoryController.GetByID)
+		protected.PUT("/categories/:id", categoryController.Update)
+		protected.DELETE("/categories/:id", categoryController.Delete)
+
+		// Payee directory
+		protected.POST("/payees", payeeController.Create)
+		protected.GET("/payees", payeeController.GetAll)
+		protected.GET("/payees/:id", payeeController.GetByID)
+		protected.PUT("/payees/:id", payeeController.Update)
+		protected.DELETE("/payees/:id", payeeController.Delete)
+		protected.POST("/payees/:id/merge", payeeController.Merge)
+
+		// Own accounts and their balances
+		protected.POST("/accounts", accountController.Create)
+		protected.GET("/accounts", accountController.GetAll)
+		protected.GET("/accounts/:id", accountController.GetByID)
+		protected.PUT("/accounts/:id", accountController.Update)
+		protected.DELETE("/accounts/:id", accountController.Delete)
+		protected.GET("/accounts/:id/balance", accountController.GetBalance)
 
 		// Budget management
-		v1.POST("/budgets", budgetController.Create)
-		v1.GET("/budgets", budgetController.GetAll)
-		v1.GET("/budgets/status", budgetController.GetBudgetStatus)
-		v1.DELETE("/budgets/:id", budgetController.Delete)
+		protected.POST("/budgets", budgetController.Create)
+		protected.GET("/budgets", budgetController.GetAll)
+		protected.GET("/budgets/status", budgetController.GetBudgetStatus)
+		protected.DELETE("/budgets/:id", budgetController.Delete)
 
 		// Subscription management
-		v1.POST("/subscriptions", subscriptionController.Create)
-		v1.GET("/subscriptions", subscriptionController.GetAll)
-		v1.DELETE("/subscriptions/:id", subscriptionController.Delete)
+		protected.POST("/subscriptions", subscriptionController.Create)
+		protected.GET("/subscriptions", subscriptionController.GetAll)
+		protected.DELETE("/subscriptions/:id", subscriptionController.Delete)
 
 		// Dashboard & Analytics
-		v1.GET("/dashboard/monthly", dashboardController.GetMonthlyTrend)
-		v1.GET("/dashboard/yearly", dashboardController.GetYearlyComparison)
-		v1.GET("/dashboard/categories", dashboardController.GetCategoryBreakdown)
-		v1.GET("/summary/monthly", transactionController.GetMonthlySummary)
+		protected.GET("/dashboard/monthly", dashboardController.GetMonthlyTrend)
+		protected.GET("/dashboard/yearly", dashboardController.GetYearlyComparison)
+		protected.GET("/dashboard/categories", dashboardController.GetCategoryBreakdown)
+		protected.GET("/dashboard/payees", dashboardController.GetTopPayees)
+		protected.GET("/summary/monthly", transactionController.GetMonthlySummary)
 	}
 
 	// Health check endpoint - handle both GET and HEAD requests
@@ -0,0 +1,237 @@
+// Package search prepares transaction text for SQLite full-text search. Thai
+// is written without spaces between words, so Thai runs are split into
+// dictionary words before they reach the FTS5 tokenizer.
+package search
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Separator is placed between segmented Thai words. The FTS5 table declares it
+// as a token separator; being zero-width, it does not show in snippets.
+const Separator = "\u200b"
+
+//go:embed words_th.txt
+var baseWords string
+
+var (
+	dictionaryMu sync.RWMutex
+	dictionary   = map[string]bool{}
+	maxWordRunes int
+)
+
+func init() {
+	addWords(strings.NewReader(baseWords))
+}
+
+// LoadDictionary adds the words in file (one per line) to the embedded base
+// list, e.g. a full Thai word list
+func LoadDictionary(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open dictionary: %w", err)
+	}
+	defer f.Close()
+
+	added, err := addWords(f)
+	if err != nil {
+		return added, fmt.Errorf("failed to read dictionary %s: %w", file, err)
+	}
+	return added, nil
+}
+
+func addWords(r io.Reader) (int, error) {
+	dictionaryMu.Lock()
+	defer dictionaryMu.Unlock()
+
+	added := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") || dictionary[word] {
+			continue
+		}
+		dictionary[word] = true
+		added++
+		if n := utf8.RuneCountInString(word); n > maxWordRunes {
+			maxWordRunes = n
+		}
+	}
+	return added, scanner.Err()
+}
+
+// DictionarySize is the number of known words
+func DictionarySize() int {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	return len(dictionary)
+}
+
+// DictionaryFingerprint identifies the current word list, so an index built
+// with a different dictionary can be detected and rebuilt
+func DictionaryFingerprint() string {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+
+	words := make([]string, 0, len(dictionary))
+	for word := range dictionary {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	hash := sha1.New()
+	for _, word := range words {
+		io.WriteString(hash, word)
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func isThai(r rune) bool {
+	return r >= 0x0E01 && r <= 0x0E5B
+}
+
+// Segment returns text with every run of Thai characters split into words
+// joined by Separator. Other text is returned unchanged.
+func Segment(text string) string {
+	if !strings.ContainsFunc(text, isThai) {
+		return text
+	}
+
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isThai(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isThai(runes[j]) {
+			j++
+		}
+		b.WriteString(strings.Join(segmentThai(runes[i:j]), Separator))
+		i = j
+	}
+	return b.String()
+}
+
+// isTokenRune mirrors the FTS5 unicode61 tokenizer: letters, numbers and
+// combining marks (Thai vowels and tone marks) are part of tokens, everything
+// else, including Separator, separates them
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+}
+
+// Words returns the tokens FTS5 indexes for text after segmentation
+func Words(text string) []string {
+	return strings.FieldsFunc(Segment(text), func(r rune) bool { return !isTokenRune(r) })
+}
+
+// MatchQuery turns user input into an FTS5 query: each space-separated term
+// becomes a phrase of its segmented words, matched as a prefix, and all terms
+// must match. It returns "" when the input has no searchable words.
+func MatchQuery(input string) string {
+	var phrases []string
+	for _, term := range strings.Fields(input) {
+		words := Words(term)
+		if len(words) == 0 {
+			continue
+		}
+		phrases = append(phrases, `"`+strings.Join(words, " ")+`"*`)
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// canBreak reports whether a word may end before run[j]: not before a vowel or
+// tone mark that belongs to the previous consonant, nor after a leading vowel
+func canBreak(run []rune, j int) bool {
+	if j == 0 || j == len(run) {
+		return true
+	}
+	if r := run[j-1]; r >= 'เ' && r <= 'ไ' {
+		return false
+	}
+	r := run[j]
+	return !(unicode.Is(unicode.Mn, r) || r == 'ะ' || r == 'า' || r == 'ำ' || r == 'ๅ')
+}
+
+// segmentThai splits one Thai run by maximal matching: the split with the
+// fewest characters outside dictionary words, then the fewest words. Unknown
+// characters next to each other stay together as one word.
+func segmentThai(run []rune) []string {
+	type state struct {
+		unknown, words int
+		prev           int  // start of the last piece
+		known          bool // whether the last piece is a dictionary word
+		reached        bool
+	}
+	n := len(run)
+	best := make([]state, n+1)
+	best[0].reached = true
+
+	better := func(a state, unknown, words int) bool {
+		return !a.reached || unknown < a.unknown || (unknown == a.unknown && words < a.words)
+	}
+
+	for i := 0; i < n; i++ {
+		if !best[i].reached {
+			continue
+		}
+		from := best[i]
+
+		for length := 1; length <= maxWordRunes && i+length <= n; length++ {
+			j := i + length
+			if canBreak(run, j) && dictionary[string(run[i:j])] {
+				if better(best[j], from.unknown, from.words+1) {
+					best[j] = state{unknown: from.unknown, words: from.words + 1, prev: i, known: true, reached: true}
+				}
+			}
+		}
+
+		// One unknown cluster, merged into a preceding unknown piece
+		j := i + 1
+		for !canBreak(run, j) {
+			j++
+		}
+		words := from.words + 1
+		if i > 0 && !from.known {
+			words = from.words
+		}
+		if better(best[j], from.unknown+j-i, words) {
+			best[j] = state{unknown: from.unknown + j - i, words: words, prev: i, known: false, reached: true}
+		}
+	}
+
+	// Walk back, joining consecutive unknown characters
+	var pieces []string
+	for j := n; j > 0; {
+		s := best[j]
+		start := s.prev
+		if !s.known {
+			for start > 0 && !best[start].known {
+				start = best[start].prev
+			}
+		}
+		pieces = append(pieces, string(run[start:j]))
+		j = start
+	}
+	for l, r := 0, len(pieces)-1; l < r; l, r = l+1, r-1 {
+		pieces[l], pieces[r] = pieces[r], pieces[l]
+	}
+	return pieces
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "dictionary words", text: "ร้านกาแฟ", want: []string{"ร้าน", "กาแฟ"}},
+		{name: "compound in dictionary", text: "ค่าน้ำค่าไฟ", want: []string{"ค่าน้ำ", "ค่าไฟ"}},
+		{name: "unknown characters stay together", text: "ฟหกดกาแฟ", want: []string{"ฟหกด", "กาแฟ"}},
+		{name: "mixed with Latin", text: "โอนเงิน 7-Eleven", want: []string{"โอนเงิน", "7", "Eleven"}},
+		{name: "Latin only", text: "Coffee shop", want: []string{"Coffee", "shop"}},
+		{name: "punctuation only", text: " - ", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentKeepsNonThaiText(t *testing.T) {
+	if got := Segment("7-Eleven, Bangkok"); got != "7-Eleven, Bangkok" {
+		t.Errorf("Segment() = %q, want the text unchanged", got)
+	}
+	if got, want := Segment("ร้านกาแฟ 7-11"), "ร้าน"+Separator+"กาแฟ 7-11"; got != want {
+		t.Errorf("Segment() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: ""},
+		{name: "no searchable words", input: " * - ", want: ""},
+		{name: "one word", input: "กาแฟ", want: `"กาแฟ"*`},
+		{name: "Thai run becomes a phrase", input: "ร้านกาแฟ", want: `"ร้าน กาแฟ"*`},
+		{name: "terms are all required", input: "ร้าน กาแฟ", want: `"ร้าน"* AND "กาแฟ"*`},
+		{name: "punctuation splits a term into a phrase", input: "7-11", want: `"7 11"*`},
+		{name: "FTS5 syntax is quoted away", input: `"x" OR y*`, want: `"x"* AND "OR"* AND "y"*`},
+		{name: "NEAR and parentheses", input: "NEAR(a b)", want: `"NEAR a"* AND "b"*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchQuery(tt.input); got != tt.want {
+				t.Errorf("MatchQuery(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
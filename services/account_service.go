@@ -0,0 +1,628 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccountService struct{}
+
+func NewAccountService() *AccountService {
+	return &AccountService{}
+}
+
+// ErrInvalidAccount is returned for a transaction whose accounts cannot be used
+var ErrInvalidAccount = errors.New("invalid account")
+
+// accountMu serializes transfer pairing so concurrent uploads do not pair the
+// same transaction twice
+var accountMu sync.Mutex
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// minAccountDigits is how many unmasked digits two account numbers must share
+// to be taken as the same account
+const minAccountDigits = 4
+
+// accountDigits reduces an account number to its digits, with masked
+// positions as x: "xxx-x-x1234-x" becomes "xxxxx1234x"
+func accountDigits(number string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(number) {
+		switch {
+		case r >= '0' && r <= '9', r == 'x':
+			b.WriteRune(r)
+		case r == '*':
+			b.WriteRune('x')
+		case r >= '๐' && r <= '๙':
+			b.WriteRune('0' + r - '๐')
+		}
+	}
+	return b.String()
+}
+
+// sameAccount reports whether two account numbers, either of them masked, can
+// be the same account: they are as long as each other and agree on every digit
+// both show, of which there are at least minAccountDigits
+func sameAccount(a, b string) bool {
+	a, b = accountDigits(a), accountDigits(b)
+	if a == "" || len(a) != len(b) {
+		return false
+	}
+	shared := 0
+	for i := 0; i < len(a); i++ {
+		if a[i] == 'x' || b[i] == 'x' {
+			continue
+		}
+		if a[i] != b[i] {
+			return false
+		}
+		shared++
+	}
+	return shared >= minAccountDigits
+}
+
+// accountIndex is a user's accounts, for linking transactions to them
+type accountIndex []models.Account
+
+func loadAccounts(db *gorm.DB, userID uint) (accountIndex, error) {
+	var accounts accountIndex
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (idx accountIndex) byID(id uint) *models.Account {
+	for i := range idx {
+		if idx[i].ID == id {
+			return &idx[i]
+		}
+	}
+	return nil
+}
+
+// match returns the account a number printed on a slip refers to. When it
+// fits several accounts, bank picks between them.
+func (idx accountIndex) match(number, bank string) *models.Account {
+	var found []*models.Account
+	for i := range idx {
+		if sameAccount(idx[i].Number, number) {
+			found = append(found, &idx[i])
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	var atBank *models.Account
+	for _, account := range found {
+		if bank != "" && strings.EqualFold(account.Bank, bank) {
+			if atBank != nil {
+				return nil
+			}
+			atBank = account
+		}
+	}
+	return atBank
+}
+
+// byBank returns the user's only account at bank, nil when there are none or
+// several
+func (idx accountIndex) byBank(bank string) *models.Account {
+	var found *models.Account
+	for i := range idx {
+		if bank != "" && strings.EqualFold(idx[i].Bank, bank) {
+			if found != nil {
+				return nil
+			}
+			found = &idx[i]
+		}
+	}
+	return found
+}
+
+// ownBank is the bank of the user's side of a transaction when no account
+// number says so: a statement or import belongs to one of the user's
+// accounts, and a slip is issued by the sender's bank
+func ownBank(t *models.Transaction) string {
+	switch {
+	case t.Source == models.SourceStatement, t.Source == models.SourceImport:
+		return t.Bank
+	case t.Source == models.SourceSlip && t.Type == "expense":
+		return t.Bank
+	}
+	return ""
+}
+
+// link sets the account of a transaction that has none from its account
+// numbers, or its bank when that identifies the account. Money sent from one
+// of the user's accounts to another makes it a transfer.
+func (idx accountIndex) link(t *models.Transaction) {
+	if t.AccountID != nil || (t.Type != "income" && t.Type != "expense") {
+		return
+	}
+
+	sender := idx.match(t.SenderAccount, t.Bank)
+	receiver := idx.match(t.ReceiverAccount, "")
+	switch {
+	case sender != nil && receiver != nil && sender.ID != receiver.ID:
+		t.Type = "transfer"
+		t.AccountID, t.TransferAccountID = &sender.ID, &receiver.ID
+	case t.Type == "expense" && sender != nil:
+		t.AccountID = &sender.ID
+	case t.Type == "income" && receiver != nil:
+		t.AccountID = &receiver.ID
+	default:
+		if account := idx.byBank(ownBank(t)); account != nil {
+			t.AccountID = &account.ID
+		}
+	}
+}
+
+// linkAccounts checks the accounts of a new transaction belong to the user and
+// links it to them when none were given
+func linkAccounts(db *gorm.DB, t *models.Transaction) error {
+	t.SenderAccount = strings.TrimSpace(t.SenderAccount)
+	t.ReceiverAccount = strings.TrimSpace(t.ReceiverAccount)
+
+	accounts, err := loadAccounts(db, t.UserID)
+	if err != nil {
+		return err
+	}
+	for _, id := range []*uint{t.AccountID, t.TransferAccountID} {
+		if id != nil && accounts.byID(*id) == nil {
+			return fmt.Errorf("%w: account #%d not found", ErrInvalidAccount, *id)
+		}
+	}
+
+	switch t.Type {
+	case "transfer":
+		if t.AccountID == nil || t.TransferAccountID == nil {
+			return fmt.Errorf("%w: a transfer needs account_id and transfer_account_id", ErrInvalidAccount)
+		}
+		if *t.AccountID == *t.TransferAccountID {
+			return fmt.Errorf("%w: a transfer needs two different accounts", ErrInvalidAccount)
+		}
+	default:
+		if t.TransferAccountID != nil {
+			return fmt.Errorf("%w: only a transfer has a transfer_account_id", ErrInvalidAccount)
+		}
+	}
+
+	accounts.link(t)
+	return nil
+}
+
+// pairTransfer looks for the other half of a transaction between two of the
+// user's accounts: the opposite type for the same amount on another account,
+// within TransferMatchWindow days. The closest in time is taken, skipping any
+// whose account numbers name a different account. Both then become the same
+// transfer; the incoming half points at the outgoing one, which alone counts.
+func pairTransfer(db *gorm.DB, t *models.Transaction) error {
+	if t.AccountID == nil || t.TransferPeerID != nil || (t.Type != "income" && t.Type != "expense") {
+		return nil
+	}
+
+	accountMu.Lock()
+	defer accountMu.Unlock()
+
+	accounts, err := loadAccounts(db, t.UserID)
+	if err != nil {
+		return err
+	}
+
+	other := "income"
+	if t.Type == "income" {
+		other = "expense"
+	}
+	window := time.Duration(config.AppConfig.TransferMatchWindow) * 24 * time.Hour
+	var candidates []models.Transaction
+	result := db.Where("user_id = ? AND id != ? AND type = ? AND amount = ? AND account_id IS NOT NULL AND account_id != ? AND occurred_at BETWEEN ? AND ?",
+		t.UserID, t.ID, other, t.Amount, *t.AccountID,
+		t.OccurredAt.Add(-window), t.OccurredAt.Add(window)).Find(&candidates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to find transfer: %w", result.Error)
+	}
+
+	var peer *models.Transaction
+	for i := range candidates {
+		candidate := &candidates[i]
+		from, to := t, candidate
+		if t.Type == "income" {
+			from, to = candidate, t
+		}
+		if !accounts.consistent(from, to) {
+			continue
+		}
+		if peer == nil || closer(candidate.OccurredAt, peer.OccurredAt, t.OccurredAt) {
+			peer = candidate
+		}
+	}
+	if peer == nil {
+		return nil
+	}
+
+	from, to := t, peer
+	if t.Type == "income" {
+		from, to = peer, t
+	}
+	fromAccount, toAccount := *from.AccountID, *to.AccountID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, half := range []*models.Transaction{from, to} {
+			var peerID *uint
+			if half == to {
+				peerID = &from.ID
+			}
+			result := tx.Model(&models.Transaction{}).Where("id = ?", half.ID).Updates(map[string]interface{}{
+				"type":                "transfer",
+				"account_id":          fromAccount,
+				"transfer_account_id": toAccount,
+				"transfer_peer_id":    peerID,
+			})
+			if result.Error != nil {
+				return fmt.Errorf("failed to link transfer: %w", result.Error)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.Type = "transfer"
+	t.AccountID, t.TransferAccountID = &fromAccount, &toAccount
+	if t == to {
+		t.TransferPeerID = &peer.ID
+	}
+	log.Printf("Linked transactions #%d and #%d as a transfer", from.ID, to.ID)
+	return nil
+}
+
+// consistent reports whether money leaving through from could be what arrived
+// through to, as far as the account numbers on them tell
+func (idx accountIndex) consistent(from, to *models.Transaction) bool {
+	if account := idx.byID(*to.AccountID); from.ReceiverAccount != "" && (account == nil || !sameAccount(account.Number, from.ReceiverAccount)) {
+		return false
+	}
+	if account := idx.byID(*from.AccountID); to.SenderAccount != "" && (account == nil || !sameAccount(account.Number, to.SenderAccount)) {
+		return false
+	}
+	return true
+}
+
+// LinkAccounts links the user's income and expenses that have no account,
+// for example after an account is added, and pairs up the transfers
+func (s *AccountService) LinkAccounts(userID uint) error {
+	accounts, err := loadAccounts(config.DB, userID)
+	if err != nil || len(accounts) == 0 {
+		return err
+	}
+
+	var transactions []models.Transaction
+	result := config.DB.Where("user_id = ? AND account_id IS NULL AND type IN ?", userID, []string{"income", "expense"}).
+		Order("occurred_at ASC, id ASC").Find(&transactions)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get transactions: %w", result.Error)
+	}
+
+	linked := 0
+	for i := range transactions {
+		t := &transactions[i]
+		accounts.link(t)
+		if t.AccountID == nil {
+			continue
+		}
+		result := config.DB.Model(t).UpdateColumns(map[string]interface{}{
+			"type":                t.Type,
+			"account_id":          t.AccountID,
+			"transfer_account_id": t.TransferAccountID,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to link transaction #%d: %w", t.ID, result.Error)
+		}
+		linked++
+	}
+
+	// Pair once everything is linked, so either half can find the other
+	for i := range transactions {
+		t := &transactions[i]
+		if t.AccountID == nil || t.Type == "transfer" {
+			continue
+		}
+		// The other half may have been paired with an earlier row already
+		var current models.Transaction
+		if err := config.DB.First(&current, t.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload transaction #%d: %w", t.ID, err)
+		}
+		if err := pairTransfer(config.DB, &current); err != nil {
+			return err
+		}
+	}
+
+	if linked > 0 {
+		log.Printf("Linked %d transactions to accounts for user %d", linked, userID)
+	}
+	return nil
+}
+
+// UnlinkTransfer turns a transfer back into what it was recorded as: each half
+// of a paired transfer becomes an expense or income on its own account, and a
+// single transfer becomes an expense from its account
+func (s *AccountService) UnlinkTransfer(userID, id uint) (*models.Transaction, error) {
+	transactionService := NewTransactionService()
+	transaction, err := transactionService.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Type != "transfer" {
+		return nil, fmt.Errorf("%w: transaction #%d is not a transfer", ErrInvalidAccount, id)
+	}
+
+	accountMu.Lock()
+	defer accountMu.Unlock()
+
+	// Of a pair, the incoming half points at the outgoing one
+	expenseID, incomeID := transaction.ID, uint(0)
+	if transaction.TransferPeerID != nil {
+		expenseID, incomeID = *transaction.TransferPeerID, transaction.ID
+	} else {
+		var incoming models.Transaction
+		result := config.DB.Where("user_id = ? AND transfer_peer_id = ?", userID, transaction.ID).Limit(1).Find(&incoming)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to find transfer: %w", result.Error)
+		}
+		incomeID = incoming.ID
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transaction{}).Where("user_id = ? AND id = ?", userID, expenseID).Updates(map[string]interface{}{
+			"type":                "expense",
+			"transfer_account_id": nil,
+			"transfer_peer_id":    nil,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to unlink transfer: %w", result.Error)
+		}
+		if incomeID == 0 {
+			return nil
+		}
+		result = tx.Model(&models.Transaction{}).Where("user_id = ? AND id = ?", userID, incomeID).Updates(map[string]interface{}{
+			"type":                "income",
+			"account_id":          transaction.TransferAccountID,
+			"transfer_account_id": nil,
+			"transfer_peer_id":    nil,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to unlink transfer: %w", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactionService.GetByID(userID, id)
+}
+
+// validate normalizes an account and checks its number is not another
+// account's
+func (s *AccountService) validate(account *models.Account) error {
+	account.Name = strings.Join(strings.Fields(account.Name), " ")
+	account.Bank = strings.TrimSpace(account.Bank)
+	account.Number = strings.TrimSpace(account.Number)
+	account.Currency = strings.ToUpper(strings.TrimSpace(account.Currency))
+	if account.Currency == "" {
+		account.Currency = "THB"
+	}
+	if !currencyCode.MatchString(account.Currency) {
+		return fmt.Errorf("invalid currency %q. Must be a 3-letter code such as THB", account.Currency)
+	}
+	if account.Name == "" && account.Bank == "" && account.Number == "" {
+		return fmt.Errorf("name, bank or number is required")
+	}
+	if account.Number != "" && len(accountDigits(account.Number)) < minAccountDigits {
+		return fmt.Errorf("invalid number %q. Must have at least %d digits", account.Number, minAccountDigits)
+	}
+
+	accounts, err := loadAccounts(config.DB, account.UserID)
+	if err != nil {
+		return err
+	}
+	for _, other := range accounts {
+		if other.ID != account.ID && account.Number != "" && accountDigits(other.Number) == accountDigits(account.Number) {
+			return fmt.Errorf("number %q already belongs to account #%d", account.Number, other.ID)
+		}
+	}
+	return nil
+}
+
+func (s *AccountService) Create(account *models.Account) error {
+	if err := s.validate(account); err != nil {
+		return err
+	}
+	if err := config.DB.Create(account).Error; err != nil {
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+
+	if err := s.LinkAccounts(account.UserID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// AccountBalance is an account with its current balance
+type AccountBalance struct {
+	models.Account
+	Balance float64 `json:"balance"`
+}
+
+func (s *AccountService) GetAll(userID uint) ([]AccountBalance, error) {
+	var accounts []models.Account
+	result := config.DB.Where("user_id = ?", userID).Order("id ASC").Find(&accounts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", result.Error)
+	}
+
+	balances := make([]AccountBalance, 0, len(accounts))
+	for _, account := range accounts {
+		change, err := sumAccountChange(accountLines(userID, account.ID), account.ID)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, AccountBalance{Account: account, Balance: account.OpeningBalance + change})
+	}
+	return balances, nil
+}
+
+func (s *AccountService) GetByID(userID, id uint) (*models.Account, error) {
+	var account models.Account
+	result := config.DB.Where("user_id = ?", userID).First(&account, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("account not found: %w", result.Error)
+	}
+	return &account, nil
+}
+
+// Update replaces an account's details
+func (s *AccountService) Update(userID, id uint, account *models.Account) (*models.Account, error) {
+	existing, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	account.ID = existing.ID
+	account.UserID = userID
+	account.CreatedAt = existing.CreatedAt
+	if err := s.validate(account); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Save(account).Error; err != nil {
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+
+	if err := s.LinkAccounts(userID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return account, nil
+}
+
+// Delete removes an account and unlinks its income and expenses. Accounts
+// with transfers are kept, since the transfers would lose a side.
+func (s *AccountService) Delete(userID, id uint) error {
+	var transfers int64
+	result := config.DB.Model(&models.Transaction{}).
+		Where("user_id = ? AND type = ? AND (account_id = ? OR transfer_account_id = ?)", userID, "transfer", id, id).
+		Count(&transfers)
+	if result.Error != nil {
+		return fmt.Errorf("failed to check transfers: %w", result.Error)
+	}
+	if transfers > 0 {
+		return fmt.Errorf("account has %d transfers; unlink them first", transfers)
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&models.Account{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete account: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("account not found")
+		}
+
+		result = tx.Model(&models.Transaction{}).Where("user_id = ? AND account_id = ?", userID, id).
+			UpdateColumn("account_id", nil)
+		if result.Error != nil {
+			return fmt.Errorf("failed to unlink transactions: %w", result.Error)
+		}
+		return nil
+	})
+}
+
+// accountLines selects the transactions that move money in or out of an
+// account, leaving out the incoming half of transfers recorded twice
+func accountLines(userID, accountID uint) *gorm.DB {
+	return userTransactions(userID).
+		Where("(account_id = ? OR transfer_account_id = ?)", accountID, accountID).
+		Where("transfer_peer_id IS NULL")
+}
+
+// accountChange is the SQL for how much a transaction changed the account ?:
+// income and transfers in add to it, expenses and transfers out take from it
+const accountChange = "CASE WHEN type = 'income' THEN amount WHEN type = 'expense' THEN -amount " +
+	"WHEN transfer_account_id = ? THEN amount ELSE -amount END"
+
+// sumAccountChange totals how much the transactions selected by q changed
+// the account
+func sumAccountChange(q *gorm.DB, accountID uint) (float64, error) {
+	var change float64
+	row := q.Select("COALESCE(SUM("+accountChange+"), 0)", accountID).Row()
+	if err := row.Scan(&change); err != nil {
+		return 0, fmt.Errorf("failed to total account: %w", err)
+	}
+	return change, nil
+}
+
+// LedgerEntry is a transaction in an account's ledger
+type LedgerEntry struct {
+	Transaction models.Transaction `json:"transaction"`
+	Change      float64            `json:"change"`  // signed: what the transaction added to the account
+	Balance     float64            `json:"balance"` // running balance after the transaction
+}
+
+// AccountLedger is an account's transactions in a period with running balances
+type AccountLedger struct {
+	Account        models.Account `json:"account"`
+	OpeningBalance float64        `json:"opening_balance"` // before the first entry
+	ClosingBalance float64        `json:"closing_balance"` // after the last entry
+	Entries        []LedgerEntry  `json:"entries"`
+}
+
+// Ledger lists an account's transactions in [from, to) in the order they
+// happened, with the balance after each. Either bound may be zero.
+func (s *AccountService) Ledger(userID, id uint, from, to time.Time) (*AccountLedger, error) {
+	account, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &AccountLedger{Account: *account, OpeningBalance: account.OpeningBalance, Entries: []LedgerEntry{}}
+	if !from.IsZero() {
+		before, err := sumAccountChange(accountLines(userID, id).Where("occurred_at < ?", from), id)
+		if err != nil {
+			return nil, err
+		}
+		ledger.OpeningBalance += before
+	}
+
+	q := accountLines(userID, id)
+	if !from.IsZero() {
+		q = q.Where("occurred_at >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("occurred_at < ?", to)
+	}
+	var transactions []models.Transaction
+	if result := q.Order("occurred_at ASC, id ASC").Find(&transactions); result.Error != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", result.Error)
+	}
+
+	balance := ledger.OpeningBalance
+	for _, t := range transactions {
+		change := t.Amount
+		if t.Type == "expense" || (t.Type == "transfer" && (t.TransferAccountID == nil || *t.TransferAccountID != id)) {
+			change = -t.Amount
+		}
+		balance += change
+		ledger.Entries = append(ledger.Entries, LedgerEntry{Transaction: t, Change: change, Balance: balance})
+	}
+	ledger.ClosingBalance = balance
+	return ledger, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+
+	"ocr-api/models"
+)
+
+func TestAccountDigits(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{number: "", want: ""},
+		{number: "123-4-56789-0", want: "1234567890"},
+		{number: "xxx-x-x1234-x", want: "xxxxx1234x"},
+		{number: "XXX-X-X1234-X", want: "xxxxx1234x"},
+		{number: "***-*-*1234-*", want: "xxxxx1234x"},
+		{number: "๑๒๓-๔-๕๖๗๘๙-๐", want: "1234567890"},
+		{number: "KBank 1234", want: "1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := accountDigits(tt.number); got != tt.want {
+				t.Errorf("accountDigits(%q) = %q, want %q", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "masked against full number", a: "xxx-x-x1234-x", b: "123-4-51234-0", want: true},
+		{name: "masked against a different full number", a: "xxx-x-x1235-x", b: "123-4-51234-0", want: false},
+		{name: "full numbers", a: "123-4-51234-0", b: "1234512340", want: true},
+		{name: "different length", a: "xx-x-x1234-x", b: "123-4-51234-0", want: false},
+		{name: "fewer than 4 shared digits", a: "xxx-x-xx234-x", b: "123-4-51234-0", want: false},
+		{name: "Thai digits", a: "๑๒๓-๔-๕๑๒๓๔-๐", b: "xxx-x-x1234-x", want: true},
+		{name: "both masked alike", a: "xxx-x-x1234-x", b: "***-*-*1234-*", want: true},
+		{name: "empty", a: "", b: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameAccount(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameAccount(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := sameAccount(tt.b, tt.a); got != tt.want {
+				t.Errorf("sameAccount(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountIndexConsistent(t *testing.T) {
+	accounts := accountIndex{
+		{ID: 1, Number: "123-4-51234-0"},
+		{ID: 2, Number: "987-6-54321-0"},
+	}
+	id := func(n uint) *uint { return &n }
+
+	tests := []struct {
+		name            string
+		toAccount       uint
+		receiverAccount string // printed on the expense half
+		senderAccount   string // printed on the income half
+		want            bool
+	}{
+		{name: "no account numbers printed", toAccount: 2, want: true},
+		{name: "expense names the receiving account", toAccount: 2, receiverAccount: "xxx-x-x4321-x", want: true},
+		{name: "expense names another account", toAccount: 2, receiverAccount: "xxx-x-x9999-x", want: false},
+		{name: "income names the sending account", toAccount: 2, senderAccount: "xxx-x-x1234-x", want: true},
+		{name: "income names another account", toAccount: 2, senderAccount: "xxx-x-x4321-x", want: false},
+		{name: "both halves agree", toAccount: 2, receiverAccount: "xxx-x-x4321-x", senderAccount: "xxx-x-x1234-x", want: true},
+		{name: "receiving account unknown", toAccount: 3, receiverAccount: "xxx-x-x4321-x", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := &models.Transaction{Type: "expense", AccountID: id(1), ReceiverAccount: tt.receiverAccount}
+			to := &models.Transaction{Type: "income", AccountID: id(tt.toAccount), SenderAccount: tt.senderAccount}
+			if got := accounts.consistent(from, to); got != tt.want {
+				t.Errorf("consistent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+type AttachmentService struct{}
+
+func NewAttachmentService() *AttachmentService {
+	return &AttachmentService{}
+}
+
+var (
+	// ErrAttachmentType is returned for files that are not JPEG, PNG or PDF
+	ErrAttachmentType = errors.New("unsupported attachment type")
+	// ErrNoThumbnail is returned for attachments that are not images
+	ErrNoThumbnail = errors.New("attachment has no thumbnail")
+)
+
+// attachmentTypes are the content types kept as attachments
+var attachmentTypes = []string{"image/jpeg", "image/png", "application/pdf"}
+
+// attachmentKinds can be chosen when attaching a file
+var attachmentKinds = []string{models.AttachmentSlip, models.AttachmentReceipt, models.AttachmentInvoice, models.AttachmentOther}
+
+// ThumbnailSizes are the thumbnail widths that can be asked for. Each is made
+// the first time it is asked for and kept.
+var ThumbnailSizes = []int{160, 320, 640}
+
+// DefaultThumbnailSize is the thumbnail width when none is asked for
+const DefaultThumbnailSize = 320
+
+// attachmentMu serializes storing and removing content. It is held from
+// storing content until its attachment row is saved, so content is not
+// removed while another attachment is being added for it.
+var attachmentMu sync.Mutex
+
+// storedFile is content in the attachment store that attachments can refer to
+type storedFile struct {
+	Hash        string
+	ContentType string
+	Size        int64
+}
+
+// attachmentDir is the root of the store under UploadDir
+func attachmentDir() string {
+	return filepath.Join(config.AppConfig.UploadDir, "attachments")
+}
+
+// contentPath is where the content with a hash is stored: attachments/ab/abcd…
+func contentPath(hash string) string {
+	return filepath.Join(attachmentDir(), hash[:2], hash)
+}
+
+func thumbnailPath(hash string, size int) string {
+	return filepath.Join(attachmentDir(), "thumbnails", hash[:2], fmt.Sprintf("%s_%d.jpg", hash, size))
+}
+
+// storeContent copies r into the store under its SHA-256 hash and calls
+// record to save the attachment that refers to it. Content that is already
+// stored is not written again; content written for a record that fails is
+// removed again.
+func storeContent(r io.Reader, record func(stored *storedFile) error) (*storedFile, error) {
+	if err := os.MkdirAll(attachmentDir(), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+	temp, err := os.CreateTemp(attachmentDir(), "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	// The content type is sniffed from the first bytes; the file name may lie
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	head = head[:n]
+	contentType := strings.Split(http.DetectContentType(head), ";")[0]
+	if !utils.Contains(attachmentTypes, contentType) {
+		return nil, fmt.Errorf("%w: %s. Allowed types: jpg, png, pdf", ErrAttachmentType, contentType)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, hasher), io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	stored := &storedFile{Hash: hex.EncodeToString(hasher.Sum(nil)), ContentType: contentType, Size: size}
+
+	attachmentMu.Lock()
+	defer attachmentMu.Unlock()
+
+	path := contentPath(stored.Hash)
+	if _, err := os.Stat(path); err != nil {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create attachment directory: %w", err)
+		}
+		if err := os.Rename(temp.Name(), path); err != nil {
+			return nil, fmt.Errorf("failed to store attachment: %w", err)
+		}
+	}
+	if err := record(stored); err != nil {
+		removeUnreferenced(stored.Hash)
+		return nil, err
+	}
+	return stored, nil
+}
+
+// releaseContent removes stored content and its thumbnails once no attachment
+// refers to it
+func releaseContent(hash string) {
+	attachmentMu.Lock()
+	defer attachmentMu.Unlock()
+	removeUnreferenced(hash)
+}
+
+// removeUnreferenced is releaseContent for callers holding attachmentMu
+func removeUnreferenced(hash string) {
+	var count int64
+	if err := config.DB.Model(&models.Attachment{}).Where("hash = ?", hash).Count(&count).Error; err != nil {
+		log.Printf("Warning: failed to check attachment %s: %v", hash, err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	paths := []string{contentPath(hash)}
+	for _, size := range ThumbnailSizes {
+		paths = append(paths, thumbnailPath(hash, size))
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to remove attachment file %s: %v", path, err)
+		}
+	}
+}
+
+// attachSlip keeps an uploaded slip as the attachment of the transaction or
+// draft saved from it. Nothing is kept when slip images are not kept or the
+// slip was saved as neither.
+func attachSlip(userID uint, imageData []byte, filename string, transaction *models.Transaction, draft *models.DraftTransaction) {
+	if !config.AppConfig.KeepSlipImages || (transaction == nil && draft == nil) {
+		return
+	}
+
+	_, err := storeContent(bytes.NewReader(imageData), func(stored *storedFile) error {
+		attachment := &models.Attachment{
+			UserID:      userID,
+			Kind:        models.AttachmentSlip,
+			Hash:        stored.Hash,
+			Filename:    filename,
+			ContentType: stored.ContentType,
+			Size:        stored.Size,
+		}
+		if transaction != nil {
+			attachment.TransactionID = &transaction.ID
+		} else {
+			attachment.DraftID = &draft.ID
+		}
+		if err := config.DB.Create(attachment).Error; err != nil {
+			return fmt.Errorf("failed to save slip attachment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: failed to keep slip image: %v", err)
+	}
+}
+
+// Add attaches an uploaded file to a transaction
+func (s *AttachmentService) Add(userID, transactionID uint, kind string, file *multipart.FileHeader) (*models.Attachment, error) {
+	if kind == "" {
+		kind = models.AttachmentReceipt
+	}
+	if !utils.Contains(attachmentKinds, kind) {
+		return nil, fmt.Errorf("invalid kind %q. Must be one of %s", kind, strings.Join(attachmentKinds, ", "))
+	}
+
+	opened, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	defer opened.Close()
+
+	var attachment *models.Attachment
+	_, err = storeContent(opened, func(stored *storedFile) error {
+		attachment = &models.Attachment{
+			UserID:        userID,
+			TransactionID: &transactionID,
+			Kind:          kind,
+			Hash:          stored.Hash,
+			Filename:      filepath.Base(file.Filename),
+			ContentType:   stored.ContentType,
+			Size:          stored.Size,
+		}
+		if err := config.DB.Create(attachment).Error; err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// List returns a transaction's attachments, oldest first
+func (s *AttachmentService) List(userID, transactionID uint) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	result := config.DB.Where("user_id = ? AND transaction_id = ?", userID, transactionID).Order("id ASC").Find(&attachments)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", result.Error)
+	}
+	return attachments, nil
+}
+
+func (s *AttachmentService) GetByID(userID, id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	result := config.DB.Where("user_id = ?", userID).First(&attachment, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("attachment not found: %w", result.Error)
+	}
+	return &attachment, nil
+}
+
+// SlipImage returns the image of a transaction's slip: its slip attachment,
+// or else its first image attachment
+func (s *AttachmentService) SlipImage(userID, transactionID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	result := config.DB.Where("user_id = ? AND transaction_id = ? AND content_type LIKE ?", userID, transactionID, "image/%").
+		Order(fmt.Sprintf("CASE WHEN kind = '%s' THEN 0 ELSE 1 END, id ASC", models.AttachmentSlip)).
+		First(&attachment)
+	if result.Error != nil {
+		return nil, fmt.Errorf("attachment not found: %w", result.Error)
+	}
+	return &attachment, nil
+}
+
+// Path is where an attachment's content is stored
+func (s *AttachmentService) Path(attachment *models.Attachment) string {
+	return contentPath(attachment.Hash)
+}
+
+// Thumbnail returns the path of an image attachment's thumbnail no wider or
+// taller than size, making it if needed
+func (s *AttachmentService) Thumbnail(attachment *models.Attachment, size int) (string, error) {
+	if !strings.HasPrefix(attachment.ContentType, "image/") {
+		return "", ErrNoThumbnail
+	}
+	path := thumbnailPath(attachment.Hash, size)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	img, err := imaging.Open(contentPath(attachment.Hash), imaging.AutoOrientation(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to open attachment: %w", err)
+	}
+	thumbnail := imaging.Fit(img, size, size, imaging.Lanczos)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+	// Written aside and renamed, so a concurrent request never serves half a file
+	temp := fmt.Sprintf("%s.%d.tmp.jpg", path, time.Now().UnixNano())
+	if err := imaging.Save(thumbnail, temp); err != nil {
+		return "", fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return "", fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+	return path, nil
+}
+
+func (s *AttachmentService) Delete(userID, id uint) error {
+	attachment, err := s.GetByID(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := config.DB.Delete(attachment).Error; err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	releaseContent(attachment.Hash)
+	return nil
+}
+
+// moveDraftAttachments gives an approved draft's slip to its transaction
+func moveDraftAttachments(draftID, transactionID uint) error {
+	result := config.DB.Model(&models.Attachment{}).Where("draft_id = ?", draftID).
+		Updates(map[string]interface{}{"transaction_id": transactionID, "draft_id": nil})
+	if result.Error != nil {
+		return fmt.Errorf("failed to move draft attachments: %w", result.Error)
+	}
+	return nil
+}
+
+// removeDraftAttachments removes the slip of a rejected draft
+func removeDraftAttachments(draftID uint) {
+	var attachments []models.Attachment
+	if err := config.DB.Where("draft_id = ?", draftID).Find(&attachments).Error; err != nil {
+		log.Printf("Warning: failed to find draft attachments: %v", err)
+		return
+	}
+	for i := range attachments {
+		if err := config.DB.Delete(&attachments[i]).Error; err != nil {
+			log.Printf("Warning: failed to delete attachment #%d: %v", attachments[i].ID, err)
+			continue
+		}
+		releaseContent(attachments[i].Hash)
+	}
+}
+
+// PurgeAttachments removes attachments past ATTACHMENT_RETENTION_DAYS and
+// those of deleted transactions and rejected drafts
+func PurgeAttachments() {
+	q := config.DB.
+		Where("transaction_id IN (SELECT id FROM transactions WHERE deleted_at IS NOT NULL)").
+		Or("draft_id IN (SELECT id FROM draft_transactions WHERE deleted_at IS NOT NULL OR status = ?)", models.DraftRejected)
+	if days := config.AppConfig.AttachmentRetention; days > 0 {
+		q = q.Or("created_at < ?", time.Now().AddDate(0, 0, -days))
+	}
+
+	var attachments []models.Attachment
+	if err := q.Find(&attachments).Error; err != nil {
+		log.Printf("Warning: failed to find expired attachments: %v", err)
+		return
+	}
+	for i := range attachments {
+		if err := config.DB.Delete(&attachments[i]).Error; err != nil {
+			log.Printf("Warning: failed to delete attachment #%d: %v", attachments[i].ID, err)
+			continue
+		}
+		releaseContent(attachments[i].Hash)
+	}
+	if len(attachments) > 0 {
+		log.Printf("Purged %d attachments", len(attachments))
+	}
+}
+
+// StartAttachmentPurge purges attachments now and then daily
+func StartAttachmentPurge() {
+	PurgeAttachments()
+	go func() {
+		for range time.Tick(24 * time.Hour) {
+			PurgeAttachments()
+		}
+	}()
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"log"
 	"ocr-api/config"
 	"ocr-api/models"
 	"ocr-api/utils"
@@ -39,6 +40,10 @@ func (s *AuthService) Register(username, email, password, fullName string) (*mod
 		return nil, errors.New("failed to create user")
 	}
 
+	if err := NewCategoryService().SeedDefaults(user.ID); err != nil {
+		log.Printf("Warning: failed to create default categories for user %d: %v", user.ID, err)
+	}
+
 	return user, nil
 }
 
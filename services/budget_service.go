@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"ocr-api/config"
 	"ocr-api/models"
+	"ocr-api/utils"
 )
 
 type BudgetService struct{}
@@ -13,10 +14,17 @@ func NewBudgetService() *BudgetService {
 }
 
 func (s *BudgetService) Create(budget *models.Budget) error {
+	if err := linkCategory(config.DB, budget.UserID, "expense", &budget.Category, &budget.CategoryID); err != nil {
+		return err
+	}
+	if budget.CategoryID == nil {
+		return fmt.Errorf("category is required")
+	}
+
 	// Check if budget already exists for this category/month/year
 	var existing models.Budget
-	result := config.DB.Where("category = ? AND month = ? AND year = ?",
-		budget.Category, budget.Month, budget.Year).First(&existing)
+	result := config.DB.Where("user_id = ? AND category = ? AND month = ? AND year = ?",
+		budget.UserID, budget.Category, budget.Month, budget.Year).First(&existing)
 
 	if result.Error == nil {
 		return fmt.Errorf("budget already exists for %s in %d/%d", budget.Category, budget.Month, budget.Year)
@@ -29,18 +37,18 @@ func (s *BudgetService) Create(budget *models.Budget) error {
 	return nil
 }
 
-func (s *BudgetService) GetAll() ([]models.Budget, error) {
+func (s *BudgetService) GetAll(userID uint) ([]models.Budget, error) {
 	var budgets []models.Budget
-	result := config.DB.Order("year DESC, month DESC, category ASC").Find(&budgets)
+	result := config.DB.Where("user_id = ?", userID).Order("year DESC, month DESC, category ASC").Find(&budgets)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get budgets: %w", result.Error)
 	}
 	return budgets, nil
 }
 
-func (s *BudgetService) GetByMonthYear(month, year int) ([]models.Budget, error) {
+func (s *BudgetService) GetByMonthYear(userID uint, month, year int) ([]models.Budget, error) {
 	var budgets []models.Budget
-	result := config.DB.Where("month = ? AND year = ?", month, year).
+	result := config.DB.Where("user_id = ? AND month = ? AND year = ?", userID, month, year).
 		Order("category ASC").Find(&budgets)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get budgets: %w", result.Error)
@@ -48,22 +56,26 @@ func (s *BudgetService) GetByMonthYear(month, year int) ([]models.Budget, error)
 	return budgets, nil
 }
 
-func (s *BudgetService) GetByID(id uint) (*models.Budget, error) {
+func (s *BudgetService) GetByID(userID, id uint) (*models.Budget, error) {
 	var budget models.Budget
-	result := config.DB.First(&budget, id)
+	result := config.DB.Where("user_id = ?", userID).First(&budget, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("budget not found: %w", result.Error)
 	}
 	return &budget, nil
 }
 
-func (s *BudgetService) Update(id uint, updates map[string]interface{}) (*models.Budget, error) {
+func (s *BudgetService) Update(userID, id uint, updates map[string]interface{}) (*models.Budget, error) {
 	var budget models.Budget
-	result := config.DB.First(&budget, id)
+	result := config.DB.Where("user_id = ?", userID).First(&budget, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("budget not found: %w", result.Error)
 	}
 
+	if err := linkCategoryUpdate(userID, "expense", updates); err != nil {
+		return nil, err
+	}
+
 	result = config.DB.Model(&budget).Updates(updates)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update budget: %w", result.Error)
@@ -72,8 +84,8 @@ func (s *BudgetService) Update(id uint, updates map[string]interface{}) (*models
 	return &budget, nil
 }
 
-func (s *BudgetService) Delete(id uint) error {
-	result := config.DB.Delete(&models.Budget{}, id)
+func (s *BudgetService) Delete(userID, id uint) error {
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.Budget{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete budget: %w", result.Error)
 	}
@@ -85,6 +97,7 @@ func (s *BudgetService) Delete(id uint) error {
 
 type BudgetStatus struct {
 	Category     string  `json:"category"`
+	CategoryID   *uint   `json:"category_id,omitempty"`
 	MonthlyLimit float64 `json:"monthly_limit"`
 	Spent        float64 `json:"spent"`
 	Remaining    float64 `json:"remaining"`
@@ -92,24 +105,31 @@ type BudgetStatus struct {
 	Status       string  `json:"status"` // ok, warning, exceeded
 }
 
-func (s *BudgetService) GetBudgetStatus(month, year int) ([]BudgetStatus, error) {
-	budgets, err := s.GetByMonthYear(month, year)
+func (s *BudgetService) GetBudgetStatus(userID uint, month, year int) ([]BudgetStatus, error) {
+	budgets, err := s.GetByMonthYear(userID, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadCategories(config.DB, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	var statuses []BudgetStatus
+	start, end := utils.MonthRange(year, month)
 
 	for _, budget := range budgets {
-		// Calculate spent for this category in this month
+		// Calculate spent for this category and its subcategories in this
+		// month, counting split transactions by line
+		query := categoryLines(userID, TransactionFilter{From: start, To: end, Type: "expense"})
+		if budget.CategoryID != nil {
+			query = query.Where("category_id IN ?", index.descendants(*budget.CategoryID))
+		} else {
+			query = query.Where("category = ?", budget.Category)
+		}
 		var spent float64
-		monthStr := fmt.Sprintf("%02d", month)
-		yearStr := fmt.Sprintf("%d", year)
-		pattern := fmt.Sprintf("%%/%s/%s", monthStr, yearStr)
-
-		config.DB.Model(&models.Transaction{}).
-			Where("type = ? AND category = ? AND date LIKE ?", "expense", budget.Category, pattern).
-			Select("COALESCE(SUM(amount), 0)").Scan(&spent)
+		query.Select("COALESCE(SUM(amount), 0)").Scan(&spent)
 
 		remaining := budget.MonthlyLimit - spent
 		percentUsed := 0.0
@@ -126,6 +146,7 @@ func (s *BudgetService) GetBudgetStatus(month, year int) ([]BudgetStatus, error)
 
 		statuses = append(statuses, BudgetStatus{
 			Category:     budget.Category,
+			CategoryID:   budget.CategoryID,
 			MonthlyLimit: budget.MonthlyLimit,
 			Spent:        spent,
 			Remaining:    remaining,
@@ -0,0 +1,628 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type CategoryService struct{}
+
+func NewCategoryService() *CategoryService {
+	return &CategoryService{}
+}
+
+// categoryMu serializes category changes so concurrent imports do not create
+// the same category twice
+var categoryMu sync.Mutex
+
+// Keys of the built-in categories. Code refers to these rather than to
+// labels, so a user renaming "บันเทิง" still gets Netflix filed under it.
+const (
+	CategoryFood            = "food"
+	CategoryDrinks          = "drinks"
+	CategoryGroceries       = "groceries"
+	CategoryTransport       = "transport"
+	CategoryFuel            = "fuel"
+	CategoryPublicTransport = "public_transport"
+	CategoryShopping        = "shopping"
+	CategoryHousing         = "housing"
+	CategoryRent            = "rent"
+	CategoryUtilities       = "utilities"
+	CategoryHealth          = "health"
+	CategoryEducation       = "education"
+	CategorySubscriptions   = "subscriptions"
+	CategoryEntertainment   = "entertainment"
+	CategoryCloud           = "cloud"
+	CategorySoftware        = "software"
+	CategoryOtherExpense    = "other_expense"
+	CategorySalary          = "salary"
+	CategoryBonus           = "bonus"
+	CategoryInterest        = "interest"
+	CategoryOtherIncome     = "other_income"
+)
+
+type defaultCategory struct {
+	Key, Parent, Name, NameEN, Kind, Icon, Color string
+}
+
+// defaultCategories is the taxonomy every new user starts with, parents first
+var defaultCategories = []defaultCategory{
+	{Key: CategoryFood, Name: "อาหาร", NameEN: "Food", Kind: "expense", Icon: "🍜", Color: "#F97316"},
+	{Key: CategoryDrinks, Parent: CategoryFood, Name: "เครื่องดื่ม", NameEN: "Drinks", Kind: "expense", Icon: "☕", Color: "#FB923C"},
+	{Key: CategoryGroceries, Parent: CategoryFood, Name: "ของสด", NameEN: "Groceries", Kind: "expense", Icon: "🥬", Color: "#FDBA74"},
+	{Key: CategoryTransport, Name: "เดินทาง", NameEN: "Transport", Kind: "expense", Icon: "🚗", Color: "#3B82F6"},
+	{Key: CategoryFuel, Parent: CategoryTransport, Name: "น้ำมัน", NameEN: "Fuel", Kind: "expense", Icon: "⛽", Color: "#60A5FA"},
+	{Key: CategoryPublicTransport, Parent: CategoryTransport, Name: "ขนส่งสาธารณะ", NameEN: "Public transport", Kind: "expense", Icon: "🚇", Color: "#93C5FD"},
+	{Key: CategoryShopping, Name: "ช้อปปิ้ง", NameEN: "Shopping", Kind: "expense", Icon: "🛍️", Color: "#EC4899"},
+	{Key: CategoryHousing, Name: "ที่อยู่อาศัย", NameEN: "Housing", Kind: "expense", Icon: "🏠", Color: "#8B5CF6"},
+	{Key: CategoryRent, Parent: CategoryHousing, Name: "ค่าเช่า", NameEN: "Rent", Kind: "expense", Icon: "🔑", Color: "#A78BFA"},
+	{Key: CategoryUtilities, Parent: CategoryHousing, Name: "ค่าน้ำค่าไฟ", NameEN: "Utilities", Kind: "expense", Icon: "💡", Color: "#C4B5FD"},
+	{Key: CategoryHealth, Name: "สุขภาพ", NameEN: "Health", Kind: "expense", Icon: "💊", Color: "#EF4444"},
+	{Key: CategoryEducation, Name: "การศึกษา", NameEN: "Education", Kind: "expense", Icon: "📚", Color: "#14B8A6"},
+	{Key: CategorySubscriptions, Name: "สมาชิก", NameEN: "Subscriptions", Kind: "expense", Icon: "🔁", Color: "#6366F1"},
+	{Key: CategoryEntertainment, Parent: CategorySubscriptions, Name: "บันเทิง", NameEN: "Entertainment", Kind: "expense", Icon: "🎬", Color: "#818CF8"},
+	{Key: CategoryCloud, Parent: CategorySubscriptions, Name: "คลาวด์", NameEN: "Cloud storage", Kind: "expense", Icon: "☁️", Color: "#A5B4FC"},
+	{Key: CategorySoftware, Parent: CategorySubscriptions, Name: "ซอฟต์แวร์", NameEN: "Software", Kind: "expense", Icon: "💻", Color: "#C7D2FE"},
+	{Key: CategoryOtherExpense, Name: "อื่นๆ", NameEN: "Other", Kind: "expense", Icon: "📦", Color: "#9CA3AF"},
+	{Key: CategorySalary, Name: "เงินเดือน", NameEN: "Salary", Kind: "income", Icon: "💼", Color: "#22C55E"},
+	{Key: CategoryBonus, Name: "โบนัส", NameEN: "Bonus", Kind: "income", Icon: "🎁", Color: "#4ADE80"},
+	{Key: CategoryInterest, Name: "ดอกเบี้ย", NameEN: "Interest", Kind: "income", Icon: "🏦", Color: "#86EFAC"},
+	{Key: CategoryOtherIncome, Name: "รายได้อื่น", NameEN: "Other income", Kind: "income", Icon: "💰", Color: "#BBF7D0"},
+}
+
+// defaultCategoryName is the Thai label of a built-in category
+func defaultCategoryName(key string) string {
+	for _, d := range defaultCategories {
+		if d.Key == key {
+			return d.Name
+		}
+	}
+	return ""
+}
+
+// defaultCategoryByLabel finds the built-in category with the given Thai or English label
+func defaultCategoryByLabel(label string) *defaultCategory {
+	for i, d := range defaultCategories {
+		if strings.EqualFold(d.Name, label) || strings.EqualFold(d.NameEN, label) {
+			return &defaultCategories[i]
+		}
+	}
+	return nil
+}
+
+var categoryColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// categoryIndex is a user's category tree
+type categoryIndex struct {
+	categories []*models.Category
+	byID       map[uint]*models.Category
+	children   map[uint][]*models.Category
+}
+
+func loadCategories(db *gorm.DB, userID uint) (*categoryIndex, error) {
+	var categories []models.Category
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+
+	index := &categoryIndex{byID: map[uint]*models.Category{}, children: map[uint][]*models.Category{}}
+	for i := range categories {
+		c := &categories[i]
+		index.categories = append(index.categories, c)
+		index.byID[c.ID] = c
+	}
+	for _, c := range index.categories {
+		if c.ParentID != nil {
+			index.children[*c.ParentID] = append(index.children[*c.ParentID], c)
+		}
+	}
+	return index, nil
+}
+
+// find returns the category whose Thai or English name is label, ignoring case
+func (x *categoryIndex) find(label string) *models.Category {
+	for _, c := range x.categories {
+		if strings.EqualFold(c.Name, label) || (c.NameEN != "" && strings.EqualFold(c.NameEN, label)) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (x *categoryIndex) byKey(key string) *models.Category {
+	for _, c := range x.categories {
+		if c.Key == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// descendants returns the IDs of a category and everything below it
+func (x *categoryIndex) descendants(id uint) []uint {
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range x.children[ids[i]] {
+			ids = append(ids, child.ID)
+		}
+	}
+	return ids
+}
+
+// path returns a category and its ancestors, top-level category last
+func (x *categoryIndex) path(id uint) []*models.Category {
+	var path []*models.Category
+	for c := x.byID[id]; c != nil && len(path) <= len(x.categories); {
+		path = append(path, c)
+		if c.ParentID == nil {
+			break
+		}
+		c = x.byID[*c.ParentID]
+	}
+	return path
+}
+
+// resolve returns the category a name refers to: one of the user's categories
+// by name, the user's copy of a built-in category by either label, or else a
+// new top-level category of the given kind
+func (x *categoryIndex) resolve(db *gorm.DB, userID uint, name, kind string) (*models.Category, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, nil
+	}
+	if c := x.find(name); c != nil {
+		return c, nil
+	}
+
+	category := &models.Category{UserID: userID, Name: name, Kind: kind}
+	if category.Kind != "income" {
+		category.Kind = "expense"
+	}
+	if d := defaultCategoryByLabel(name); d != nil {
+		if c := x.byKey(d.Key); c != nil {
+			return c, nil
+		}
+		category = newDefaultCategory(userID, *d, x)
+	}
+
+	if err := db.Create(category).Error; err != nil {
+		return nil, fmt.Errorf("failed to create category %q: %w", name, err)
+	}
+	x.add(category)
+	return category, nil
+}
+
+func (x *categoryIndex) add(c *models.Category) {
+	x.categories = append(x.categories, c)
+	x.byID[c.ID] = c
+	if c.ParentID != nil {
+		x.children[*c.ParentID] = append(x.children[*c.ParentID], c)
+	}
+}
+
+// newDefaultCategory builds the user's copy of a built-in category, under the
+// user's copy of its parent if they have one
+func newDefaultCategory(userID uint, d defaultCategory, x *categoryIndex) *models.Category {
+	category := &models.Category{
+		UserID: userID,
+		Key:    d.Key,
+		Name:   d.Name,
+		NameEN: d.NameEN,
+		Kind:   d.Kind,
+		Icon:   d.Icon,
+		Color:  d.Color,
+	}
+	if parent := x.byKey(d.Parent); d.Parent != "" && parent != nil {
+		category.ParentID = &parent.ID
+	}
+	return category
+}
+
+// Resolve returns the category a name refers to, creating it when the user
+// has none by that name. An empty name resolves to nil.
+func (s *CategoryService) Resolve(userID uint, name, kind string) (*models.Category, error) {
+	return resolveCategory(config.DB, userID, name, kind)
+}
+
+// resolveCategory is Resolve through db
+func resolveCategory(db *gorm.DB, userID uint, name, kind string) (*models.Category, error) {
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	index, err := loadCategories(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return index.resolve(db, userID, name, kind)
+}
+
+// linkCategory points a row at the category its category string names and
+// replaces the string with the category's own name
+func linkCategory(db *gorm.DB, userID uint, kind string, name *string, categoryID **uint) error {
+	category, err := resolveCategory(db, userID, *name, kind)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		*name, *categoryID = "", nil
+		return nil
+	}
+	*name, *categoryID = category.Name, &category.ID
+	return nil
+}
+
+// linkCategoryUpdate does the same for a map of column updates
+func linkCategoryUpdate(userID uint, kind string, updates map[string]interface{}) error {
+	name, ok := updates["category"].(string)
+	if !ok {
+		return nil
+	}
+	var categoryID *uint
+	if err := linkCategory(config.DB, userID, kind, &name, &categoryID); err != nil {
+		return err
+	}
+	updates["category"] = name
+	if categoryID != nil {
+		updates["category_id"] = *categoryID
+	} else {
+		updates["category_id"] = nil
+	}
+	return nil
+}
+
+// canonicalCategory is the name of the category a name refers to, for rows
+// that store a category name only
+func canonicalCategory(userID uint, name, kind string) (string, error) {
+	var categoryID *uint
+	err := linkCategory(config.DB, userID, kind, &name, &categoryID)
+	return name, err
+}
+
+// SeedDefaults gives a user the built-in categories they do not have yet
+func (s *CategoryService) SeedDefaults(userID uint) error {
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		index, err := loadCategories(tx, userID)
+		if err != nil {
+			return err
+		}
+		for _, d := range defaultCategories {
+			if index.byKey(d.Key) != nil || index.find(d.Name) != nil {
+				continue
+			}
+			category := newDefaultCategory(userID, d, index)
+			if err := tx.Create(category).Error; err != nil {
+				return fmt.Errorf("failed to create category %q: %w", d.Name, err)
+			}
+			index.add(category)
+		}
+		return nil
+	})
+}
+
+// LinkCategories gives users who never had categories the built-in ones, and
+// turns the category strings of transactions, budgets and subscriptions
+// created before categories were rows into links, creating a category for
+// each name not seen before
+func LinkCategories() {
+	var userIDs []uint
+	if err := config.DB.Model(&models.User{}).
+		Where("id NOT IN (?)", config.DB.Unscoped().Model(&models.Category{}).Select("user_id")).
+		Pluck("id", &userIDs).Error; err != nil {
+		log.Printf("Warning: failed to find users without categories: %v", err)
+		return
+	}
+	service := NewCategoryService()
+	for _, userID := range userIDs {
+		if err := service.SeedDefaults(userID); err != nil {
+			log.Printf("Warning: failed to create default categories for user %d: %v", userID, err)
+			return
+		}
+	}
+
+	tables := []struct {
+		model interface{}
+		kind  string
+	}{
+		{&models.Transaction{}, "type"},
+		{&models.Budget{}, "'expense'"},
+		{&models.Subscription{}, "'expense'"},
+	}
+	linked := 0
+	for _, table := range tables {
+		var names []struct {
+			UserID   uint
+			Category string
+			Kind     string
+		}
+		// expense sorts first, so a name used for both is an expense category
+		if err := config.DB.Model(table.model).
+			Select("user_id, category, " + table.kind + " AS kind").
+			Where("category_id IS NULL AND category != ''").
+			Group("user_id, category, kind").Order("user_id, category, kind").
+			Scan(&names).Error; err != nil {
+			log.Printf("Warning: failed to find rows without category: %v", err)
+			return
+		}
+
+		for _, n := range names {
+			category, err := service.Resolve(n.UserID, n.Category, n.Kind)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				return
+			}
+			result := config.DB.Model(table.model).
+				Where("user_id = ? AND category = ? AND category_id IS NULL", n.UserID, n.Category).
+				UpdateColumns(map[string]interface{}{"category": category.Name, "category_id": category.ID})
+			if result.Error != nil {
+				log.Printf("Warning: failed to link rows to category %q: %v", category.Name, result.Error)
+				return
+			}
+			linked += int(result.RowsAffected)
+		}
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("Created default categories for %d users", len(userIDs))
+	}
+	if linked > 0 {
+		log.Printf("Linked %d rows to categories", linked)
+	}
+}
+
+// CategoryNode is a category with its subcategories
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// validate checks a new or edited category against the user's others
+func (s *CategoryService) validate(index *categoryIndex, category *models.Category) error {
+	category.Name = strings.Join(strings.Fields(category.Name), " ")
+	category.NameEN = strings.Join(strings.Fields(category.NameEN), " ")
+	if category.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if category.Kind == "" {
+		category.Kind = "expense"
+	}
+	if category.Kind != "income" && category.Kind != "expense" {
+		return fmt.Errorf("invalid kind %q. Must be income or expense", category.Kind)
+	}
+	if category.Color != "" && !categoryColor.MatchString(category.Color) {
+		return fmt.Errorf("invalid color %q. Must be #RRGGBB", category.Color)
+	}
+
+	for _, label := range []string{category.Name, category.NameEN} {
+		if label == "" {
+			continue
+		}
+		if other := index.find(label); other != nil && other.ID != category.ID {
+			return fmt.Errorf("%q already belongs to category #%d %q", label, other.ID, other.Name)
+		}
+	}
+
+	if category.ParentID == nil {
+		return nil
+	}
+	parent := index.byID[*category.ParentID]
+	if parent == nil {
+		return fmt.Errorf("parent category #%d not found", *category.ParentID)
+	}
+	if parent.Kind != category.Kind {
+		return fmt.Errorf("category kind must be %s like its parent %q", parent.Kind, parent.Name)
+	}
+	for _, ancestor := range index.path(parent.ID) {
+		if ancestor.ID == category.ID {
+			return fmt.Errorf("category cannot be moved under its own subcategory %q", parent.Name)
+		}
+	}
+	return nil
+}
+
+func (s *CategoryService) Create(category *models.Category) error {
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	index, err := loadCategories(config.DB, category.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.validate(index, category); err != nil {
+		return err
+	}
+	if err := config.DB.Create(category).Error; err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+func (s *CategoryService) GetAll(userID uint) ([]models.Category, error) {
+	var categories []models.Category
+	result := config.DB.Where("user_id = ?", userID).Order("kind DESC, name ASC").Find(&categories)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", result.Error)
+	}
+	return categories, nil
+}
+
+// GetTree returns the user's top-level categories with their subcategories
+func (s *CategoryService) GetTree(userID uint) ([]*CategoryNode, error) {
+	categories, err := s.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{Category: c, Children: []*CategoryNode{}}
+	}
+	roots := []*CategoryNode{}
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if parent := nodes[derefUint(c.ParentID)]; c.ParentID != nil && parent != nil {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+func derefUint(p *uint) uint {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func (s *CategoryService) GetByID(userID, id uint) (*models.Category, error) {
+	var category models.Category
+	result := config.DB.Where("user_id = ?", userID).First(&category, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("category not found: %w", result.Error)
+	}
+	return &category, nil
+}
+
+// Update replaces a category's labels, kind, look and parent. A new name is
+// carried over to everything filed under the category, and a new kind to its
+// subcategories.
+func (s *CategoryService) Update(userID, id uint, changes *models.Category) (*models.Category, error) {
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	index, err := loadCategories(config.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	category := index.byID[id]
+	if category == nil {
+		return nil, fmt.Errorf("category not found")
+	}
+	oldName := category.Name
+
+	updated := *category
+	updated.ParentID = changes.ParentID
+	updated.Name = changes.Name
+	updated.NameEN = changes.NameEN
+	updated.Kind = changes.Kind
+	updated.Icon = changes.Icon
+	updated.Color = changes.Color
+	if err := s.validate(index, &updated); err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&updated).Error; err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+		if updated.Kind != category.Kind {
+			if err := tx.Model(&models.Category{}).Where("id IN ?", index.descendants(id)).
+				Update("kind", updated.Kind).Error; err != nil {
+				return fmt.Errorf("failed to update subcategories: %w", err)
+			}
+		}
+		if updated.Name != oldName {
+			return renameCategory(tx, userID, id, oldName, updated.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated.Name != oldName {
+		forgetPayees(userID)
+	}
+	return &updated, nil
+}
+
+// renameCategory changes the category name stored alongside category links,
+// and in rules, payee defaults and drafts, which store the name only
+func renameCategory(tx *gorm.DB, userID, id uint, oldName, newName string) error {
+	for _, model := range []interface{}{&models.Transaction{}, &models.TransactionSplit{}, &models.Budget{}, &models.Subscription{}} {
+		if err := tx.Model(model).Where("user_id = ? AND category_id = ?", userID, id).
+			UpdateColumn("category", newName).Error; err != nil {
+			return fmt.Errorf("failed to rename category: %w", err)
+		}
+	}
+	named := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.CategoryRule{}, "category"},
+		{&models.Payee{}, "default_category"},
+		{&models.DraftTransaction{}, "category"},
+	}
+	for _, n := range named {
+		if err := tx.Model(n.model).Where("user_id = ? AND "+n.column+" = ?", userID, oldName).
+			UpdateColumn(n.column, newName).Error; err != nil {
+			return fmt.Errorf("failed to rename category: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a category. Its subcategories, transactions, subscriptions
+// and payee defaults move up to its parent, or become uncategorized under a
+// top-level category. Categories that budgets or rules use are kept.
+func (s *CategoryService) Delete(userID, id uint) error {
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	index, err := loadCategories(config.DB, userID)
+	if err != nil {
+		return err
+	}
+	category := index.byID[id]
+	if category == nil {
+		return fmt.Errorf("category not found")
+	}
+
+	var budgets, rules int64
+	config.DB.Model(&models.Budget{}).Where("user_id = ? AND category_id = ?", userID, id).Count(&budgets)
+	config.DB.Model(&models.CategoryRule{}).Where("user_id = ? AND category = ?", userID, category.Name).Count(&rules)
+	if budgets > 0 || rules > 0 {
+		return fmt.Errorf("category %q is used by %d budgets and %d rules", category.Name, budgets, rules)
+	}
+
+	parentName := ""
+	if category.ParentID != nil {
+		parentName = index.byID[*category.ParentID].Name
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(category).Error; err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+		if err := tx.Model(&models.Category{}).Where("user_id = ? AND parent_id = ?", userID, id).
+			Update("parent_id", category.ParentID).Error; err != nil {
+			return fmt.Errorf("failed to move subcategories: %w", err)
+		}
+		for _, model := range []interface{}{&models.Transaction{}, &models.TransactionSplit{}, &models.Subscription{}} {
+			if err := tx.Model(model).Where("user_id = ? AND category_id = ?", userID, id).
+				UpdateColumns(map[string]interface{}{"category": parentName, "category_id": category.ParentID}).Error; err != nil {
+				return fmt.Errorf("failed to move categorized rows: %w", err)
+			}
+		}
+		if err := tx.Model(&models.Payee{}).Where("user_id = ? AND default_category = ?", userID, category.Name).
+			Update("default_category", parentName).Error; err != nil {
+			return fmt.Errorf("failed to move payee defaults: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	forgetPayees(userID)
+	return nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/ocr"
+	"sort"
+	"strings"
+)
+
+type CorrectionService struct{}
+
+func NewCorrectionService() *CorrectionService {
+	return &CorrectionService{}
+}
+
+// correctableFields are the extracted fields whose corrections are tracked
+var correctableFields = []string{
+	ocr.FieldAmount, ocr.FieldDate, ocr.FieldTime, ocr.FieldReference,
+	ocr.FieldBank, ocr.FieldSender, ocr.FieldReceiver,
+}
+
+// aliasFields are the free-text fields that learned aliases are applied to
+var aliasFields = []string{ocr.FieldSender, ocr.FieldReceiver}
+
+// extractionSnapshot captures a transaction's extracted values in string form
+func extractionSnapshot(t *models.Transaction) map[string]string {
+	return map[string]string{
+		ocr.FieldAmount:    formatAmount(t.Amount),
+		ocr.FieldDate:      t.Date,
+		ocr.FieldTime:      t.Time,
+		ocr.FieldReference: t.Reference,
+		ocr.FieldBank:      t.Bank,
+		ocr.FieldSender:    t.Sender,
+		ocr.FieldReceiver:  t.Receiver,
+	}
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// RecordCorrections stores a correction for every extracted field whose
+// current value differs from what OCR originally produced.
+func (s *CorrectionService) RecordCorrections(t *models.Transaction) {
+	if t.OCRExtraction == nil {
+		return
+	}
+
+	current := extractionSnapshot(t)
+	for _, field := range correctableFields {
+		original := t.OCRExtraction[field]
+
+		// Only the latest correction per transaction and field is kept, and
+		// none at all once the value is back to what OCR extracted
+		config.DB.Where("transaction_id = ? AND field = ?", t.ID, field).
+			Delete(&models.ExtractionCorrection{})
+		if current[field] == original {
+			continue
+		}
+
+		correction := &models.ExtractionCorrection{
+			UserID:        t.UserID,
+			TransactionID: t.ID,
+			Bank:          t.OCRExtraction[ocr.FieldBank],
+			Field:         field,
+			WrongValue:    original,
+			RightValue:    current[field],
+			RawText:       t.RawOCRText,
+		}
+		if err := config.DB.Create(correction).Error; err != nil {
+			log.Printf("Failed to record correction for transaction #%d: %v", t.ID, err)
+		}
+	}
+}
+
+// ApplyLearnedAliases replaces sender/receiver values the user has previously
+// corrected for the same bank with the value they corrected them to.
+func (s *CorrectionService) ApplyLearnedAliases(t *models.Transaction) {
+	for _, field := range aliasFields {
+		value := t.Receiver
+		if field == ocr.FieldSender {
+			value = t.Sender
+		}
+		if value == "" {
+			continue
+		}
+
+		alias := s.lookupAlias(t.UserID, t.Bank, field, value)
+		if alias == "" {
+			continue
+		}
+
+		log.Printf("Learned alias for %s: %q -> %q", field, value, alias)
+		if field == ocr.FieldSender {
+			t.Sender = alias
+		} else {
+			t.Receiver = alias
+		}
+	}
+}
+
+// lookupAlias returns the most frequent correction for value, if any
+func (s *CorrectionService) lookupAlias(userID uint, bank, field, value string) string {
+	var corrections []models.ExtractionCorrection
+	config.DB.Where("user_id = ? AND bank = ? AND field = ? AND wrong_value != '' AND right_value != ''",
+		userID, bank, field).Find(&corrections)
+
+	key := normalizeAliasKey(value)
+	counts := make(map[string]int)
+	for _, c := range corrections {
+		if normalizeAliasKey(c.WrongValue) == key {
+			counts[c.RightValue]++
+		}
+	}
+
+	best, bestCount := "", 0
+	for right, count := range counts {
+		if count > bestCount || (count == bestCount && right < best) {
+			best, bestCount = right, count
+		}
+	}
+	return best
+}
+
+func normalizeAliasKey(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
+
+func (s *CorrectionService) GetAll(userID uint) ([]models.ExtractionCorrection, error) {
+	var corrections []models.ExtractionCorrection
+	result := config.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&corrections)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get corrections: %w", result.Error)
+	}
+	return corrections, nil
+}
+
+type FieldAccuracy struct {
+	Period    string  `json:"period"` // YYYY-MM of the transaction's upload
+	Bank      string  `json:"bank"`
+	Field     string  `json:"field"`
+	Total     int     `json:"total"`
+	Corrected int     `json:"corrected"`
+	Accuracy  float64 `json:"accuracy"`
+}
+
+// GetAccuracy reports, per month, bank and field, the share of OCR-created
+// transactions whose extracted value was not corrected.
+func (s *CorrectionService) GetAccuracy(userID uint) ([]FieldAccuracy, error) {
+	var transactions []models.Transaction
+	result := config.DB.Select("id, bank, ocr_extraction, created_at").
+		Where("user_id = ? AND ocr_extraction IS NOT NULL AND ocr_extraction != 'null'", userID).
+		Find(&transactions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", result.Error)
+	}
+
+	var corrections []models.ExtractionCorrection
+	result = config.DB.Select("transaction_id, field").Where("user_id = ?", userID).Find(&corrections)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get corrections: %w", result.Error)
+	}
+
+	corrected := make(map[string]bool)
+	for _, c := range corrections {
+		corrected[fmt.Sprintf("%d_%s", c.TransactionID, c.Field)] = true
+	}
+
+	stats := make(map[string]*FieldAccuracy)
+	for _, t := range transactions {
+		period := t.CreatedAt.Format("2006-01")
+		bank := t.OCRExtraction[ocr.FieldBank]
+		for _, field := range correctableFields {
+			key := period + "_" + bank + "_" + field
+			if _, exists := stats[key]; !exists {
+				stats[key] = &FieldAccuracy{Period: period, Bank: bank, Field: field}
+			}
+			stats[key].Total++
+			if corrected[fmt.Sprintf("%d_%s", t.ID, field)] {
+				stats[key].Corrected++
+			}
+		}
+	}
+
+	data := make([]FieldAccuracy, 0, len(stats))
+	for _, stat := range stats {
+		stat.Accuracy = 1 - float64(stat.Corrected)/float64(stat.Total)
+		data = append(data, *stat)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Period != data[j].Period {
+			return data[i].Period < data[j].Period
+		}
+		if data[i].Bank != data[j].Bank {
+			return data[i].Bank < data[j].Bank
+		}
+		return data[i].Field < data[j].Field
+	})
+
+	return data, nil
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"ocr-api/config"
 	"ocr-api/models"
+	"ocr-api/utils"
+	"sort"
+	"time"
 )
 
 type DashboardService struct{}
@@ -25,31 +29,28 @@ type YearlyData struct {
 }
 
 type CategoryData struct {
-	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
-	Count    int     `json:"count"`
+	Category   string         `json:"category"`
+	CategoryID uint           `json:"category_id,omitempty"`
+	NameEN     string         `json:"name_en,omitempty"`
+	Icon       string         `json:"icon,omitempty"`
+	Color      string         `json:"color,omitempty"`
+	Amount     float64        `json:"amount"`
+	Count      int            `json:"count"`
+	Children   []CategoryData `json:"children,omitempty"` // subcategories, with their own subcategories rolled in
+
+	children map[string]*CategoryData
 }
 
 // GetMonthlyTrend returns income/expense for 12 months
-func (s *DashboardService) GetMonthlyTrend(year int) ([]MonthlyData, error) {
+func (s *DashboardService) GetMonthlyTrend(userID uint, year int) ([]MonthlyData, error) {
 	var data []MonthlyData
 
 	for month := 1; month <= 12; month++ {
-		monthStr := fmt.Sprintf("%02d", month)
-		yearStr := fmt.Sprintf("%d", year)
-		pattern := fmt.Sprintf("%%/%s/%s", monthStr, yearStr)
-
-		var income, expense float64
-		config.DB.Model(&models.Transaction{}).
-			Where("type = ? AND date LIKE ?", "income", pattern).
-			Select("COALESCE(SUM(amount), 0)").Scan(&income)
-
-		config.DB.Model(&models.Transaction{}).
-			Where("type = ? AND date LIKE ?", "expense", pattern).
-			Select("COALESCE(SUM(amount), 0)").Scan(&expense)
+		start, end := utils.MonthRange(year, month)
+		income, expense := sumIncomeExpense(userID, start, end)
 
 		data = append(data, MonthlyData{
-			Month:   fmt.Sprintf("%s/%s", monthStr, yearStr),
+			Month:   fmt.Sprintf("%02d/%d", month, year),
 			Income:  income,
 			Expense: expense,
 		})
@@ -59,21 +60,12 @@ func (s *DashboardService) GetMonthlyTrend(year int) ([]MonthlyData, error) {
 }
 
 // GetYearlyComparison compares multiple years
-func (s *DashboardService) GetYearlyComparison(years []int) ([]YearlyData, error) {
+func (s *DashboardService) GetYearlyComparison(userID uint, years []int) ([]YearlyData, error) {
 	var data []YearlyData
 
 	for _, year := range years {
-		yearStr := fmt.Sprintf("%d", year)
-		pattern := fmt.Sprintf("%%/%s", yearStr)
-
-		var income, expense float64
-		config.DB.Model(&models.Transaction{}).
-			Where("type = ? AND date LIKE ?", "income", pattern).
-			Select("COALESCE(SUM(amount), 0)").Scan(&income)
-
-		config.DB.Model(&models.Transaction{}).
-			Where("type = ? AND date LIKE ?", "expense", pattern).
-			Select("COALESCE(SUM(amount), 0)").Scan(&expense)
+		start, end := utils.YearRange(year)
+		income, expense := sumIncomeExpense(userID, start, end)
 
 		data = append(data, YearlyData{
 			Year:    year,
@@ -85,30 +77,170 @@ func (s *DashboardService) GetYearlyComparison(years []int) ([]YearlyData, error
 	return data, nil
 }
 
-// GetCategoryBreakdown returns spending by category (for pie chart)
-func (s *DashboardService) GetCategoryBreakdown(year int, month int, transactionType string) ([]CategoryData, error) {
-	monthStr := fmt.Sprintf("%02d", month)
-	yearStr := fmt.Sprintf("%d", year)
-	pattern := fmt.Sprintf("%%/%s/%s", monthStr, yearStr)
+// GetCategoryBreakdown returns spending by top-level category (for pie chart),
+// with subcategories rolled up into their parents and split transactions
+// counted by line
+func (s *DashboardService) GetCategoryBreakdown(userID uint, year int, month int, transactionType string) ([]CategoryData, error) {
+	start, end := utils.MonthRange(year, month)
+
+	var rows []struct {
+		CategoryID *uint
+		Category   string
+		Amount     float64
+		Count      int
+	}
+	filter := TransactionFilter{From: start, To: end, Type: transactionType}
+	result := categoryLines(userID, filter).
+		Select("category_id, category, SUM(amount) as amount, COUNT(*) as count").
+		Group("category_id, category").
+		Scan(&rows)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get category breakdown: %w", result.Error)
+	}
+
+	index, err := loadCategories(config.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	breakdown := newRollUp(index)
+	for _, row := range rows {
+		breakdown.add(row.CategoryID, row.Category, row.Amount, row.Count)
+	}
+
+	return breakdown.result(), nil
+}
 
-	var data []CategoryData
-	result := config.DB.Model(&models.Transaction{}).
-		Select("category, SUM(amount) as amount, COUNT(*) as count").
-		Where("type = ? AND date LIKE ?", transactionType, pattern).
-		Group("category").
+type PayeeData struct {
+	PayeeID uint    `json:"payee_id"`
+	Name    string  `json:"name"`
+	Amount  float64 `json:"amount"`
+	Count   int     `json:"count"`
+}
+
+// GetTopPayees returns the payees with the largest totals in a month, or the
+// whole year when month is 0
+func (s *DashboardService) GetTopPayees(userID uint, year int, month int, transactionType string, limit int) ([]PayeeData, error) {
+	start, end := utils.YearRange(year)
+	if month != 0 {
+		start, end = utils.MonthRange(year, month)
+	}
+
+	data := []PayeeData{}
+	filter := TransactionFilter{From: start, To: end, Type: transactionType}
+	result := filter.Apply(userTransactions(userID)).
+		Where("payee_id IS NOT NULL").
+		Select("payee_id, SUM(amount) as amount, COUNT(*) as count").
+		Group("payee_id").
 		Order("amount DESC").
+		Limit(limit).
 		Scan(&data)
-
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to get category breakdown: %w", result.Error)
+		return nil, fmt.Errorf("failed to get top payees: %w", result.Error)
 	}
 
-	// Replace empty category
+	ids := make([]uint, len(data))
 	for i := range data {
-		if data[i].Category == "" {
-			data[i].Category = "ไม่มีหมวดหมู่"
-		}
+		ids[i] = data[i].PayeeID
+	}
+	var payees []models.Payee
+	if err := config.DB.Where("id IN ?", ids).Find(&payees).Error; err != nil {
+		return nil, fmt.Errorf("failed to get payees: %w", err)
+	}
+	names := make(map[uint]string, len(payees))
+	for _, payee := range payees {
+		names[payee.ID] = payee.Name
+	}
+	for i := range data {
+		data[i].Name = names[data[i].PayeeID]
 	}
 
 	return data, nil
 }
+
+// sumIncomeExpense totals income and expense for a user within [start, end)
+func sumIncomeExpense(userID uint, start, end time.Time) (float64, float64) {
+	totals, err := sumTransactions(TransactionFilter{From: start, To: end}.Apply(userTransactions(userID)))
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return 0, 0
+	}
+	return totals.TotalIncome, totals.TotalExpense
+}
+
+// rollUp groups amounts per category into their top-level categories, with
+// the direct subcategories each was spent under
+type rollUp struct {
+	index *categoryIndex
+	roots map[string]*CategoryData
+}
+
+func newRollUp(index *categoryIndex) *rollUp {
+	return &rollUp{index: index, roots: map[string]*CategoryData{}}
+}
+
+// add counts an amount under a category, or under the given name when the
+// category is unknown
+func (r *rollUp) add(categoryID *uint, name string, amount float64, count int) {
+	var path []*models.Category
+	if categoryID != nil {
+		path = r.index.path(*categoryID)
+	}
+	if len(path) == 0 {
+		if name == "" {
+			name = "ไม่มีหมวดหมู่"
+		}
+		entry(r.roots, name, nil).addAmount(amount, count)
+		return
+	}
+
+	root := path[len(path)-1]
+	top := entry(r.roots, root.Name, root)
+	top.addAmount(amount, count)
+	if len(path) > 1 {
+		child := path[len(path)-2]
+		if top.children == nil {
+			top.children = map[string]*CategoryData{}
+		}
+		entry(top.children, child.Name, child).addAmount(amount, count)
+	}
+}
+
+func entry(entries map[string]*CategoryData, name string, c *models.Category) *CategoryData {
+	if e, ok := entries[name]; ok {
+		return e
+	}
+	e := &CategoryData{Category: name}
+	if c != nil {
+		e.CategoryID, e.NameEN, e.Icon, e.Color = c.ID, c.NameEN, c.Icon, c.Color
+	}
+	entries[name] = e
+	return e
+}
+
+func (d *CategoryData) addAmount(amount float64, count int) {
+	d.Amount += amount
+	d.Count += count
+}
+
+// result lists the top-level categories and their subcategories, largest first
+func (r *rollUp) result() []CategoryData {
+	return sortedCategoryData(r.roots)
+}
+
+func sortedCategoryData(entries map[string]*CategoryData) []CategoryData {
+	data := make([]CategoryData, 0, len(entries))
+	for _, e := range entries {
+		if e.children != nil {
+			e.Children = sortedCategoryData(e.children)
+		}
+		data = append(data, *e)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Amount != data[j].Amount {
+			return data[i].Amount > data[j].Amount
+		}
+		return data[i].Category < data[j].Category
+	})
+	return data
+}
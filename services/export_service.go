@@ -0,0 +1,370 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats
+const (
+	ExportCSV   = "csv"
+	ExportXLSX  = "xlsx"
+	ExportOFX   = "ofx"
+	ExportJSONL = "jsonl"
+)
+
+type ExportService struct{}
+
+func NewExportService() *ExportService {
+	return &ExportService{}
+}
+
+// ContentType returns the MIME type and file extension of an export format
+func (s *ExportService) ContentType(format string) (string, string, error) {
+	switch format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8", "csv", nil
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", nil
+	case ExportOFX:
+		return "application/x-ofx", "ofx", nil
+	case ExportJSONL:
+		return "application/x-ndjson", "jsonl", nil
+	}
+	return "", "", fmt.Errorf("invalid format %q. Must be csv, xlsx, ofx or jsonl", format)
+}
+
+// Export writes the user's transactions matching filter to w, oldest first.
+// Rows are read from the database one at a time rather than loaded up front.
+func (s *ExportService) Export(w io.Writer, userID uint, format string, filter TransactionFilter) error {
+	switch format {
+	case ExportCSV:
+		return s.exportCSV(w, userID, filter)
+	case ExportXLSX:
+		return s.exportXLSX(w, userID, filter)
+	case ExportOFX:
+		return s.exportOFX(w, userID, filter)
+	case ExportJSONL:
+		return s.exportJSONL(w, userID, filter)
+	}
+	return fmt.Errorf("invalid format %q", format)
+}
+
+// exportPageSize is how many transactions are read before their splits are
+// loaded together
+const exportPageSize = 500
+
+// each calls fn for every matching transaction, scanning rows a page at a
+// time. Each page's splits are loaded in one query, so split transactions
+// export and summarize by line as they do in GetMonthlySummary.
+func (s *ExportService) each(userID uint, filter TransactionFilter, fn func(*models.Transaction) error) error {
+	q := filter.Apply(userTransactions(userID))
+	rows, err := q.Order("occurred_at ASC, id ASC").Rows()
+	if err != nil {
+		return fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	page := make([]models.Transaction, 0, exportPageSize)
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := config.DB.ScanRows(rows, &transaction); err != nil {
+			return fmt.Errorf("failed to read transaction: %w", err)
+		}
+		page = append(page, transaction)
+		if len(page) == exportPageSize {
+			if err := s.emitPage(page, fn); err != nil {
+				return err
+			}
+			page = page[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return s.emitPage(page, fn)
+}
+
+// emitPage loads the splits of a page of transactions and calls fn for each
+func (s *ExportService) emitPage(page []models.Transaction, fn func(*models.Transaction) error) error {
+	if len(page) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(page))
+	byID := make(map[uint]*models.Transaction, len(page))
+	for i := range page {
+		page[i].Splits = nil
+		ids[i] = page[i].ID
+		byID[page[i].ID] = &page[i]
+	}
+
+	var splits []models.TransactionSplit
+	if err := config.DB.Where("transaction_id IN ?", ids).Order("id ASC").Find(&splits).Error; err != nil {
+		return fmt.Errorf("failed to load splits: %w", err)
+	}
+	for _, split := range splits {
+		if t, ok := byID[split.TransactionID]; ok {
+			t.Splits = append(t.Splits, split)
+		}
+	}
+
+	for i := range page {
+		if err := fn(&page[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var exportColumns = []string{
+	"id", "date", "time", "type", "amount", "category", "bank", "channel", "source",
+	"reference", "sender", "receiver", "detail",
+}
+
+func exportRecord(t *models.Transaction) []string {
+	return []string{
+		strconv.FormatUint(uint64(t.ID), 10), t.Date, t.Time, t.Type,
+		strconv.FormatFloat(t.Amount, 'f', 2, 64), t.Category, t.Bank, t.Channel, t.Source,
+		t.Reference, t.Sender, t.Receiver, t.Detail,
+	}
+}
+
+func (s *ExportService) exportCSV(w io.Writer, userID uint, filter TransactionFilter) error {
+	// The byte order mark makes Excel read Thai text as UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	err := s.each(userID, filter, func(t *models.Transaction) error {
+		return writer.Write(exportRecord(t))
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *ExportService) exportJSONL(w io.Writer, userID uint, filter TransactionFilter) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return s.each(userID, filter, func(t *models.Transaction) error {
+		return encoder.Encode(t)
+	})
+}
+
+// exportXLSX writes a Transactions sheet and a Summary sheet with one
+// MonthlySummary per month and its category breakdown
+func (s *ExportService) exportXLSX(w io.Writer, userID uint, filter TransactionFilter) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	if err := file.SetSheetName("Sheet1", "Transactions"); err != nil {
+		return err
+	}
+	stream, err := file.NewStreamWriter("Transactions")
+	if err != nil {
+		return fmt.Errorf("failed to create sheet: %w", err)
+	}
+
+	if err := stream.SetRow("A1", stringCells(exportColumns)); err != nil {
+		return err
+	}
+
+	var months []string
+	builders := make(map[string]*summaryBuilder)
+	row := 2
+	err = s.each(userID, filter, func(t *models.Transaction) error {
+		record := exportRecord(t)
+		cells := stringCells(record)
+		cells[0] = t.ID
+		cells[4] = t.Amount
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		row++
+		if err := stream.SetRow(cell, cells); err != nil {
+			return err
+		}
+
+		month := t.OccurredAt.In(utils.BangkokLocation).Format("01/2006")
+		if _, exists := builders[month]; !exists {
+			builders[month] = newSummaryBuilder(month)
+			months = append(months, month)
+		}
+		builders[month].add(t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := file.NewSheet("Summary"); err != nil {
+		return err
+	}
+	summaryStream, err := file.NewStreamWriter("Summary")
+	if err != nil {
+		return fmt.Errorf("failed to create sheet: %w", err)
+	}
+
+	rows := [][]interface{}{
+		{"month", "total_income", "total_expense", "net_amount", "income_count", "expense_count", "transaction_count"},
+	}
+	var breakdown [][]interface{}
+	for _, month := range months {
+		summary, categories := builders[month].result()
+		rows = append(rows, []interface{}{
+			summary.Month, summary.TotalIncome, summary.TotalExpense, summary.NetAmount,
+			summary.IncomeCount, summary.ExpenseCount, summary.TransactionCount,
+		})
+		for _, c := range categories {
+			breakdown = append(breakdown, []interface{}{summary.Month, c.Category, c.Type, c.Total, c.Count})
+		}
+	}
+	rows = append(rows, nil, []interface{}{"month", "category", "type", "total", "count"})
+	rows = append(rows, breakdown...)
+
+	for i, values := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := summaryStream.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+	if err := summaryStream.Flush(); err != nil {
+		return err
+	}
+
+	return file.Write(w)
+}
+
+func stringCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// exportOFX writes an OFX 1.02 bank statement. It covers all of the user's
+// accounts, so it is labelled with the bank filtered on and user, and
+// transfers between those accounts are left out.
+func (s *ExportService) exportOFX(w io.Writer, userID uint, filter TransactionFilter) error {
+	// The header needs the date range and closing balance before any rows
+	var first, last string
+	var income, spent float64
+	row := filter.Apply(userTransactions(userID)).
+		Select("COALESCE(MIN(occurred_at), ''), COALESCE(MAX(occurred_at), ''), " +
+			"COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 0), " +
+			"COALESCE(SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END), 0)").Row()
+	if err := row.Scan(&first, &last, &income, &spent); err != nil {
+		return fmt.Errorf("failed to summarize transactions: %w", err)
+	}
+	start, end := filter.From, filter.To
+	if start.IsZero() {
+		start = parseStoredTime(first)
+	}
+	if end.IsZero() {
+		end = parseStoredTime(last)
+	}
+
+	bankID := "OCR-API"
+	if len(filter.Banks) == 1 {
+		bankID = filter.Banks[0]
+	}
+
+	var b strings.Builder
+	b.WriteString("OFXHEADER:100\r\nDATA:OFXSGML\r\nVERSION:102\r\nSECURITY:NONE\r\nENCODING:UTF-8\r\nCHARSET:NONE\r\nCOMPRESSION:NONE\r\nOLDFILEUID:NONE\r\nNEWFILEUID:NONE\r\n\r\n")
+	b.WriteString("<OFX>\r\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	fmt.Fprintf(&b, "<DTSERVER>%s<LANGUAGE>THA</SONRS></SIGNONMSGSRSV1>\r\n", ofxTime(time.Now()))
+	b.WriteString("<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STATUS><CODE>0<SEVERITY>INFO</STATUS><STMTRS><CURDEF>THB\r\n")
+	fmt.Fprintf(&b, "<BANKACCTFROM><BANKID>%s<ACCTID>%d<ACCTTYPE>CHECKING</BANKACCTFROM>\r\n", ofxEscaper.Replace(bankID), userID)
+	fmt.Fprintf(&b, "<BANKTRANLIST><DTSTART>%s<DTEND>%s\r\n", ofxTime(start), ofxTime(end))
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	err := s.each(userID, filter, func(t *models.Transaction) error {
+		if t.Type == "transfer" {
+			return nil
+		}
+		trnType, amount := "CREDIT", t.Amount
+		if t.Type == "expense" {
+			trnType, amount = "DEBIT", -t.Amount
+		}
+		fitID := t.Reference
+		if fitID == "" {
+			fitID = fmt.Sprintf("OCRAPI-%d", t.ID)
+		}
+		name := t.Receiver
+		if t.Type == "income" || name == "" {
+			name = t.Sender
+		}
+		if name == "" {
+			name = t.Category
+		}
+
+		var entry strings.Builder
+		fmt.Fprintf(&entry, "<STMTTRN><TRNTYPE>%s<DTPOSTED>%s<TRNAMT>%.2f<FITID>%s",
+			trnType, ofxTime(t.OccurredAt), amount, ofxEscaper.Replace(fitID))
+		if name != "" {
+			fmt.Fprintf(&entry, "<NAME>%s", ofxEscaper.Replace(truncateRunes(name, 32)))
+		}
+		if t.Detail != "" {
+			fmt.Fprintf(&entry, "<MEMO>%s", ofxEscaper.Replace(truncateRunes(strings.Join(strings.Fields(t.Detail), " "), 255)))
+		}
+		entry.WriteString("</STMTTRN>\r\n")
+		_, err := io.WriteString(w, entry.String())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "</BANKTRANLIST><LEDGERBAL><BALAMT>%.2f<DTASOF>%s</LEDGERBAL></STMTRS></STMTTRNRS></BANKMSGSRSV1>\r\n</OFX>\r\n",
+		income-spent, ofxTime(end))
+	return err
+}
+
+// ofxTime formats an instant in Thai time with its offset, e.g. 20251123143000[+7:ICT]
+func ofxTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.In(utils.BangkokLocation).Format("20060102150405") + "[+7:ICT]"
+}
+
+// parseStoredTime reads a timestamp returned as text by an aggregate query
+func parseStoredTime(value string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
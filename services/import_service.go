@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/importer"
+	"ocr-api/models"
+	"ocr-api/ocr"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ImportService struct{}
+
+func NewImportService() *ImportService {
+	return &ImportService{}
+}
+
+// ImportRow is one line of an imported file with its reconciliation outcome
+type ImportRow struct {
+	importer.Row
+	Status        string `json:"status"`                   // matched, new or conflicting
+	TransactionID uint   `json:"transaction_id,omitempty"` // matched or conflicting transaction, or the one created
+	Reason        string `json:"reason,omitempty"`
+}
+
+// ImportSummary counts rows by status
+type ImportSummary struct {
+	Rows        int `json:"rows"`
+	Matched     int `json:"matched"`
+	New         int `json:"new"`
+	Conflicting int `json:"conflicting"`
+}
+
+// ImportResult is the reconciliation report for one imported file
+type ImportResult struct {
+	Format  string        `json:"format"`
+	Bank    string        `json:"bank"`
+	DryRun  bool          `json:"dry_run"`
+	Rows    []ImportRow   `json:"rows"`
+	Summary ImportSummary `json:"summary"`
+
+	// Bank lines with no recorded transaction: slips that were never uploaded
+	NeverUploaded []ImportRow `json:"never_uploaded"`
+	// Slips within the file's dates that no bank line accounts for
+	UnmatchedUploads []models.Transaction `json:"unmatched_uploads"`
+}
+
+// Import parses a CSV, OFX or QIF export and reconciles each row against the
+// user's transactions. New rows are saved unless dryRun is set.
+func (s *ImportService) Import(userID uint, format string, content []byte, bank string, dryRun bool) (*ImportResult, error) {
+	statement, err := importer.Parse(format, content, bank)
+	if err != nil {
+		return nil, err
+	}
+	// OFX names the bank as free text; use the bank template name when it matches
+	if statement.Format == importer.FormatOFX {
+		if name, _ := ocr.DetectStatementBank(statement.Bank); name != "" {
+			statement.Bank = name
+		}
+	}
+
+	result := &ImportResult{
+		Format:           statement.Format,
+		Bank:             statement.Bank,
+		DryRun:           dryRun,
+		Rows:             []ImportRow{},
+		NeverUploaded:    []ImportRow{},
+		UnmatchedUploads: []models.Transaction{},
+	}
+
+	transactionService := NewTransactionService()
+	var seen []uint
+	var first, last time.Time
+	// Rows are saved together or not at all, so a file that fails halfway can
+	// be fixed and imported again without saving its first rows twice
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range statement.Rows {
+			transaction := &models.Transaction{
+				UserID:    userID,
+				Type:      row.Type,
+				Amount:    row.Amount,
+				Date:      row.Date,
+				Time:      row.Time,
+				Reference: row.Reference,
+				Bank:      statement.Bank,
+				Channel:   ocr.ChannelBank,
+				Source:    models.SourceImport,
+				Detail:    row.Description,
+			}
+			occurredAt, err := resolveOccurredAt(transaction.Date, transaction.Time)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			transaction.OccurredAt = occurredAt
+			if first.IsZero() || occurredAt.Before(first) {
+				first = occurredAt
+			}
+			if occurredAt.After(last) {
+				last = occurredAt
+			}
+
+			status, existing, reason, err := transactionService.MatchImported(transaction, config.AppConfig.ImportMatchWindow, seen)
+			if err != nil {
+				return err
+			}
+
+			imported := ImportRow{Row: row, Status: status, Reason: reason}
+			switch status {
+			case MatchMatched:
+				result.Summary.Matched++
+			case MatchConflicting:
+				result.Summary.Conflicting++
+			case MatchNew:
+				result.Summary.New++
+				if !dryRun {
+					NewRuleService().Categorize(transaction)
+					if err := createTransaction(tx, transaction); err != nil {
+						return fmt.Errorf("line %d: %w", row.Line, err)
+					}
+					existing = transaction
+				}
+			}
+			// Each recorded transaction accounts for at most one row
+			if existing != nil {
+				imported.TransactionID = existing.ID
+				seen = append(seen, existing.ID)
+			}
+
+			result.Rows = append(result.Rows, imported)
+			if status == MatchNew {
+				result.NeverUploaded = append(result.NeverUploaded, imported)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Summary.Rows = len(result.Rows)
+
+	unmatched, err := s.unmatchedUploads(userID, statement.Bank, first, last.Add(24*time.Hour), seen)
+	if err != nil {
+		return nil, err
+	}
+	result.UnmatchedUploads = unmatched
+
+	log.Printf("Import (%s, %q): %d rows, %d matched, %d new, %d conflicting, %d unmatched uploads, dry run %v",
+		result.Format, result.Bank, result.Summary.Rows, result.Summary.Matched, result.Summary.New,
+		result.Summary.Conflicting, len(result.UnmatchedUploads), dryRun)
+	return result, nil
+}
+
+// unmatchedUploads lists bank slip transactions between from and to that no
+// row accounted for. When the file's bank is known, other banks' slips are
+// left out since they belong on another statement.
+func (s *ImportService) unmatchedUploads(userID uint, bank string, from, to time.Time, matchedIDs []uint) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+
+	// Wallet and counter slips never appear on a bank statement
+	q := config.DB.Where("user_id = ? AND source = ? AND channel IN ? AND occurred_at >= ? AND occurred_at < ?",
+		userID, models.SourceSlip, []string{ocr.ChannelBank, ""}, from, to)
+	if bank != "" {
+		q = q.Where("bank = ?", bank)
+	}
+	if len(matchedIDs) > 0 {
+		q = q.Where("id NOT IN ?", matchedIDs)
+	}
+	if result := q.Order("occurred_at ASC").Find(&transactions); result.Error != nil {
+		return nil, fmt.Errorf("failed to find unmatched uploads: %w", result.Error)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// jobPollInterval is how often idle workers look for queued files they were not woken for
+const jobPollInterval = 5 * time.Second
+
+// jobWake wakes idle workers when new files are queued
+var jobWake chan struct{}
+
+type JobService struct {
+	ocrService *OCRService
+}
+
+func NewJobService() *JobService {
+	return &JobService{ocrService: NewOCRService()}
+}
+
+// JobFileInput describes one file of an async upload. Files that failed
+// validation carry an Error and are recorded as failed straight away.
+type JobFileInput struct {
+	Filename   string
+	StoredPath string
+	Error      string
+}
+
+type JobStatus struct {
+	*models.UploadJob
+	Status         string `json:"status"` // queued, processing, completed
+	TotalCount     int    `json:"total_count"`
+	DoneCount      int    `json:"done_count"`
+	FailedCount    int    `json:"failed_count"`
+	TransactionIDs []uint `json:"transaction_ids"`
+	DraftIDs       []uint `json:"draft_ids"` // slips sent to the review queue
+}
+
+// StartJobWorkers requeues files interrupted by a restart and starts the worker pool
+func StartJobWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobWake = make(chan struct{}, workers)
+
+	result := config.DB.Model(&models.UploadJobFile{}).
+		Where("status = ?", models.JobFileProcessing).
+		Update("status", models.JobFileQueued)
+	if result.Error != nil {
+		log.Printf("Warning: failed to requeue interrupted job files: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Requeued %d job files interrupted by restart", result.RowsAffected)
+	}
+
+	service := NewJobService()
+	for i := 0; i < workers; i++ {
+		go service.runWorker(i)
+	}
+	notifyWorkers(workers)
+
+	log.Printf("Started %d upload job workers", workers)
+}
+
+func notifyWorkers(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case jobWake <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// Create persists a job for files already saved to disk and wakes the workers
+func (s *JobService) Create(userID uint, transactionType string, files []JobFileInput) (*models.UploadJob, error) {
+	job := &models.UploadJob{
+		UserID: userID,
+		Type:   transactionType,
+	}
+
+	queued := 0
+	for _, f := range files {
+		file := models.UploadJobFile{
+			Filename:   f.Filename,
+			StoredPath: f.StoredPath,
+			Status:     models.JobFileQueued,
+		}
+		if f.Error != "" {
+			file.Status = models.JobFileFailed
+			file.Error = f.Error
+		} else {
+			queued++
+		}
+		job.Files = append(job.Files, file)
+	}
+
+	if queued == 0 {
+		now := time.Now()
+		job.CompletedAt = &now
+	}
+
+	if err := config.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	notifyWorkers(queued)
+	return job, nil
+}
+
+func (s *JobService) GetByID(userID, id uint) (*JobStatus, error) {
+	var job models.UploadJob
+	result := config.DB.Where("user_id = ?", userID).
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		First(&job, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("job not found: %w", result.Error)
+	}
+
+	status := &JobStatus{
+		UploadJob:      &job,
+		TotalCount:     len(job.Files),
+		TransactionIDs: []uint{},
+		DraftIDs:       []uint{},
+	}
+
+	pending := 0
+	for _, f := range job.Files {
+		switch f.Status {
+		case models.JobFileDone:
+			status.DoneCount++
+			if f.TransactionID != nil {
+				status.TransactionIDs = append(status.TransactionIDs, *f.TransactionID)
+			}
+			if f.DraftID != nil {
+				status.DraftIDs = append(status.DraftIDs, *f.DraftID)
+			}
+		case models.JobFileFailed:
+			status.FailedCount++
+		default:
+			pending++
+		}
+	}
+
+	switch {
+	case pending == 0:
+		status.Status = "completed"
+	case pending == status.TotalCount:
+		status.Status = "queued"
+	default:
+		status.Status = "processing"
+	}
+
+	return status, nil
+}
+
+func (s *JobService) runWorker(worker int) {
+	for {
+		file, job, err := s.claimNextFile()
+		if err != nil {
+			log.Printf("Job worker %d: failed to claim file: %v", worker, err)
+		}
+		if file == nil {
+			select {
+			case <-jobWake:
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+
+		s.processFile(worker, file, job)
+	}
+}
+
+// claimNextFile atomically moves the oldest queued file to processing
+func (s *JobService) claimNextFile() (*models.UploadJobFile, *models.UploadJob, error) {
+	for {
+		var file models.UploadJobFile
+		result := config.DB.Where("status = ?", models.JobFileQueued).Order("id ASC").First(&file)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+
+		// Another worker may have claimed it between the read and the update
+		result = config.DB.Model(&models.UploadJobFile{}).
+			Where("id = ? AND status = ?", file.ID, models.JobFileQueued).
+			Update("status", models.JobFileProcessing)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		var job models.UploadJob
+		if err := config.DB.First(&job, file.JobID).Error; err != nil {
+			s.finishFile(&file, nil, nil, fmt.Errorf("job #%d not found", file.JobID))
+			continue
+		}
+
+		return &file, &job, nil
+	}
+}
+
+func (s *JobService) processFile(worker int, file *models.UploadJobFile, job *models.UploadJob) {
+	log.Printf("Job worker %d: processing '%s' (job #%d)", worker, file.Filename, job.ID)
+
+	// A panic in the OCR pipeline fails this file instead of the server; left
+	// in processing, the file would be requeued and crash it again on restart
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job worker %d: panic processing '%s': %v\n%s", worker, file.Filename, r, debug.Stack())
+			if err := s.ocrService.CleanupUploadedFile(file.StoredPath); err != nil {
+				log.Printf("Warning: failed to cleanup uploaded file: %v", err)
+			}
+			s.finishFile(file, nil, nil, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	// Async uploads wait on disk for their job, which survives a restart
+	var transaction *models.Transaction
+	var draft *models.DraftTransaction
+	imageData, err := os.ReadFile(file.StoredPath)
+	if err == nil {
+		// The upload request has returned by now, so nothing cancels a job's OCR
+		transaction, draft, err = s.ocrService.ImportSlip(context.Background(), job.UserID, imageData, file.Filename, job.Type)
+	}
+	if err != nil {
+		log.Printf("Job worker %d: failed to process '%s': %v", worker, file.Filename, err)
+	}
+
+	if err := s.ocrService.CleanupUploadedFile(file.StoredPath); err != nil {
+		log.Printf("Warning: failed to cleanup uploaded file: %v", err)
+	}
+
+	s.finishFile(file, transaction, draft, err)
+}
+
+// finishFile records the outcome of a file and completes the job once no files are pending
+func (s *JobService) finishFile(file *models.UploadJobFile, transaction *models.Transaction, draft *models.DraftTransaction, processErr error) {
+	updates := map[string]interface{}{"status": models.JobFileDone}
+	if processErr != nil {
+		updates["status"] = models.JobFileFailed
+		updates["error"] = processErr.Error()
+	} else if transaction != nil {
+		updates["transaction_id"] = transaction.ID
+	} else if draft != nil {
+		updates["draft_id"] = draft.ID
+	}
+
+	if err := config.DB.Model(file).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update job file #%d: %v", file.ID, err)
+		return
+	}
+
+	var pending int64
+	config.DB.Model(&models.UploadJobFile{}).
+		Where("job_id = ? AND status IN ?", file.JobID, []string{models.JobFileQueued, models.JobFileProcessing}).
+		Count(&pending)
+	if pending == 0 {
+		config.DB.Model(&models.UploadJob{}).Where("id = ?", file.JobID).Update("completed_at", time.Now())
+	}
+}
@@ -1,7 +1,11 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"log"
 	"ocr-api/config"
 	"ocr-api/models"
@@ -9,6 +13,7 @@ import (
 	"ocr-api/utils"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type OCRService struct{}
@@ -17,37 +22,107 @@ func NewOCRService() *OCRService {
 	return &OCRService{}
 }
 
-func (s *OCRService) ProcessSlip(imagePath string, transactionType string) (*models.Transaction, *models.Subscription, error) {
-	log.Printf("Processing slip: %s", imagePath)
+// SlipResult is the outcome of running the OCR pipeline on one slip
+type SlipResult struct {
+	Transaction  *models.Transaction
+	Subscription *models.Subscription
+	Missing      []string // required fields that could not be extracted
+	ImagePath    string   // preprocessed image, saved for review when Missing is non-empty
 
-	jpegPath, err := ocr.ConvertToJPEG(imagePath)
+	Preprocessing *ocr.PreprocessReport // steps run on the image before OCR
+}
+
+// ProcessSlip reads a slip image in memory. Nothing is written to disk except
+// the preprocessed image of a slip that needs review.
+func (s *OCRService) ProcessSlip(ctx context.Context, imageData []byte, transactionType string) (*SlipResult, error) {
+	log.Printf("Processing slip (%.2f KB)", float64(len(imageData))/1024)
+	started := time.Now()
+
+	img, err := ocr.DecodeImage(imageData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to convert image: %w", err)
+		return nil, err
 	}
-	defer s.cleanupFile(jpegPath)
 
-	processedPath, err := ocr.PreprocessImage(jpegPath)
+	// The slip QR is read from the unprocessed image; it is optional
+	slipQR, err := ocr.DecodeSlipQR(img)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to preprocess image: %w", err)
+		log.Printf("Slip QR not decoded: %v", err)
+		slipQR = nil
 	}
-	defer s.cleanupFile(processedPath)
 
-	ocrText, err := ocr.PerformOCR(processedPath, config.AppConfig.TesseractLang)
+	// Slips are preprocessed the way their bank's template says. The QR names
+	// the bank up front; otherwise the fallback template's steps are used, and
+	// the slip is read again if the text shows a bank that preprocesses
+	// differently.
+	qrBank := ""
+	if slipQR != nil {
+		qrBank = slipQR.BankName()
+	}
+	profile, steps := ocr.PreprocessingFor(qrBank)
+	processed, ocrResult, preprocessing, err := s.recognize(ctx, img, profile, steps)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to perform OCR: %w", err)
+		return nil, err
+	}
+	if qrBank == "" {
+		if detected, detectedSteps := ocr.PreprocessingFor(ocr.DetectBank(ocrResult.Text)); detected != profile {
+			log.Printf("Slip looks like %s, preprocessing again", detected)
+			processed, ocrResult, preprocessing, err = s.recognize(ctx, img, detected, detectedSteps)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	diagnostics := &ocr.Diagnostics{Preprocessing: preprocessing}
+
+	// Banks whose templates map their slip layout have those fields read
+	// region by region
+	regionBank := qrBank
+	if regionBank == "" {
+		regionBank = ocr.DetectBank(ocrResult.Text)
 	}
+	regions := ocr.ReadRegions(ctx, processed, regionBank, config.AppConfig.TesseractLang)
+	diagnostics.Regions = regions
+
+	var extractedData *ocr.ExtractedData
+	if config.AppConfig.OCRMultiPass {
+		reader := &ocr.SlipReader{
+			Raw:          img,
+			Preprocessed: processed,
+			Lang:         config.AppConfig.TesseractLang,
+			QR:           slipQR,
+			Regions:      regions,
+			Budget:       time.Duration(config.AppConfig.OCRTimeBudget) * time.Millisecond,
+			Started:      started,
+		}
+		winner, attempts := reader.Read(ctx, ocrResult, ocrResult.Duration)
+		diagnostics.Attempts = attempts
+		if winner == nil {
+			return nil, fmt.Errorf("failed to extract data: %s", attempts[0].Error)
+		}
+		ocrResult, extractedData = winner.Result, winner.Data
+	} else {
+		extractedData, err = ocr.ExtractDataWithRegions(ocrResult.Text, slipQR, regions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract data: %w", err)
+		}
+		ocr.ScoreConfidence(extractedData, ocrResult)
+	}
+	// Reads skipped because the request was canceled leave a poorer reading,
+	// which is not worth saving for a client that has gone
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("slip processing canceled: %w", err)
+	}
+	ocrText := ocrResult.Text
 
 	log.Printf("OCR Text:\n%s\n", ocrText)
 
 	// Clean OCR text for better readability
 	cleanedOCRText := utils.CleanOCRText(ocrText)
 
-	extractedData, err := ocr.ExtractData(ocrText)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to extract data: %w", err)
-	}
+	overallConfidence := ocr.OverallConfidence(extractedData.Confidence)
 
-	normalizedDate := ocr.NormalizeDate(extractedData.Date)
+	normalizedDate := utils.NormalizeDateLocale(extractedData.Date, extractedData.DateLocale)
 
 	transaction := &models.Transaction{
 		Type:       transactionType,
@@ -56,36 +131,191 @@ func (s *OCRService) ProcessSlip(imagePath string, transactionType string) (*mod
 		Time:       extractedData.Time,
 		Reference:  extractedData.Reference,
 		Bank:       extractedData.Bank,
+		Channel:    extractedData.Channel,
+		Source:     models.SourceSlip,
 		Sender:     extractedData.Sender,
 		Receiver:   extractedData.Receiver,
 		RawOCRText: cleanedOCRText,
+
+		SenderAccount:   extractedData.SenderAccount,
+		ReceiverAccount: extractedData.ReceiverAccount,
+
+		Confidence:        extractedData.Confidence,
+		OverallConfidence: overallConfidence,
+		NeedsReview:       overallConfidence < config.AppConfig.ReviewThreshold,
+	}
+
+	diagnostics.DurationMS = float64(time.Since(started).Milliseconds())
+	if encoded, err := json.Marshal(diagnostics); err != nil {
+		log.Printf("Warning: failed to record OCR diagnostics: %v", err)
+	} else {
+		transaction.OCRDiagnostics = encoded
+	}
+
+	result := &SlipResult{
+		Transaction:   transaction,
+		Missing:       extractedData.MissingFields(),
+		Preprocessing: preprocessing,
+	}
+	// A date that was read but cannot be parsed needs a person to fix it
+	if normalizedDate != "" {
+		if _, err := utils.ParseTransactionTime(normalizedDate, transaction.Time); err != nil {
+			log.Printf("Warning: could not parse slip date %q %q: %v", normalizedDate, transaction.Time, err)
+			result.Missing = append(result.Missing, ocr.FieldDate)
+		}
+	}
+
+	// Incomplete slips go to the review queue, which shows the image unless
+	// slip images are not kept
+	if len(result.Missing) > 0 {
+		log.Printf("Slip is missing %v, sending it for review", result.Missing)
+		if config.AppConfig.KeepSlipImages {
+			keptPath, err := keepForReview(processed)
+			if err != nil {
+				log.Printf("Warning: failed to keep slip image for review: %v", err)
+			} else {
+				result.ImagePath = keptPath
+			}
+		}
+		return result, nil
 	}
 
 	// Auto-detect subscription
 	subscriptionService := NewSubscriptionService()
-	detectedSub := subscriptionService.DetectSubscription(ocrText, extractedData.Amount)
+	result.Subscription = subscriptionService.DetectSubscription(ocrText, extractedData.Amount)
 
 	log.Printf("Transaction created: %+v", transaction)
 
-	return transaction, detectedSub, nil
+	return result, nil
 }
 
-func (s *OCRService) cleanupFile(filePath string) {
-	if filePath == "" {
-		return
+// recognize preprocesses a slip image with the given steps and runs OCR on
+// the result
+func (s *OCRService) recognize(ctx context.Context, img image.Image, profile string, steps []ocr.PreprocessStep) (image.Image, *ocr.OCRResult, *ocr.PreprocessReport, error) {
+	processed, report := ocr.Preprocess(img, profile, steps)
+	log.Printf("Preprocessed image (%s)", report)
+
+	ocrResult, err := ocr.PerformOCR(ctx, processed, config.AppConfig.TesseractLang)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to perform OCR: %w", err)
 	}
+	return processed, ocrResult, report, nil
+}
 
-	if err := os.Remove(filePath); err != nil {
-		log.Printf("Warning: failed to cleanup file %s: %v", filePath, err)
-	} else {
-		log.Printf("Cleaned up file: %s", filePath)
+// PoolStats reports how busy the OCR client pool is and how long reads wait
+// for a client
+func (s *OCRService) PoolStats() ocr.PoolStats {
+	return ocr.ClientPoolStats()
+}
+
+// keepForReview saves a preprocessed slip image in the review directory under
+// a name no other upload can take
+func keepForReview(img image.Image) (string, error) {
+	reviewDir := filepath.Join(config.AppConfig.UploadDir, "review")
+	if err := os.MkdirAll(reviewDir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(reviewDir, "slip-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	err = jpeg.Encode(file, img, &jpeg.Options{Quality: 95})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// DuplicateSlipError is returned by ImportSlip when the slip matches an existing transaction
+type DuplicateSlipError struct {
+	ExistingID uint
+}
+
+func (e *DuplicateSlipError) Error() string {
+	return fmt.Sprintf("duplicate slip (already exists as transaction #%d)", e.ExistingID)
+}
+
+// ImportSlip runs the OCR pipeline on an uploaded slip and saves the resulting
+// transaction for the user, along with any auto-detected subscription.
+// Slips with missing required fields are saved as a draft for review instead.
+// The slip image is kept as an attachment named filename when KEEP_SLIP_IMAGES
+// is on.
+func (s *OCRService) ImportSlip(ctx context.Context, userID uint, imageData []byte, filename, transactionType string) (transaction *models.Transaction, draft *models.DraftTransaction, err error) {
+	defer func() { attachSlip(userID, imageData, filename, transaction, draft) }()
+
+	result, err := s.ProcessSlip(ctx, imageData, transactionType)
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Transaction.UserID = userID
+
+	// Remember what OCR extracted so later edits can be recorded as
+	// corrections, then apply what the user taught us. Taking the snapshot
+	// first keeps accuracy stats about OCR rather than about learned aliases.
+	result.Transaction.OCRExtraction = extractionSnapshot(result.Transaction)
+	NewCorrectionService().ApplyLearnedAliases(result.Transaction)
+
+	// Drafts get their payee when approved, once the receiver has been checked
+	if len(result.Missing) > 0 {
+		NewRuleService().Categorize(result.Transaction)
+		created, err := NewReviewService().CreateDraft(result)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, created, nil
+	}
+
+	payee := NewPayeeService().Resolve(result.Transaction)
+	NewRuleService().Categorize(result.Transaction)
+
+	// Slips that only name the service through the payee, e.g. a corrected alias
+	if result.Subscription == nil && payee != nil {
+		result.Subscription = NewSubscriptionService().DetectSubscription(payee.Name, result.Transaction.Amount)
 	}
+
+	if err := saveSlipTransaction(result.Transaction, result.Subscription); err != nil {
+		return nil, nil, err
+	}
+
+	return result.Transaction, nil, nil
 }
 
-func (s *OCRService) SaveUploadedFile(fileHeader interface{}, filename string) (string, error) {
-	uploadPath := filepath.Join(config.AppConfig.UploadDir, filename)
+// saveSlipTransaction stores a slip transaction unless it duplicates an
+// existing one, then saves its auto-detected subscription if any.
+func saveSlipTransaction(transaction *models.Transaction, detectedSub *models.Subscription) error {
+	transactionService := NewTransactionService()
+
+	// Check for duplicates
+	duplicate, err := transactionService.CheckDuplicate(transaction)
+	if err != nil {
+		return err
+	}
+	if duplicate != nil {
+		return &DuplicateSlipError{ExistingID: duplicate.ID}
+	}
+
+	if err := transactionService.Create(transaction); err != nil {
+		return err
+	}
+
+	// Auto-save detected subscription
+	if detectedSub != nil {
+		detectedSub.UserID = transaction.UserID
+		detectedSub.NextBillingDate = transaction.Date
+		subscriptionService := NewSubscriptionService()
+		if err := subscriptionService.Create(detectedSub); err != nil {
+			log.Printf("Failed to save auto-detected subscription: %v", err)
+		} else {
+			log.Printf("Auto-detected subscription: %s", detectedSub.Name)
+		}
+	}
 
-	return uploadPath, nil
+	return nil
 }
 
 func (s *OCRService) CleanupUploadedFile(filePath string) error {
@@ -106,7 +336,7 @@ func (s *OCRService) ValidateImage(filename string) error {
 	validExts := []string{".jpg", ".jpeg", ".png"}
 
 	if !utils.Contains(validExts, ext) {
-		return fmt.Errorf("invalid file type: %s. Allowed types: jpg, jpeg, png", ext)
+		return fmt.Errorf("invalid file type: %s. Allowed types: jpg, jpeg, png (PDF statements go to /api/v1/statements)", ext)
 	}
 
 	return nil
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+type PayeeService struct{}
+
+func NewPayeeService() *PayeeService {
+	return &PayeeService{}
+}
+
+// payeeUsers holds a *payeeState per user
+var payeeUsers sync.Map
+
+// payeeState serializes changes to one user's payees, so concurrent uploads
+// do not create the same payee twice, and keeps their directory between
+// requests. The directory is dropped whenever the payees change.
+type payeeState struct {
+	mu        sync.Mutex
+	directory *payeeDirectory
+}
+
+// lockPayees locks the user's payees and returns their state; unlock it with
+// state.mu.Unlock
+func lockPayees(userID uint) *payeeState {
+	v, _ := payeeUsers.LoadOrStore(userID, &payeeState{})
+	state := v.(*payeeState)
+	state.mu.Lock()
+	return state
+}
+
+// forgetPayees drops the user's kept payee directory after payees were
+// changed outside this service
+func forgetPayees(userID uint) {
+	state := lockPayees(userID)
+	state.directory = nil
+	state.mu.Unlock()
+}
+
+// load returns the user's payee directory, reading it only when it is not
+// kept already. The lock must be held.
+func (st *payeeState) load(userID uint) (*payeeDirectory, error) {
+	if st.directory == nil {
+		directory, err := loadPayeeDirectory(userID)
+		if err != nil {
+			return nil, err
+		}
+		st.directory = directory
+	}
+	return st.directory, nil
+}
+
+// Titles and shop/company designators written before a name, longest first
+var payeePrefixes = []string{"นางสาว", "น.ส.", "นาง", "นาย", "คุณ", "ร้าน", "บริษัท", "บจก.", "บจก", "หจก.", "หจก"}
+
+// Words that do not tell payees apart
+var payeeStopWords = map[string]bool{
+	"จำกัด": true, "มหาชน": true, "co": true, "ltd": true, "company": true, "limited": true,
+	"plc": true, "inc": true, "mr": true, "mrs": true, "ms": true, "miss": true, "shop": true, "store": true,
+}
+
+// Branch names and numbers after the payee name
+var payeeBranch = regexp.MustCompile(`(?i)(สาขา|\bbranch\b|\bbr\.).*$`)
+
+// minFuzzyRunes is the shortest normalized name matched approximately;
+// shorter names must match exactly
+const minFuzzyRunes = 4
+
+// displayPayeeName is the raw name without its branch, as shown for new payees
+func displayPayeeName(raw string) string {
+	name := strings.Join(strings.Fields(payeeBranch.ReplaceAllString(raw, "")), " ")
+	if name == "" {
+		return strings.Join(strings.Fields(raw), " ")
+	}
+	return name
+}
+
+// normalizePayee reduces a receiver or sender to a comparison key: lower case,
+// Thai digits as ASCII, without branch, titles, company designators, spaces
+// and punctuation. "ร้าน 7-Eleven สาขา ๑๒๓" and "7-ELEVEN" both become "7eleven".
+func normalizePayee(raw string) string {
+	s := strings.ToLower(norm.NFKC.String(displayPayeeName(raw)))
+	// NFKC splits sara am into nikhahit + sara aa, as some OCR output has it
+	s = strings.ReplaceAll(s, "\u0e4d\u0e32", "\u0e33")
+	s = strings.Map(func(r rune) rune {
+		if r >= '๐' && r <= '๙' {
+			return '0' + r - '๐'
+		}
+		return r
+	}, s)
+
+	for stripped := true; stripped; {
+		stripped = false
+		s = strings.TrimSpace(s)
+		for _, prefix := range payeePrefixes {
+			if strings.HasPrefix(s, prefix) && len(s) > len(prefix) {
+				s = s[len(prefix):]
+				stripped = true
+				break
+			}
+		}
+	}
+
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.In(r, unicode.Mn, unicode.Mc)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if payeeStopWords[word] {
+			continue
+		}
+		b.WriteString(strings.TrimSuffix(word, "จำกัด"))
+	}
+	if b.Len() == 0 {
+		return strings.Join(words, "")
+	}
+	return b.String()
+}
+
+// payeeSimilarity is 1 minus the edit distance between two keys relative to
+// the longer one
+func payeeSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// counterparty is the raw name a transaction's payee is resolved from: who
+// was paid for an expense, who paid for income
+func counterparty(t *models.Transaction) string {
+	if t.Type == "income" {
+		return t.Sender
+	}
+	return t.Receiver
+}
+
+// payeeDirectory is one user's payees indexed by the keys of their names and
+// aliases
+type payeeDirectory struct {
+	userID uint
+	keys   []payeeKey
+}
+
+type payeeKey struct {
+	key   string
+	payee *models.Payee
+}
+
+func loadPayeeDirectory(userID uint) (*payeeDirectory, error) {
+	var payees []models.Payee
+	if err := config.DB.Where("user_id = ?", userID).Find(&payees).Error; err != nil {
+		return nil, fmt.Errorf("failed to get payees: %w", err)
+	}
+
+	d := &payeeDirectory{userID: userID}
+	for i := range payees {
+		d.index(&payees[i])
+	}
+	return d, nil
+}
+
+func (d *payeeDirectory) index(p *models.Payee) {
+	for _, name := range append([]string{p.Name}, p.Aliases...) {
+		if key := normalizePayee(name); key != "" {
+			d.keys = append(d.keys, payeeKey{key: key, payee: p})
+		}
+	}
+}
+
+// find returns the payee whose name or alias matches key exactly, or else
+// the most similar one above the configured threshold
+func (d *payeeDirectory) find(key string) (payee *models.Payee, exact bool) {
+	best := 0.0
+	for _, k := range d.keys {
+		if k.key == key {
+			return k.payee, true
+		}
+		if utf8.RuneCountInString(key) < minFuzzyRunes || utf8.RuneCountInString(k.key) < minFuzzyRunes {
+			continue
+		}
+		if similarity := payeeSimilarity(key, k.key); similarity >= config.AppConfig.PayeeMatchThreshold && similarity > best {
+			payee, best = k.payee, similarity
+		}
+	}
+	return payee, false
+}
+
+// resolve returns the payee for a raw name, creating one when nothing matches.
+// A name matched approximately is added to the payee's aliases.
+func (d *payeeDirectory) resolve(raw string) (*models.Payee, error) {
+	key := normalizePayee(raw)
+	if key == "" {
+		return nil, nil
+	}
+
+	payee, exact := d.find(key)
+	switch {
+	case payee == nil:
+		payee = &models.Payee{UserID: d.userID, Name: displayPayeeName(raw), Aliases: []string{}}
+		if err := config.DB.Create(payee).Error; err != nil {
+			return nil, fmt.Errorf("failed to create payee: %w", err)
+		}
+		d.index(payee)
+		log.Printf("New payee #%d %q", payee.ID, payee.Name)
+	case !exact:
+		payee.Aliases = append(payee.Aliases, displayPayeeName(raw))
+		if err := config.DB.Save(payee).Error; err != nil {
+			return nil, fmt.Errorf("failed to add payee alias: %w", err)
+		}
+		d.keys = append(d.keys, payeeKey{key: key, payee: payee})
+		log.Printf("Matched %q to payee #%d %q", raw, payee.ID, payee.Name)
+	}
+	return payee, nil
+}
+
+// Resolve links a transaction, before it is saved, to the payee of its
+// receiver or sender, creating the payee if it is new. It returns the payee,
+// or nil when the transaction has no counterparty.
+func (s *PayeeService) Resolve(t *models.Transaction) *models.Payee {
+	state := lockPayees(t.UserID)
+	defer state.mu.Unlock()
+
+	t.PayeeID = nil
+	directory, err := state.load(t.UserID)
+	if err != nil {
+		log.Printf("Warning: payee not resolved: %v", err)
+		return nil
+	}
+	payee, err := directory.resolve(counterparty(t))
+	if err != nil {
+		// The kept directory may no longer match the database
+		state.directory = nil
+		log.Printf("Warning: payee not resolved: %v", err)
+		return nil
+	}
+	if payee == nil {
+		return nil
+	}
+	// A copy, as the kept payee changes when later names match it
+	resolved := *payee
+	t.PayeeID = &resolved.ID
+	return &resolved
+}
+
+// Learn adds raw as an alias of the payee unless it already matches exactly,
+// so that later slips with the same spelling find it. The spelling is taken
+// off any other payee it was an alias of.
+func (s *PayeeService) Learn(payee *models.Payee, raw string) error {
+	state := lockPayees(payee.UserID)
+	defer state.mu.Unlock()
+
+	key := normalizePayee(raw)
+	if key == "" {
+		return nil
+	}
+	for _, name := range append([]string{payee.Name}, payee.Aliases...) {
+		if normalizePayee(name) == key {
+			return nil
+		}
+	}
+
+	var others []models.Payee
+	if err := config.DB.Where("user_id = ? AND id != ?", payee.UserID, payee.ID).Find(&others).Error; err != nil {
+		return fmt.Errorf("failed to get payees: %w", err)
+	}
+
+	state.directory = nil
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range others {
+			kept := []string{}
+			for _, alias := range others[i].Aliases {
+				if normalizePayee(alias) != key {
+					kept = append(kept, alias)
+				}
+			}
+			if len(kept) == len(others[i].Aliases) {
+				continue
+			}
+			others[i].Aliases = kept
+			if err := tx.Save(&others[i]).Error; err != nil {
+				return fmt.Errorf("failed to move payee alias: %w", err)
+			}
+		}
+
+		payee.Aliases = append(payee.Aliases, displayPayeeName(raw))
+		if err := tx.Save(payee).Error; err != nil {
+			return fmt.Errorf("failed to add payee alias: %w", err)
+		}
+		return nil
+	})
+}
+
+// LinkPayees resolves the payee of every transaction that has a receiver or
+// sender but no payee yet, e.g. those created before payees existed
+func LinkPayees() {
+	var transactions []models.Transaction
+	result := config.DB.Select("id", "user_id", "type", "sender", "receiver").
+		Where("payee_id IS NULL AND (sender != '' OR receiver != '')").
+		Order("user_id, id").Find(&transactions)
+	if result.Error != nil {
+		log.Printf("Warning: failed to find transactions without payee: %v", result.Error)
+		return
+	}
+
+	linked := 0
+	for start := 0; start < len(transactions); {
+		end := start
+		for end < len(transactions) && transactions[end].UserID == transactions[start].UserID {
+			end++
+		}
+		n, err := linkUserPayees(transactions[start].UserID, transactions[start:end])
+		linked += n
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			break
+		}
+		start = end
+	}
+
+	if linked > 0 {
+		log.Printf("Linked %d transactions to payees", linked)
+	}
+}
+
+// linkUserPayees links one user's transactions to their payees and returns
+// how many were linked
+func linkUserPayees(userID uint, transactions []models.Transaction) (int, error) {
+	state := lockPayees(userID)
+	defer state.mu.Unlock()
+
+	directory, err := state.load(userID)
+	if err != nil {
+		return 0, err
+	}
+	linked := 0
+	for i := range transactions {
+		t := &transactions[i]
+		payee, err := directory.resolve(counterparty(t))
+		if err != nil {
+			state.directory = nil
+			return linked, err
+		}
+		if payee == nil {
+			continue
+		}
+		if err := config.DB.Model(t).UpdateColumn("payee_id", payee.ID).Error; err != nil {
+			return linked, fmt.Errorf("failed to link transaction #%d to payee: %w", t.ID, err)
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// cleanAliases trims aliases and drops empty ones, the payee's own name and
+// spellings already listed
+func cleanAliases(name string, aliases []string) []string {
+	seen := map[string]bool{normalizePayee(name): true}
+	cleaned := []string{}
+	for _, alias := range aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		key := normalizePayee(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, alias)
+	}
+	return cleaned
+}
+
+// checkPayeeNames fails when a name or alias of payee already belongs to
+// another of the user's payees. The payees must be locked.
+func (s *PayeeService) checkPayeeNames(state *payeeState, payee *models.Payee) error {
+	directory, err := state.load(payee.UserID)
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{payee.Name}, payee.Aliases...) {
+		key := normalizePayee(name)
+		for _, k := range directory.keys {
+			if k.key == key && k.payee.ID != payee.ID {
+				return fmt.Errorf("%q already belongs to payee #%d %q", name, k.payee.ID, k.payee.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *PayeeService) Create(payee *models.Payee) error {
+	payee.Name = strings.Join(strings.Fields(payee.Name), " ")
+	if normalizePayee(payee.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	payee.Aliases = cleanAliases(payee.Name, payee.Aliases)
+	var err error
+	if payee.DefaultCategory, err = canonicalCategory(payee.UserID, payee.DefaultCategory, "expense"); err != nil {
+		return err
+	}
+
+	state := lockPayees(payee.UserID)
+	defer state.mu.Unlock()
+
+	if err := s.checkPayeeNames(state, payee); err != nil {
+		return err
+	}
+	state.directory = nil
+	if err := config.DB.Create(payee).Error; err != nil {
+		return fmt.Errorf("failed to create payee: %w", err)
+	}
+	return nil
+}
+
+func (s *PayeeService) GetAll(userID uint) ([]models.Payee, error) {
+	var payees []models.Payee
+	result := config.DB.Where("user_id = ?", userID).Order("name ASC").Find(&payees)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get payees: %w", result.Error)
+	}
+	return payees, nil
+}
+
+func (s *PayeeService) GetByID(userID, id uint) (*models.Payee, error) {
+	var payee models.Payee
+	result := config.DB.Where("user_id = ?", userID).First(&payee, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("payee not found: %w", result.Error)
+	}
+	return &payee, nil
+}
+
+// Update replaces a payee's name, aliases and default category
+func (s *PayeeService) Update(userID, id uint, name string, aliases []string, defaultCategory string) (*models.Payee, error) {
+	state := lockPayees(userID)
+	defer state.mu.Unlock()
+
+	payee, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	payee.Name = strings.Join(strings.Fields(name), " ")
+	if normalizePayee(payee.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	payee.Aliases = cleanAliases(payee.Name, aliases)
+	if payee.DefaultCategory, err = canonicalCategory(userID, defaultCategory, "expense"); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPayeeNames(state, payee); err != nil {
+		return nil, err
+	}
+	state.directory = nil
+	if err := config.DB.Save(payee).Error; err != nil {
+		return nil, fmt.Errorf("failed to update payee: %w", err)
+	}
+	return payee, nil
+}
+
+// Delete removes a payee and unlinks its transactions
+func (s *PayeeService) Delete(userID, id uint) error {
+	state := lockPayees(userID)
+	defer state.mu.Unlock()
+
+	state.directory = nil
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&models.Payee{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete payee: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("payee not found")
+		}
+
+		result = tx.Model(&models.Transaction{}).Where("user_id = ? AND payee_id = ?", userID, id).
+			UpdateColumn("payee_id", nil)
+		if result.Error != nil {
+			return fmt.Errorf("failed to unlink transactions: %w", result.Error)
+		}
+		return nil
+	})
+}
+
+// Merge folds other payees into the target: their names and aliases become
+// aliases of the target, and their transactions and rules move to it
+func (s *PayeeService) Merge(userID, targetID uint, sourceIDs []uint) (*models.Payee, error) {
+	state := lockPayees(userID)
+	defer state.mu.Unlock()
+
+	target, err := s.GetByID(userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	state.directory = nil
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range sourceIDs {
+			if id == targetID {
+				return fmt.Errorf("cannot merge payee #%d into itself", id)
+			}
+			var source models.Payee
+			if err := tx.Where("user_id = ?", userID).First(&source, id).Error; err != nil {
+				return fmt.Errorf("payee #%d not found: %w", id, err)
+			}
+
+			target.Aliases = cleanAliases(target.Name, append(append(target.Aliases, source.Name), source.Aliases...))
+			if target.DefaultCategory == "" {
+				target.DefaultCategory = source.DefaultCategory
+			}
+
+			if err := tx.Model(&models.Transaction{}).Where("user_id = ? AND payee_id = ?", userID, id).
+				UpdateColumn("payee_id", targetID).Error; err != nil {
+				return fmt.Errorf("failed to move transactions: %w", err)
+			}
+			if err := tx.Model(&models.CategoryRule{}).Where("user_id = ? AND payee_id = ?", userID, id).
+				Update("payee_id", targetID).Error; err != nil {
+				return fmt.Errorf("failed to move rules: %w", err)
+			}
+			if err := tx.Delete(&source).Error; err != nil {
+				return fmt.Errorf("failed to delete payee: %w", err)
+			}
+		}
+		return tx.Save(target).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizePayee(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "branch, shop word and Thai digits", raw: "ร้าน 7-Eleven สาขา ๑๒๓", want: "7eleven"},
+		{name: "case and punctuation", raw: "7-ELEVEN", want: "7eleven"},
+		{name: "company designators", raw: "บริษัท ซีพี ออลล์ จำกัด (มหาชน)", want: "ซีพีออลล์"},
+		{name: "designator written onto the name", raw: "ร้านค้าจำกัด", want: "ค้า"},
+		{name: "Thai title", raw: "นาย สมชาย ใจดี", want: "สมชายใจดี"},
+		{name: "Thai title without space", raw: "น.ส.สมหญิง", want: "สมหญิง"},
+		{name: "English title", raw: "Mr. John Smith", want: "johnsmith"},
+		{name: "English branch", raw: "Starbucks Branch 12", want: "starbucks"},
+		{name: "split sara am", raw: "ทํา", want: "ทำ"},
+		{name: "Thai digits", raw: "๑๒๓", want: "123"},
+		{name: "title alone is kept", raw: "ร้าน", want: "ร้าน"},
+		{name: "only stop words are kept", raw: "Co., Ltd.", want: "coltd"},
+		{name: "blank", raw: "  ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizePayee(tt.raw); got != tt.want {
+				t.Errorf("normalizePayee(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "", b: "abc", want: 3},
+		{a: "7eleven", b: "7eleven", want: 0},
+		{a: "7eleven", b: "7elevfn", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "flaw", b: "lawn", want: 2},
+		{a: "กาแฟ", b: "กาเฟ", want: 1}, // counted in runes, not bytes
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := editDistance([]rune(tt.b), []rune(tt.a)); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayeeSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "", b: "", want: 1},
+		{a: "7eleven", b: "7eleven", want: 1},
+		{a: "7eleven", b: "7elevfn", want: 1 - 1.0/7},
+		{a: "abcd", b: "wxyz", want: 0},
+		{a: "starbucks", b: "star", want: 4.0 / 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := payeeSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("payeeSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/ocr"
+	"ocr-api/utils"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDraftResolved   = errors.New("draft has already been approved or rejected")
+	ErrDraftIncomplete = errors.New("draft is incomplete")
+)
+
+type ReviewService struct{}
+
+func NewReviewService() *ReviewService {
+	return &ReviewService{}
+}
+
+// ReviewQueue is everything waiting for the user's attention
+type ReviewQueue struct {
+	Drafts  []models.DraftTransaction `json:"drafts"`  // incomplete extractions, not yet transactions
+	Flagged []models.Transaction      `json:"flagged"` // saved transactions with low OCR confidence
+}
+
+// CreateDraft saves an incomplete slip extraction to the review queue
+func (s *ReviewService) CreateDraft(result *SlipResult) (*models.DraftTransaction, error) {
+	t := result.Transaction
+	draft := &models.DraftTransaction{
+		UserID:          t.UserID,
+		Status:          models.DraftPending,
+		Type:            t.Type,
+		Amount:          t.Amount,
+		Date:            t.Date,
+		Time:            t.Time,
+		Reference:       t.Reference,
+		Bank:            t.Bank,
+		Channel:         t.Channel,
+		Sender:          t.Sender,
+		Receiver:        t.Receiver,
+		SenderAccount:   t.SenderAccount,
+		ReceiverAccount: t.ReceiverAccount,
+		Category:        t.Category,
+		Detail:          t.Detail,
+		RawOCRText:      t.RawOCRText,
+		Confidence:      t.Confidence,
+		OCRExtraction:   t.OCRExtraction,
+		OCRDiagnostics:  t.OCRDiagnostics,
+		MissingFields:   strings.Join(result.Missing, ","),
+		ImagePath:       result.ImagePath,
+	}
+
+	if err := config.DB.Create(draft).Error; err != nil {
+		return nil, fmt.Errorf("failed to create draft: %w", err)
+	}
+	return draft, nil
+}
+
+func (s *ReviewService) GetQueue(userID uint) (*ReviewQueue, error) {
+	queue := &ReviewQueue{
+		Drafts:  []models.DraftTransaction{},
+		Flagged: []models.Transaction{},
+	}
+
+	result := config.DB.Where("user_id = ? AND status = ?", userID, models.DraftPending).
+		Order("created_at DESC").Find(&queue.Drafts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get drafts: %w", result.Error)
+	}
+
+	result = config.DB.Where("user_id = ? AND needs_review = ?", userID, true).
+		Order("created_at DESC").Find(&queue.Flagged)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get flagged transactions: %w", result.Error)
+	}
+
+	return queue, nil
+}
+
+func (s *ReviewService) GetDraft(userID, id uint) (*models.DraftTransaction, error) {
+	var draft models.DraftTransaction
+	result := config.DB.Where("user_id = ?", userID).First(&draft, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("draft not found: %w", result.Error)
+	}
+	return &draft, nil
+}
+
+// getPendingDraft is GetDraft restricted to drafts that have not been resolved
+func (s *ReviewService) getPendingDraft(userID, id uint) (*models.DraftTransaction, error) {
+	draft, err := s.GetDraft(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.Status != models.DraftPending {
+		return nil, ErrDraftResolved
+	}
+	return draft, nil
+}
+
+// Correct applies user corrections to a pending draft
+func (s *ReviewService) Correct(userID, id uint, updates map[string]interface{}) (*models.DraftTransaction, error) {
+	draft, err := s.getPendingDraft(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.Model(draft).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update draft: %w", err)
+	}
+	return draft, nil
+}
+
+// Approve turns a pending draft into a transaction, running the same duplicate
+// check and subscription detection as a direct upload.
+func (s *ReviewService) Approve(userID, id uint) (*models.Transaction, error) {
+	draft, err := s.getPendingDraft(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	occurredAt, err := validateForApproval(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		UserID:            draft.UserID,
+		Type:              draft.Type,
+		Amount:            draft.Amount,
+		Date:              draft.Date,
+		Time:              draft.Time,
+		OccurredAt:        occurredAt,
+		Reference:         draft.Reference,
+		Bank:              draft.Bank,
+		Channel:           draft.Channel,
+		Source:            models.SourceSlip,
+		Sender:            draft.Sender,
+		Receiver:          draft.Receiver,
+		SenderAccount:     draft.SenderAccount,
+		ReceiverAccount:   draft.ReceiverAccount,
+		Category:          draft.Category,
+		Detail:            draft.Detail,
+		RawOCRText:        draft.RawOCRText,
+		Confidence:        draft.Confidence,
+		OCRExtraction:     draft.OCRExtraction,
+		OCRDiagnostics:    draft.OCRDiagnostics,
+		OverallConfidence: 1, // confirmed by the user
+	}
+
+	payee := NewPayeeService().Resolve(transaction)
+	NewRuleService().Categorize(transaction)
+
+	detectedSub := NewSubscriptionService().DetectSubscription(draft.RawOCRText, draft.Amount)
+	if detectedSub == nil && payee != nil {
+		detectedSub = NewSubscriptionService().DetectSubscription(payee.Name, draft.Amount)
+	}
+	if err := saveSlipTransaction(transaction, detectedSub); err != nil {
+		return nil, err
+	}
+	NewCorrectionService().RecordCorrections(transaction)
+
+	result := config.DB.Model(draft).Updates(map[string]interface{}{
+		"status":         models.DraftApproved,
+		"transaction_id": transaction.ID,
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update draft: %w", result.Error)
+	}
+	if err := moveDraftAttachments(draft.ID, transaction.ID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	s.removeImage(draft)
+
+	return transaction, nil
+}
+
+// validateForApproval checks that the fields OCR could not read have been
+// filled in, and returns the instant the draft's date and time stand for
+func validateForApproval(draft *models.DraftTransaction) (time.Time, error) {
+	for _, field := range strings.Split(draft.MissingFields, ",") {
+		switch field {
+		case ocr.FieldAmount:
+			if draft.Amount <= 0 {
+				return time.Time{}, fmt.Errorf("%w: amount is required before approving", ErrDraftIncomplete)
+			}
+		case ocr.FieldDate:
+			if strings.TrimSpace(draft.Date) == "" {
+				return time.Time{}, fmt.Errorf("%w: date is required before approving", ErrDraftIncomplete)
+			}
+		}
+	}
+	if draft.Amount <= 0 {
+		return time.Time{}, fmt.Errorf("%w: amount must be positive", ErrDraftIncomplete)
+	}
+
+	occurredAt, err := utils.ParseTransactionTime(draft.Date, draft.Time)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrDraftIncomplete, err)
+	}
+	return occurredAt.UTC(), nil
+}
+
+func (s *ReviewService) Reject(userID, id uint) error {
+	draft, err := s.getPendingDraft(userID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := config.DB.Model(draft).Update("status", models.DraftRejected).Error; err != nil {
+		return fmt.Errorf("failed to reject draft: %w", err)
+	}
+	s.removeImage(draft)
+	removeDraftAttachments(draft.ID)
+
+	return nil
+}
+
+// removeImage deletes a resolved draft's slip image; the OCR text stays on the row
+func (s *ReviewService) removeImage(draft *models.DraftTransaction) {
+	if draft.ImagePath == "" {
+		return
+	}
+	if err := os.Remove(draft.ImagePath); err != nil {
+		log.Printf("Warning: failed to remove draft image %s: %v", draft.ImagePath, err)
+	}
+	config.DB.Model(draft).Update("image_path", "")
+}
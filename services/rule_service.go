@@ -0,0 +1,440 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/utils"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RuleService struct{}
+
+func NewRuleService() *RuleService {
+	return &RuleService{}
+}
+
+// RuleChange is a transaction whose category or detail a rule changes
+type RuleChange struct {
+	TransactionID uint    `json:"transaction_id"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+	Date          string  `json:"date"`
+	Time          string  `json:"time,omitempty"`
+	Sender        string  `json:"sender,omitempty"`
+	Receiver      string  `json:"receiver,omitempty"`
+	PayeeID       *uint   `json:"payee_id,omitempty"`
+	RuleID        uint    `json:"rule_id"` // 0 when the payee's default category was used
+	RuleName      string  `json:"rule_name,omitempty"`
+	Category      string  `json:"category"`
+	NewCategory   string  `json:"new_category"`
+	NewCategoryID *uint   `json:"new_category_id,omitempty"`
+	Detail        string  `json:"detail,omitempty"`
+	NewDetail     string  `json:"new_detail,omitempty"`
+}
+
+var ruleWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// compiledRule is a rule with its patterns and times parsed
+type compiledRule struct {
+	*models.CategoryRule
+	receiver, sender *regexp.Regexp
+	from, to         int // minutes after midnight, -1 when the rule has no time window
+	weekdays         map[time.Weekday]bool
+}
+
+// compileRule validates a rule and prepares it for matching
+func compileRule(rule *models.CategoryRule) (*compiledRule, error) {
+	rule.Category = strings.TrimSpace(rule.Category)
+	if rule.Category == "" {
+		return nil, fmt.Errorf("category is required")
+	}
+	if rule.Type != "" && !utils.ValidateTransactionType(rule.Type) {
+		return nil, fmt.Errorf("invalid type. Must be 'income' or 'expense'")
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return nil, fmt.Errorf("min_amount must not be greater than max_amount")
+	}
+
+	compiled := &compiledRule{CategoryRule: rule, from: -1, to: -1}
+
+	var err error
+	if compiled.receiver, err = compilePattern("receiver_pattern", rule.ReceiverPattern); err != nil {
+		return nil, err
+	}
+	if compiled.sender, err = compilePattern("sender_pattern", rule.SenderPattern); err != nil {
+		return nil, err
+	}
+
+	if (rule.TimeFrom == "") != (rule.TimeTo == "") {
+		return nil, fmt.Errorf("time_from and time_to must be set together")
+	}
+	if rule.TimeFrom != "" {
+		if compiled.from, err = parseClockMinutes(rule.TimeFrom); err != nil {
+			return nil, fmt.Errorf("invalid time_from: %w", err)
+		}
+		if compiled.to, err = parseClockMinutes(rule.TimeTo); err != nil {
+			return nil, fmt.Errorf("invalid time_to: %w", err)
+		}
+	}
+
+	if len(rule.Weekdays) > 0 {
+		compiled.weekdays = map[time.Weekday]bool{}
+		for i, name := range rule.Weekdays {
+			name = strings.ToLower(strings.TrimSpace(name))
+			day, ok := ruleWeekdays[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekday %q. Use mon, tue, wed, thu, fri, sat or sun", rule.Weekdays[i])
+			}
+			rule.Weekdays[i] = name
+			compiled.weekdays[day] = true
+		}
+	}
+
+	return compiled, nil
+}
+
+// compilePattern compiles a case-insensitive rule pattern, nil when empty
+func compilePattern(field, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	return re, nil
+}
+
+// parseClockMinutes reads HH:MM as minutes after midnight
+func parseClockMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches reports whether t meets every condition of the rule
+func (r *compiledRule) matches(t *models.Transaction) bool {
+	if r.Type != "" && r.Type != t.Type {
+		return false
+	}
+	if r.Bank != "" && !strings.EqualFold(r.Bank, t.Bank) {
+		return false
+	}
+	if r.PayeeID != nil && (t.PayeeID == nil || *t.PayeeID != *r.PayeeID) {
+		return false
+	}
+	if r.MinAmount != nil && t.Amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && t.Amount > *r.MaxAmount {
+		return false
+	}
+	if r.receiver != nil && !r.receiver.MatchString(t.Receiver) {
+		return false
+	}
+	if r.sender != nil && !r.sender.MatchString(t.Sender) {
+		return false
+	}
+
+	if r.from < 0 && r.weekdays == nil {
+		return true
+	}
+	at, err := utils.ParseTransactionTime(t.Date, t.Time)
+	if err != nil {
+		return false
+	}
+	if r.weekdays != nil && !r.weekdays[at.Weekday()] {
+		return false
+	}
+	if r.from >= 0 {
+		// Without a time of day the window cannot be checked
+		if strings.TrimSpace(t.Time) == "" {
+			return false
+		}
+		minutes := at.Hour()*60 + at.Minute()
+		if r.from <= r.to {
+			return minutes >= r.from && minutes <= r.to
+		}
+		return minutes >= r.from || minutes <= r.to // e.g. 22:00-02:00
+	}
+	return true
+}
+
+// change returns what the first matching rule, or else the default category
+// of t's payee, would set on t. The detail is only filled in when t has none.
+func change(rules []*compiledRule, payeeCategories map[uint]string, t *models.Transaction) (*RuleChange, bool) {
+	c := &RuleChange{
+		TransactionID: t.ID,
+		Type:          t.Type,
+		Amount:        t.Amount,
+		Date:          t.Date,
+		Time:          t.Time,
+		Sender:        t.Sender,
+		Receiver:      t.Receiver,
+		PayeeID:       t.PayeeID,
+		Category:      t.Category,
+		Detail:        t.Detail,
+		NewDetail:     t.Detail,
+	}
+
+	for _, rule := range rules {
+		if rule.matches(t) {
+			c.RuleID, c.RuleName, c.NewCategory = rule.ID, rule.Name, rule.Category
+			if t.Detail == "" && rule.Detail != "" {
+				c.NewDetail = rule.Detail
+			}
+			return c, c.NewCategory != c.Category || c.NewDetail != c.Detail
+		}
+	}
+
+	if t.PayeeID != nil && payeeCategories[*t.PayeeID] != "" {
+		c.NewCategory = payeeCategories[*t.PayeeID]
+		return c, c.NewCategory != c.Category
+	}
+	return nil, false
+}
+
+// payeeCategories maps the user's payees to their default categories
+func payeeCategories(userID uint) (map[uint]string, error) {
+	var payees []models.Payee
+	result := config.DB.Select("id", "default_category").
+		Where("user_id = ? AND default_category != ''", userID).Find(&payees)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get payee categories: %w", result.Error)
+	}
+
+	categories := make(map[uint]string, len(payees))
+	for _, payee := range payees {
+		categories[payee.ID] = payee.DefaultCategory
+	}
+	return categories, nil
+}
+
+// enabledRules loads the user's enabled rules in the order they are tried
+func (s *RuleService) enabledRules(userID uint) ([]*compiledRule, error) {
+	var rules []models.CategoryRule
+	result := config.DB.Where("user_id = ? AND enabled = ?", userID, true).
+		Order("priority DESC, id ASC").Find(&rules)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", result.Error)
+	}
+
+	compiled := make([]*compiledRule, 0, len(rules))
+	for i := range rules {
+		rule, err := compileRule(&rules[i])
+		if err != nil {
+			log.Printf("Warning: skipping invalid rule #%d: %v", rules[i].ID, err)
+			continue
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// Categorize sets the category of an uncategorized transaction, before it is
+// saved, from the first of the user's rules that matches it, or else from
+// the default category of its payee (see PayeeService.Resolve)
+func (s *RuleService) Categorize(t *models.Transaction) {
+	if t.Category != "" {
+		return
+	}
+
+	rules, err := s.enabledRules(t.UserID)
+	if err != nil {
+		log.Printf("Warning: transaction not categorized: %v", err)
+		return
+	}
+	categories, err := payeeCategories(t.UserID)
+	if err != nil {
+		log.Printf("Warning: transaction not categorized: %v", err)
+		return
+	}
+
+	if c, changed := change(rules, categories, t); changed {
+		log.Printf("Categorized transaction as %s (rule #%d)", c.NewCategory, c.RuleID)
+		t.Category = c.NewCategory
+		t.Detail = c.NewDetail
+	}
+}
+
+// changes lists the transactions the rules would change. Unless overwrite
+// is set only uncategorized transactions are considered.
+func (s *RuleService) changes(userID uint, rules []*compiledRule, categories map[uint]string, filter TransactionFilter, overwrite bool) ([]RuleChange, error) {
+	q := filter.Apply(userTransactions(userID))
+	if !overwrite {
+		q = q.Where("category = '' OR category IS NULL")
+	}
+	rows, err := q.Order("occurred_at ASC, id ASC").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []RuleChange{}
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := config.DB.ScanRows(rows, &transaction); err != nil {
+			return nil, fmt.Errorf("failed to read transaction: %w", err)
+		}
+		if c, changed := change(rules, categories, &transaction); changed {
+			changes = append(changes, *c)
+		}
+	}
+	return changes, rows.Err()
+}
+
+// DryRun shows which of the user's transactions rule would change on its
+// own, without saving the rule or the transactions
+func (s *RuleService) DryRun(userID uint, rule *models.CategoryRule, filter TransactionFilter, overwrite bool) ([]RuleChange, error) {
+	compiled, err := s.validate(rule)
+	if err != nil {
+		return nil, err
+	}
+	return s.changes(userID, []*compiledRule{compiled}, nil, filter, overwrite)
+}
+
+// Apply runs the user's enabled rules over their existing transactions and
+// saves the changes unless dryRun is set. With overwrite, transactions that
+// already have a category are recategorized when a rule matches them.
+func (s *RuleService) Apply(userID uint, filter TransactionFilter, overwrite, dryRun bool) ([]RuleChange, error) {
+	rules, err := s.enabledRules(userID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := payeeCategories(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.changes(userID, rules, categories, filter, overwrite)
+	if err != nil || dryRun {
+		return changes, err
+	}
+
+	// Link the transactions to the categories the rules name
+	linked := map[string]*models.Category{}
+	for i := range changes {
+		c := &changes[i]
+		category, ok := linked[c.NewCategory]
+		if !ok {
+			if category, err = NewCategoryService().Resolve(userID, c.NewCategory, c.Type); err != nil {
+				return nil, err
+			}
+			linked[c.NewCategory] = category
+		}
+		c.NewCategoryID = nil
+		if category != nil {
+			c.NewCategory, c.NewCategoryID = category.Name, &category.ID
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, c := range changes {
+			result := tx.Model(&models.Transaction{}).Where("id = ? AND user_id = ?", c.TransactionID, userID).
+				Updates(map[string]interface{}{"category": c.NewCategory, "category_id": c.NewCategoryID, "detail": c.NewDetail})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rules: %w", err)
+	}
+
+	log.Printf("Rules recategorized %d transactions for user %d", len(changes), userID)
+	return changes, nil
+}
+
+// validate compiles a rule and checks that its payee belongs to the user
+func (s *RuleService) validate(rule *models.CategoryRule) (*compiledRule, error) {
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	if rule.PayeeID != nil {
+		if _, err := NewPayeeService().GetByID(rule.UserID, *rule.PayeeID); err != nil {
+			return nil, fmt.Errorf("payee #%d not found", *rule.PayeeID)
+		}
+	}
+	return compiled, nil
+}
+
+func (s *RuleService) Create(rule *models.CategoryRule) error {
+	if _, err := s.validate(rule); err != nil {
+		return err
+	}
+	var err error
+	if rule.Category, err = canonicalCategory(rule.UserID, rule.Category, rule.Type); err != nil {
+		return err
+	}
+
+	result := config.DB.Create(rule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create rule: %w", result.Error)
+	}
+	return nil
+}
+
+// GetAll lists the user's rules in the order they are tried
+func (s *RuleService) GetAll(userID uint) ([]models.CategoryRule, error) {
+	var rules []models.CategoryRule
+	result := config.DB.Where("user_id = ?", userID).Order("priority DESC, id ASC").Find(&rules)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", result.Error)
+	}
+	return rules, nil
+}
+
+func (s *RuleService) GetByID(userID, id uint) (*models.CategoryRule, error) {
+	var rule models.CategoryRule
+	result := config.DB.Where("user_id = ?", userID).First(&rule, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("rule not found: %w", result.Error)
+	}
+	return &rule, nil
+}
+
+// Update replaces a rule's name, priority, conditions and actions
+func (s *RuleService) Update(userID, id uint, rule *models.CategoryRule) (*models.CategoryRule, error) {
+	existing, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.validate(rule); err != nil {
+		return nil, err
+	}
+	if rule.Category, err = canonicalCategory(userID, rule.Category, rule.Type); err != nil {
+		return nil, err
+	}
+
+	rule.ID = existing.ID
+	rule.UserID = existing.UserID
+	rule.CreatedAt = existing.CreatedAt
+	if err := config.DB.Save(rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *RuleService) Delete(userID, id uint) error {
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.CategoryRule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("rule not found")
+	}
+	return nil
+}
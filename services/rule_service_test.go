@@ -0,0 +1,129 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"ocr-api/models"
+)
+
+func TestCompileRule(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		rule    models.CategoryRule
+		wantErr string
+	}{
+		{name: "category only", rule: models.CategoryRule{Category: "Food"}},
+		{name: "no category", rule: models.CategoryRule{Category: "  "}, wantErr: "category is required"},
+		{name: "invalid type", rule: models.CategoryRule{Category: "Food", Type: "gift"}, wantErr: "invalid type"},
+		{name: "min above max", rule: models.CategoryRule{Category: "Food", MinAmount: amount(100), MaxAmount: amount(50)},
+			wantErr: "min_amount must not be greater than max_amount"},
+		{name: "invalid pattern", rule: models.CategoryRule{Category: "Food", ReceiverPattern: "7-(eleven"}, wantErr: "invalid receiver_pattern"},
+		{name: "time_from without time_to", rule: models.CategoryRule{Category: "Food", TimeFrom: "22:00"},
+			wantErr: "time_from and time_to must be set together"},
+		{name: "invalid time", rule: models.CategoryRule{Category: "Food", TimeFrom: "22:00", TimeTo: "25:00"}, wantErr: "invalid time_to"},
+		{name: "overnight window", rule: models.CategoryRule{Category: "Food", TimeFrom: "22:00", TimeTo: "02:00"}},
+		{name: "invalid weekday", rule: models.CategoryRule{Category: "Food", Weekdays: []string{"mon", "funday"}}, wantErr: `invalid weekday "funday"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileRule(&tt.rule)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("compileRule() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("compileRule() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileRuleNormalizesWeekdays(t *testing.T) {
+	rule := &models.CategoryRule{Category: "Food", Weekdays: []string{" Mon", "SAT "}}
+	if _, err := compileRule(rule); err != nil {
+		t.Fatalf("compileRule() error: %v", err)
+	}
+	if want := []string{"mon", "sat"}; !reflect.DeepEqual(rule.Weekdays, want) {
+		t.Errorf("weekdays = %q, want %q", rule.Weekdays, want)
+	}
+}
+
+func TestCompiledRuleMatches(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+	payee := func(id uint) *uint { return &id }
+
+	tests := []struct {
+		name        string
+		rule        models.CategoryRule
+		transaction models.Transaction
+		want        bool
+	}{
+		{name: "no conditions", rule: models.CategoryRule{},
+			transaction: models.Transaction{Type: "expense", Date: "23/11/2025"}, want: true},
+		{name: "type", rule: models.CategoryRule{Type: "income"},
+			transaction: models.Transaction{Type: "expense"}, want: false},
+		{name: "receiver pattern is case-insensitive", rule: models.CategoryRule{ReceiverPattern: "7-?eleven"},
+			transaction: models.Transaction{Receiver: "7-ELEVEN สาขา 123"}, want: true},
+		{name: "sender pattern", rule: models.CategoryRule{SenderPattern: "^somchai"},
+			transaction: models.Transaction{Sender: "นาย Somchai"}, want: false},
+		{name: "bank", rule: models.CategoryRule{Bank: "kbank"},
+			transaction: models.Transaction{Bank: "KBank"}, want: true},
+		{name: "payee", rule: models.CategoryRule{PayeeID: payee(3)},
+			transaction: models.Transaction{}, want: false},
+		{name: "amount in range", rule: models.CategoryRule{MinAmount: amount(50), MaxAmount: amount(100)},
+			transaction: models.Transaction{Amount: 100}, want: true},
+		{name: "amount below range", rule: models.CategoryRule{MinAmount: amount(50)},
+			transaction: models.Transaction{Amount: 49.99}, want: false},
+
+		// Time windows, in Bangkok time
+		{name: "daytime window", rule: models.CategoryRule{TimeFrom: "11:00", TimeTo: "14:00"},
+			transaction: models.Transaction{Date: "23/11/2025", Time: "14:00"}, want: true},
+		{name: "after daytime window", rule: models.CategoryRule{TimeFrom: "11:00", TimeTo: "14:00"},
+			transaction: models.Transaction{Date: "23/11/2025", Time: "14:01"}, want: false},
+		{name: "overnight window before midnight", rule: models.CategoryRule{TimeFrom: "22:00", TimeTo: "02:00"},
+			transaction: models.Transaction{Date: "23/11/2025", Time: "23:30"}, want: true},
+		{name: "overnight window after midnight", rule: models.CategoryRule{TimeFrom: "22:00", TimeTo: "02:00"},
+			transaction: models.Transaction{Date: "24/11/2025", Time: "01:15"}, want: true},
+		{name: "overnight window end", rule: models.CategoryRule{TimeFrom: "22:00", TimeTo: "02:00"},
+			transaction: models.Transaction{Date: "24/11/2025", Time: "02:00"}, want: true},
+		{name: "outside overnight window", rule: models.CategoryRule{TimeFrom: "22:00", TimeTo: "02:00"},
+			transaction: models.Transaction{Date: "24/11/2025", Time: "12:00"}, want: false},
+		{name: "no time of day never matches a window", rule: models.CategoryRule{TimeFrom: "00:00", TimeTo: "23:59"},
+			transaction: models.Transaction{Date: "24/11/2025"}, want: false},
+		{name: "unparseable date never matches a window", rule: models.CategoryRule{TimeFrom: "00:00", TimeTo: "23:59"},
+			transaction: models.Transaction{Date: "soon", Time: "12:00"}, want: false},
+
+		// Weekdays, in Bangkok time: 24/11/2025 is a Monday, still Sunday in UTC at 01:30
+		{name: "weekday", rule: models.CategoryRule{Weekdays: []string{"sat", "sun"}},
+			transaction: models.Transaction{Date: "23/11/2025"}, want: true},
+		{name: "other weekday", rule: models.CategoryRule{Weekdays: []string{"sat", "sun"}},
+			transaction: models.Transaction{Date: "24/11/2025"}, want: false},
+		{name: "weekday in Bangkok, not UTC", rule: models.CategoryRule{Weekdays: []string{"mon"}},
+			transaction: models.Transaction{Date: "24/11/2025", Time: "01:30"}, want: true},
+		{name: "weekday without a time of day", rule: models.CategoryRule{Weekdays: []string{"mon"}},
+			transaction: models.Transaction{Date: "24/11/2025"}, want: true},
+		{name: "weekday after midnight is the next day", rule: models.CategoryRule{TimeFrom: "22:00", TimeTo: "02:00", Weekdays: []string{"fri"}},
+			transaction: models.Transaction{Date: "29/11/2025", Time: "01:00"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.Category = "Food"
+			compiled, err := compileRule(&rule)
+			if err != nil {
+				t.Fatalf("compileRule() error: %v", err)
+			}
+			if got := compiled.matches(&tt.transaction); got != tt.want {
+				t.Errorf("matches(%+v) = %v, want %v", tt.transaction, got, tt.want)
+			}
+		})
+	}
+}
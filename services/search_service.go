@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/search"
+	"strings"
+)
+
+// ErrSearchUnavailable is returned when SQLite was built without FTS5
+var ErrSearchUnavailable = errors.New("transaction search is not available: the server was built without FTS5 (-tags sqlite_fts5)")
+
+type SearchService struct{}
+
+func NewSearchService() *SearchService {
+	return &SearchService{}
+}
+
+// SearchResult is a matching transaction with its highlighted text
+type SearchResult struct {
+	Transaction models.Transaction `json:"transaction"`
+	Rank        float64            `json:"rank"`       // bm25, lower is a better match
+	Highlights  map[string]string  `json:"highlights"` // column -> snippet HTML-escaped, with <mark> around matches
+}
+
+// Snippets come back with these around matches, so the column text can be
+// HTML-escaped before the marks are turned into <mark> tags
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// Search columns in index order, and how much a match in each counts
+var searchFields = []struct {
+	name   string
+	weight float64
+}{
+	{"raw_ocr_text", 1},
+	{"sender", 4},
+	{"receiver", 4},
+	{"detail", 2},
+}
+
+// Search finds the user's transactions whose OCR text, sender, receiver or
+// detail contain every term of query, best matches first
+func (s *SearchService) Search(userID uint, query string, limit int) ([]SearchResult, error) {
+	if !config.SearchEnabled {
+		return nil, ErrSearchUnavailable
+	}
+
+	match := search.MatchQuery(query)
+	if match == "" {
+		return nil, fmt.Errorf("query has no searchable words")
+	}
+
+	weights := make([]string, len(searchFields))
+	columns := make([]string, len(searchFields))
+	for i, field := range searchFields {
+		weights[i] = fmt.Sprintf("%g", field.weight)
+		columns[i] = fmt.Sprintf("snippet(transaction_search, %d, char(2), char(3), '…', 16)", i)
+	}
+
+	rows, err := config.DB.Raw(fmt.Sprintf(
+		`SELECT rowid, bm25(transaction_search, %s), %s FROM transaction_search
+		WHERE transaction_search MATCH ? AND user_id = ? ORDER BY 2 LIMIT ?`,
+		strings.Join(weights, ", "), strings.Join(columns, ", ")),
+		match, userID, limit).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		var rank float64
+		snippets := make([]string, len(searchFields))
+		dest := []interface{}{&id, &rank}
+		for i := range snippets {
+			dest = append(dest, &snippets[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read search result: %w", err)
+		}
+
+		result := SearchResult{Rank: rank, Highlights: map[string]string{}}
+		for i, snippet := range snippets {
+			if strings.Contains(snippet, matchStart) {
+				result.Highlights[searchFields[i].name] = highlight(snippet)
+			}
+		}
+		results = append(results, result)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+
+	// The index is already scoped, but load through the usual user and
+	// soft-delete conditions so results never outlive their transaction
+	var transactions []models.Transaction
+	if len(ids) > 0 {
+		if err := config.DB.Where("user_id = ? AND id IN ?", userID, ids).Find(&transactions).Error; err != nil {
+			return nil, fmt.Errorf("failed to load search results: %w", err)
+		}
+	}
+	byID := make(map[uint]models.Transaction, len(transactions))
+	for _, t := range transactions {
+		byID[t.ID] = t
+	}
+
+	found := []SearchResult{}
+	for i, id := range ids {
+		if t, ok := byID[id]; ok {
+			results[i].Transaction = t
+			found = append(found, results[i])
+		}
+	}
+	return found, nil
+}
+
+// highlight escapes a snippet's text for HTML and wraps its matches in <mark>
+func highlight(snippet string) string {
+	text := html.EscapeString(strings.ReplaceAll(snippet, search.Separator, ""))
+	return strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(text)
+}
@@ -0,0 +1,24 @@
+package services
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{name: "plain", snippet: "โอนเงินให้ \x027-Eleven\x03 สาขา", want: "โอนเงินให้ <mark>7-Eleven</mark> สาขา"},
+		{name: "markup in text is escaped", snippet: "<img src=x onerror=alert(1)> \x02pay\x03ment", want: "&lt;img src=x onerror=alert(1)&gt; <mark>pay</mark>ment"},
+		{name: "literal mark tags are not trusted", snippet: "</mark><script>\x02x\x03", want: "&lt;/mark&gt;&lt;script&gt;<mark>x</mark>"},
+		{name: "segment separators removed", snippet: "\x02ค่า\u200bน้ำ\x03", want: "<mark>ค่าน้ำ</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.snippet); got != tt.want {
+				t.Errorf("highlight(%q) = %q, want %q", tt.snippet, got, tt.want)
+			}
+		})
+	}
+}
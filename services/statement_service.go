@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"ocr-api/config"
+	"ocr-api/models"
+	"ocr-api/ocr"
+	"ocr-api/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"gorm.io/gorm"
+)
+
+// minTextLayerChars is how much text a PDF page needs before its text layer is
+// trusted; pages with less are treated as scans and OCR'd
+const minTextLayerChars = 40
+
+type StatementService struct{}
+
+func NewStatementService() *StatementService {
+	return &StatementService{}
+}
+
+// StatementDuplicate is a statement row that matched an existing transaction
+type StatementDuplicate struct {
+	Row        string `json:"row"`
+	ExistingID uint   `json:"existing_id"`
+}
+
+// StatementImport is the outcome of importing one statement PDF
+type StatementImport struct {
+	Bank         string               `json:"bank"`
+	PageCount    int                  `json:"page_count"`
+	ScannedPages []int                `json:"scanned_pages"` // pages read with OCR rather than the text layer
+	Transactions []models.Transaction `json:"transactions"`
+	Duplicates   []StatementDuplicate `json:"duplicates"`
+}
+
+func (s *StatementService) ValidateStatement(filename string) error {
+	if ext := filepath.Ext(filename); ext != ".pdf" {
+		return fmt.Errorf("invalid file type: %s. Statements must be PDF", ext)
+	}
+	return nil
+}
+
+// Import reads a PDF statement and saves one transaction per row, skipping
+// rows that match transactions already recorded (e.g. from uploaded slips).
+func (s *StatementService) Import(ctx context.Context, userID uint, pdfPath string) (*StatementImport, error) {
+	pages, err := ocr.ExtractPDFText(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	result := &StatementImport{
+		PageCount:    len(pages),
+		ScannedPages: []int{},
+		Transactions: []models.Transaction{},
+		Duplicates:   []StatementDuplicate{},
+	}
+
+	// Scanned pages go through the same preprocessing and OCR as slips
+	needsReview := false
+	for i, text := range pages {
+		if len(strings.TrimSpace(text)) >= minTextLayerChars {
+			continue
+		}
+
+		page := i + 1
+		ocrResult, err := s.ocrPage(ctx, pdfPath, page)
+		if err != nil {
+			return nil, err
+		}
+		pages[i] = ocrResult.Text
+		result.ScannedPages = append(result.ScannedPages, page)
+
+		if ocrResult.MeanConfidence()/100 < config.AppConfig.ReviewThreshold {
+			needsReview = true
+		}
+	}
+
+	text := strings.Join(pages, "\n")
+	bank, dateLocale := ocr.DetectStatementBank(text)
+	result.Bank = bank
+	log.Printf("Statement bank: %q, %d pages (%d scanned)", bank, len(pages), len(result.ScannedPages))
+
+	rows := ocr.ParseStatement(text, dateLocale)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no transaction rows found in statement")
+	}
+
+	// Rows are saved together or not at all, so a statement that fails halfway
+	// can be imported again without saving its first rows twice
+	var matched []uint
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			transaction := &models.Transaction{
+				UserID:      userID,
+				Type:        row.Type,
+				Amount:      row.Amount,
+				Date:        row.Date,
+				Time:        row.Time,
+				Bank:        bank,
+				Channel:     ocr.ChannelBank,
+				Source:      models.SourceStatement,
+				Detail:      row.Description,
+				RawOCRText:  row.Line,
+				NeedsReview: needsReview,
+			}
+
+			duplicate, err := checkDuplicate(tx, transaction, matched)
+			if err != nil {
+				return err
+			}
+			if duplicate != nil {
+				matched = append(matched, duplicate.ID)
+				result.Duplicates = append(result.Duplicates, StatementDuplicate{Row: row.Line, ExistingID: duplicate.ID})
+				continue
+			}
+
+			NewRuleService().Categorize(transaction)
+			if err := createTransaction(tx, transaction); err != nil {
+				return fmt.Errorf("row %q: %w", row.Line, err)
+			}
+			// Rows within the same statement never duplicate each other
+			matched = append(matched, transaction.ID)
+			result.Transactions = append(result.Transactions, *transaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Statement imported: %d transactions, %d duplicates", len(result.Transactions), len(result.Duplicates))
+	return result, nil
+}
+
+func (s *StatementService) ocrPage(ctx context.Context, pdfPath string, page int) (*ocr.OCRResult, error) {
+	imagePath, err := ocr.RasterizePDFPage(pdfPath, page)
+	if err != nil {
+		return nil, err
+	}
+	defer removeFile(imagePath)
+
+	img, err := imaging.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open page %d: %w", page, err)
+	}
+	processed, _ := ocr.Preprocess(img, ocr.DefaultProfile, ocr.DefaultPreprocessing)
+
+	ocrResult, err := ocr.PerformOCR(ctx, processed, config.AppConfig.TesseractLang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform OCR on page %d: %w", page, err)
+	}
+	ocrResult.Text = utils.CleanOCRText(ocrResult.Text)
+	return ocrResult, nil
+}
+
+func removeFile(path string) {
+	if err := os.Remove(path); err != nil {
+		log.Printf("Warning: failed to cleanup file %s: %v", path, err)
+	}
+}
@@ -18,21 +18,21 @@ func NewSubscriptionService() *SubscriptionService {
 var subscriptionPatterns = []struct {
 	Name     string
 	Patterns []string
-	Category string
+	Category string // key of a built-in category
 }{
 	{
 		Name: "Netflix",
 		Patterns: []string{
 			"(?i)netflix",
 		},
-		Category: "บันเทิง",
+		Category: CategoryEntertainment,
 	},
 	{
 		Name: "Spotify",
 		Patterns: []string{
 			"(?i)spotify",
 		},
-		Category: "บันเทิง",
+		Category: CategoryEntertainment,
 	},
 	{
 		Name: "YouTube Premium",
@@ -40,7 +40,7 @@ var subscriptionPatterns = []struct {
 			"(?i)youtube\\s*premium",
 			"(?i)youtube\\s*music",
 		},
-		Category: "บันเทิง",
+		Category: CategoryEntertainment,
 	},
 	{
 		Name: "LINE MAN",
@@ -48,14 +48,14 @@ var subscriptionPatterns = []struct {
 			"(?i)line\\s*man",
 			"(?i)lineman",
 		},
-		Category: "สมาชิก",
+		Category: CategorySubscriptions,
 	},
 	{
 		Name: "Grab Unlimited",
 		Patterns: []string{
 			"(?i)grab\\s*unlimited",
 		},
-		Category: "สมาชิก",
+		Category: CategorySubscriptions,
 	},
 	{
 		Name: "True ID",
@@ -63,7 +63,7 @@ var subscriptionPatterns = []struct {
 			"(?i)true\\s*id",
 			"(?i)trueid",
 		},
-		Category: "บันเทิง",
+		Category: CategoryEntertainment,
 	},
 	{
 		Name: "Disney+",
@@ -71,7 +71,7 @@ var subscriptionPatterns = []struct {
 			"(?i)disney\\s*\\+",
 			"(?i)disney\\s*plus",
 		},
-		Category: "บันเทิง",
+		Category: CategoryEntertainment,
 	},
 	{
 		Name: "iCloud",
@@ -79,14 +79,14 @@ var subscriptionPatterns = []struct {
 			"(?i)icloud",
 			"(?i)apple\\s*storage",
 		},
-		Category: "คลาวด์",
+		Category: CategoryCloud,
 	},
 	{
 		Name: "Google One",
 		Patterns: []string{
 			"(?i)google\\s*one",
 		},
-		Category: "คลาวด์",
+		Category: CategoryCloud,
 	},
 	{
 		Name: "Adobe",
@@ -94,7 +94,7 @@ var subscriptionPatterns = []struct {
 			"(?i)adobe",
 			"(?i)photoshop",
 		},
-		Category: "ซอฟต์แวร์",
+		Category: CategorySoftware,
 	},
 }
 
@@ -107,7 +107,7 @@ func (s *SubscriptionService) DetectSubscription(ocrText string, amount float64)
 				return &models.Subscription{
 					Name:         sp.Name,
 					Amount:       amount,
-					Category:     sp.Category,
+					Category:     defaultCategoryName(sp.Category),
 					BillingCycle: "monthly", // default
 					IsActive:     true,
 					AutoDetected: true,
@@ -119,6 +119,10 @@ func (s *SubscriptionService) DetectSubscription(ocrText string, amount float64)
 }
 
 func (s *SubscriptionService) Create(subscription *models.Subscription) error {
+	if err := linkCategory(config.DB, subscription.UserID, "expense", &subscription.Category, &subscription.CategoryID); err != nil {
+		return err
+	}
+
 	result := config.DB.Create(subscription)
 	if result.Error != nil {
 		return fmt.Errorf("failed to create subscription: %w", result.Error)
@@ -126,18 +130,18 @@ func (s *SubscriptionService) Create(subscription *models.Subscription) error {
 	return nil
 }
 
-func (s *SubscriptionService) GetAll() ([]models.Subscription, error) {
+func (s *SubscriptionService) GetAll(userID uint) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
-	result := config.DB.Order("is_active DESC, name ASC").Find(&subscriptions)
+	result := config.DB.Where("user_id = ?", userID).Order("is_active DESC, name ASC").Find(&subscriptions)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get subscriptions: %w", result.Error)
 	}
 	return subscriptions, nil
 }
 
-func (s *SubscriptionService) GetActive() ([]models.Subscription, error) {
+func (s *SubscriptionService) GetActive(userID uint) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
-	result := config.DB.Where("is_active = ?", true).
+	result := config.DB.Where("user_id = ? AND is_active = ?", userID, true).
 		Order("name ASC").Find(&subscriptions)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get active subscriptions: %w", result.Error)
@@ -145,22 +149,26 @@ func (s *SubscriptionService) GetActive() ([]models.Subscription, error) {
 	return subscriptions, nil
 }
 
-func (s *SubscriptionService) GetByID(id uint) (*models.Subscription, error) {
+func (s *SubscriptionService) GetByID(userID, id uint) (*models.Subscription, error) {
 	var subscription models.Subscription
-	result := config.DB.First(&subscription, id)
+	result := config.DB.Where("user_id = ?", userID).First(&subscription, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("subscription not found: %w", result.Error)
 	}
 	return &subscription, nil
 }
 
-func (s *SubscriptionService) Update(id uint, updates map[string]interface{}) (*models.Subscription, error) {
+func (s *SubscriptionService) Update(userID, id uint, updates map[string]interface{}) (*models.Subscription, error) {
 	var subscription models.Subscription
-	result := config.DB.First(&subscription, id)
+	result := config.DB.Where("user_id = ?", userID).First(&subscription, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("subscription not found: %w", result.Error)
 	}
 
+	if err := linkCategoryUpdate(userID, "expense", updates); err != nil {
+		return nil, err
+	}
+
 	result = config.DB.Model(&subscription).Updates(updates)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update subscription: %w", result.Error)
@@ -169,8 +177,8 @@ func (s *SubscriptionService) Update(id uint, updates map[string]interface{}) (*
 	return &subscription, nil
 }
 
-func (s *SubscriptionService) Delete(id uint) error {
-	result := config.DB.Delete(&models.Subscription{}, id)
+func (s *SubscriptionService) Delete(userID, id uint) error {
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.Subscription{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete subscription: %w", result.Error)
 	}
@@ -181,10 +189,10 @@ func (s *SubscriptionService) Delete(id uint) error {
 }
 
 // GetUpcoming returns subscriptions with billing dates in the next N days
-func (s *SubscriptionService) GetUpcoming(days int) ([]models.Subscription, error) {
+func (s *SubscriptionService) GetUpcoming(userID uint, days int) ([]models.Subscription, error) {
 	// This is a simplified version - you might want to parse dates properly
 	var subscriptions []models.Subscription
-	result := config.DB.Where("is_active = ? AND next_billing_date != ''", true).
+	result := config.DB.Where("user_id = ? AND is_active = ? AND next_billing_date != ''", userID, true).
 		Order("next_billing_date ASC").Find(&subscriptions)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get upcoming subscriptions: %w", result.Error)
@@ -193,10 +201,10 @@ func (s *SubscriptionService) GetUpcoming(days int) ([]models.Subscription, erro
 }
 
 // CalculateMonthlyTotal calculates total monthly subscription cost
-func (s *SubscriptionService) CalculateMonthlyTotal() (float64, error) {
+func (s *SubscriptionService) CalculateMonthlyTotal(userID uint) (float64, error) {
 	var total float64
 	result := config.DB.Model(&models.Subscription{}).
-		Where("is_active = ? AND billing_cycle = ?", true, "monthly").
+		Where("user_id = ? AND is_active = ? AND billing_cycle = ?", userID, true, "monthly").
 		Select("COALESCE(SUM(amount), 0)").Scan(&total)
 	if result.Error != nil {
 		return 0, fmt.Errorf("failed to calculate total: %w", result.Error)
@@ -211,17 +219,17 @@ func SuggestSubscriptionCategory(receiver string) string {
 	if strings.Contains(receiver, "netflix") || strings.Contains(receiver, "spotify") ||
 		strings.Contains(receiver, "youtube") || strings.Contains(receiver, "true") ||
 		strings.Contains(receiver, "disney") {
-		return "บันเทิง"
+		return defaultCategoryName(CategoryEntertainment)
 	}
 
 	if strings.Contains(receiver, "icloud") || strings.Contains(receiver, "google") ||
 		strings.Contains(receiver, "dropbox") {
-		return "คลาวด์"
+		return defaultCategoryName(CategoryCloud)
 	}
 
 	if strings.Contains(receiver, "adobe") || strings.Contains(receiver, "microsoft") {
-		return "ซอฟต์แวร์"
+		return defaultCategoryName(CategorySoftware)
 	}
 
-	return "สมาชิก"
+	return defaultCategoryName(CategorySubscriptions)
 }
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ocr-api/config"
+	"ocr-api/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransactionFilter narrows a transaction query; zero values match everything.
+// It is shared by the transaction list, exports and the dashboard.
+type TransactionFilter struct {
+	From         time.Time // occurred_at >= From, UTC
+	To           time.Time // occurred_at < To, UTC
+	Type         string
+	Categories   []string
+	Banks        []string
+	Channel      string
+	Payees       []uint
+	Accounts     []uint // either side of a transfer
+	MinAmount    *float64
+	MaxAmount    *float64
+	Sender       string // substring, case-insensitive
+	Receiver     string // substring, case-insensitive
+	HasReference *bool
+}
+
+// userTransactions starts a query over one user's transactions
+func userTransactions(userID uint) *gorm.DB {
+	return config.DB.Model(&models.Transaction{}).Where("user_id = ?", userID)
+}
+
+// Apply adds the filter's conditions to q
+func (f TransactionFilter) Apply(q *gorm.DB) *gorm.DB {
+	if !f.From.IsZero() {
+		q = q.Where("occurred_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("occurred_at < ?", f.To)
+	}
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if len(f.Categories) > 0 {
+		q = q.Where("category IN ?", f.Categories)
+	}
+	if len(f.Banks) > 0 {
+		q = q.Where("bank IN ?", f.Banks)
+	}
+	if f.Channel != "" {
+		q = q.Where("channel = ?", f.Channel)
+	}
+	if len(f.Payees) > 0 {
+		q = q.Where("payee_id IN ?", f.Payees)
+	}
+	if len(f.Accounts) > 0 {
+		q = q.Where("(account_id IN ? OR transfer_account_id IN ?)", f.Accounts, f.Accounts)
+	}
+	if f.MinAmount != nil {
+		q = q.Where("amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		q = q.Where("amount <= ?", *f.MaxAmount)
+	}
+	if f.Sender != "" {
+		q = q.Where(`sender LIKE ? ESCAPE '\'`, likePattern(f.Sender))
+	}
+	if f.Receiver != "" {
+		q = q.Where(`receiver LIKE ? ESCAPE '\'`, likePattern(f.Receiver))
+	}
+	if f.HasReference != nil {
+		if *f.HasReference {
+			q = q.Where("reference IS NOT NULL AND reference != ''")
+		} else {
+			q = q.Where("(reference IS NULL OR reference = '')")
+		}
+	}
+	return q
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern matches value anywhere in a column
+func likePattern(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+// TransactionTotals sums a filtered set of transactions
+type TransactionTotals struct {
+	Count        int64   `json:"count"`
+	TotalIncome  float64 `json:"total_income"`
+	TotalExpense float64 `json:"total_expense"`
+	NetAmount    float64 `json:"net_amount"`
+}
+
+// sumTransactions totals the transactions selected by q
+func sumTransactions(q *gorm.DB) (*TransactionTotals, error) {
+	totals := &TransactionTotals{}
+	row := q.Select("COUNT(*), " +
+		"COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 0), " +
+		"COALESCE(SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END), 0)").Row()
+	if err := row.Scan(&totals.Count, &totals.TotalIncome, &totals.TotalExpense); err != nil {
+		return nil, fmt.Errorf("failed to total transactions: %w", err)
+	}
+	totals.NetAmount = totals.TotalIncome - totals.TotalExpense
+	return totals, nil
+}
+
+// Sort fields for transaction lists
+const (
+	SortCreatedAt = "created_at"
+	SortDate      = "date"
+	SortAmount    = "amount"
+)
+
+// TransactionSort orders a transaction list; ties are broken by ID
+type TransactionSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseTransactionSort reads a sort field and an asc/desc order; the default
+// is newest created first, as the list has always been ordered
+func ParseTransactionSort(field, order string) (TransactionSort, error) {
+	sort := TransactionSort{Field: SortCreatedAt, Desc: true}
+	switch field {
+	case "":
+	case SortCreatedAt, SortDate, SortAmount:
+		sort.Field = field
+	default:
+		return sort, fmt.Errorf("invalid sort %q. Must be amount, date or created_at", field)
+	}
+
+	switch strings.ToLower(order) {
+	case "", "desc":
+	case "asc":
+		sort.Desc = false
+	default:
+		return sort, fmt.Errorf("invalid order %q. Must be asc or desc", order)
+	}
+	return sort, nil
+}
+
+func (s TransactionSort) column() string {
+	if s.Field == SortDate {
+		return "occurred_at"
+	}
+	return s.Field
+}
+
+// ErrInvalidCursor is returned for a cursor that was not produced by List
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// transactionCursor is the position after the last row of a page
+type transactionCursor struct {
+	Amount float64   `json:"a,omitempty"`
+	Time   time.Time `json:"t,omitempty"`
+	ID     uint      `json:"id"`
+}
+
+func (s TransactionSort) encodeCursor(t *models.Transaction) string {
+	cursor := transactionCursor{ID: t.ID}
+	switch s.Field {
+	case SortAmount:
+		cursor.Amount = t.Amount
+	case SortDate:
+		cursor.Time = t.OccurredAt
+	default:
+		cursor.Time = t.CreatedAt
+	}
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(cursor string) (*transactionCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c transactionCursor
+	if err := json.Unmarshal(decoded, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// after limits q to the rows following cursor in this sort order
+func (s TransactionSort) after(q *gorm.DB, cursor string) (*gorm.DB, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{} = c.Time
+	if s.Field == SortAmount {
+		value = c.Amount
+	}
+	op := ">"
+	if s.Desc {
+		op = "<"
+	}
+	column := s.column()
+	return q.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op), value, value, c.ID), nil
+}
+
+func (s TransactionSort) apply(q *gorm.DB) *gorm.DB {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	return q.Order(fmt.Sprintf("%s %s, id %s", s.column(), direction, direction))
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"ocr-api/models"
+)
+
+func TestParseTransactionSort(t *testing.T) {
+	tests := []struct {
+		field, order string
+		want         TransactionSort
+		wantErr      bool
+	}{
+		{want: TransactionSort{Field: SortCreatedAt, Desc: true}},
+		{field: SortDate, order: "asc", want: TransactionSort{Field: SortDate}},
+		{field: SortAmount, order: "DESC", want: TransactionSort{Field: SortAmount, Desc: true}},
+		{field: "receiver", wantErr: true},
+		{field: SortDate, order: "up", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.order, func(t *testing.T) {
+			got, err := ParseTransactionSort(tt.field, tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTransactionSort(%q, %q) error = %v, wantErr %v", tt.field, tt.order, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseTransactionSort(%q, %q) = %+v, want %+v", tt.field, tt.order, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	occurredAt := time.Date(2025, 11, 23, 7, 30, 0, 0, time.UTC)
+	createdAt := time.Date(2025, 11, 24, 1, 2, 3, 456789000, time.UTC)
+	transaction := &models.Transaction{ID: 42, Amount: 1250.50, OccurredAt: occurredAt, CreatedAt: createdAt}
+
+	tests := []struct {
+		field string
+		want  transactionCursor
+	}{
+		{field: SortCreatedAt, want: transactionCursor{ID: 42, Time: createdAt}},
+		{field: SortDate, want: transactionCursor{ID: 42, Time: occurredAt}},
+		{field: SortAmount, want: transactionCursor{ID: 42, Amount: 1250.50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			encoded := TransactionSort{Field: tt.field, Desc: true}.encodeCursor(transaction)
+			got, err := decodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) error: %v", encoded, err)
+			}
+			if got.ID != tt.want.ID || got.Amount != tt.want.Amount || !got.Time.Equal(tt.want.Time) {
+				t.Errorf("decodeCursor(%q) = %+v, want %+v", encoded, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsForeignCursors(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"id":1}`))},
+		{name: "not JSON", cursor: encode("42")},
+		{name: "no ID", cursor: encode(`{"a":10}`)},
+		{name: "wrong types", cursor: encode(`{"id":"42"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) = %+v, %v, want ErrInvalidCursor", tt.cursor, c, err)
+			}
+		})
+	}
+}
@@ -1,11 +1,21 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"ocr-api/config"
 	"ocr-api/models"
+	"ocr-api/utils"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// ErrInvalidDate is returned when a transaction's date and time cannot be
+// parsed into the instant it occurred
+var ErrInvalidDate = errors.New("invalid transaction date")
+
 type TransactionService struct{}
 
 func NewTransactionService() *TransactionService {
@@ -13,98 +23,348 @@ func NewTransactionService() *TransactionService {
 }
 
 func (s *TransactionService) Create(transaction *models.Transaction) error {
-	result := config.DB.Create(transaction)
+	return createTransaction(config.DB, transaction)
+}
+
+// createTransaction saves a new transaction through db, which may be a
+// database transaction that imports several rows at once
+func createTransaction(db *gorm.DB, transaction *models.Transaction) error {
+	if transaction.OccurredAt.IsZero() {
+		occurredAt, err := resolveOccurredAt(transaction.Date, transaction.Time)
+		if err != nil {
+			return err
+		}
+		transaction.OccurredAt = occurredAt
+	}
+	if err := linkAccounts(db, transaction); err != nil {
+		return err
+	}
+	if transaction.Type == "transfer" {
+		// Moving money between own accounts is neither spending nor earning
+		transaction.Category, transaction.CategoryID = "", nil
+	} else if err := linkCategory(db, transaction.UserID, transaction.Type, &transaction.Category, &transaction.CategoryID); err != nil {
+		return err
+	}
+	if err := prepareSplits(db, transaction, transaction.Splits); err != nil {
+		return err
+	}
+
+	result := db.Create(transaction)
 	if result.Error != nil {
 		return fmt.Errorf("failed to create transaction: %w", result.Error)
 	}
+
+	if err := pairTransfer(db, transaction); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 	return nil
 }
 
 // CheckDuplicate checks if a similar transaction already exists
 func (s *TransactionService) CheckDuplicate(transaction *models.Transaction) (*models.Transaction, error) {
+	return s.CheckDuplicateExcluding(transaction, nil)
+}
+
+// CheckDuplicateExcluding is CheckDuplicate ignoring the given transactions,
+// so that each existing transaction is matched by at most one statement row.
+func (s *TransactionService) CheckDuplicateExcluding(transaction *models.Transaction, excludeIDs []uint) (*models.Transaction, error) {
+	return checkDuplicate(config.DB, transaction, excludeIDs)
+}
+
+// checkDuplicate is CheckDuplicateExcluding through db
+func checkDuplicate(db *gorm.DB, transaction *models.Transaction, excludeIDs []uint) (*models.Transaction, error) {
 	var existing models.Transaction
 
+	query := func() *gorm.DB {
+		q := db.Where("user_id = ?", transaction.UserID)
+		if len(excludeIDs) > 0 {
+			q = q.Where("id NOT IN ?", excludeIDs)
+		}
+		return q
+	}
+
 	// Check by reference number first (most reliable)
 	if transaction.Reference != "" {
-		result := config.DB.Where("reference = ? AND reference != ''", transaction.Reference).
+		result := query().Where("reference = ? AND reference != ''", transaction.Reference).
 			First(&existing)
 		if result.Error == nil {
 			return &existing, nil
 		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check for duplicates: %w", result.Error)
+		}
 	}
 
 	// Check by amount + date + time + bank (within same minute)
 	if transaction.Date != "" && transaction.Time != "" {
-		result := config.DB.Where("amount = ? AND date = ? AND time = ? AND bank = ?",
+		result := query().Where("amount = ? AND date = ? AND time = ? AND bank = ?",
 			transaction.Amount, transaction.Date, transaction.Time, transaction.Bank).
 			First(&existing)
 		if result.Error == nil {
 			return &existing, nil
 		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check for duplicates: %w", result.Error)
+		}
+	}
+
+	// Statement rows often have no time; match amount + date (+ bank when known)
+	if transaction.Date != "" && transaction.Time == "" {
+		q := query().Where("amount = ? AND date = ?", transaction.Amount, transaction.Date)
+		if transaction.Bank != "" {
+			q = q.Where("bank = ?", transaction.Bank)
+		}
+		result := q.Order("id ASC").First(&existing)
+		if result.Error == nil {
+			return &existing, nil
+		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check for duplicates: %w", result.Error)
+		}
 	}
 
 	return nil, nil
 }
 
-func (s *TransactionService) GetAll() ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	result := config.DB.Order("created_at DESC").Find(&transactions)
-	if result.Error != nil {
+// Reconciliation outcomes for an imported bank line
+const (
+	MatchMatched     = "matched"
+	MatchNew         = "new"
+	MatchConflicting = "conflicting"
+)
+
+// MatchImported finds the recorded transaction an imported bank line refers
+// to. It starts with CheckDuplicateExcluding, then looks for the same amount
+// within windowDays either side, since a slip's date and the bank's posting
+// date can differ. A match whose type or amount disagrees is conflicting.
+func (s *TransactionService) MatchImported(transaction *models.Transaction, windowDays int, excludeIDs []uint) (string, *models.Transaction, string, error) {
+	existing, err := s.CheckDuplicateExcluding(transaction, excludeIDs)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if existing != nil {
+		if transaction.Reference != "" && existing.Reference == transaction.Reference && existing.Amount != transaction.Amount {
+			return MatchConflicting, existing, fmt.Sprintf("reference %s was recorded with amount %.2f", existing.Reference, existing.Amount), nil
+		}
+		if existing.Type != transaction.Type && existing.Type != "transfer" {
+			return MatchConflicting, existing, fmt.Sprintf("recorded as %s", existing.Type), nil
+		}
+		return MatchMatched, existing, "", nil
+	}
+
+	if transaction.OccurredAt.IsZero() {
+		occurredAt, err := resolveOccurredAt(transaction.Date, transaction.Time)
+		if err != nil {
+			return "", nil, "", err
+		}
+		transaction.OccurredAt = occurredAt
+	}
+	window := time.Duration(windowDays) * 24 * time.Hour
+
+	q := config.DB.Where("user_id = ? AND amount = ? AND occurred_at BETWEEN ? AND ?",
+		transaction.UserID, transaction.Amount,
+		transaction.OccurredAt.Add(-window), transaction.OccurredAt.Add(window))
+	if len(excludeIDs) > 0 {
+		q = q.Where("id NOT IN ?", excludeIDs)
+	}
+	if transaction.Bank != "" {
+		q = q.Where("bank IN ?", []string{transaction.Bank, ""})
+	}
+	var candidates []models.Transaction
+	if result := q.Find(&candidates); result.Error != nil {
+		return "", nil, "", fmt.Errorf("failed to match transaction: %w", result.Error)
+	}
+
+	var best, otherType *models.Transaction
+	for i := range candidates {
+		candidate := &candidates[i]
+		// Different references are different transfers, however close
+		if transaction.Reference != "" && candidate.Reference != "" && candidate.Reference != transaction.Reference {
+			continue
+		}
+		// A transfer between own accounts is an expense on one statement and
+		// income on the other
+		if candidate.Type != transaction.Type && candidate.Type != "transfer" {
+			if otherType == nil {
+				otherType = candidate
+			}
+			continue
+		}
+		if best == nil || closer(candidate.OccurredAt, best.OccurredAt, transaction.OccurredAt) {
+			best = candidate
+		}
+	}
+
+	switch {
+	case best != nil:
+		return MatchMatched, best, fmt.Sprintf("same amount on %s", best.Date), nil
+	case otherType != nil:
+		return MatchConflicting, otherType, fmt.Sprintf("same amount on %s recorded as %s", otherType.Date, otherType.Type), nil
+	}
+	return MatchNew, nil, "", nil
+}
+
+// closer reports whether a is nearer to target than b
+func closer(a, b, target time.Time) bool {
+	da, db := a.Sub(target), b.Sub(target)
+	if da < 0 {
+		da = -da
+	}
+	if db < 0 {
+		db = -db
+	}
+	return da < db
+}
+
+// TransactionPage is one page of a filtered, sorted transaction list
+type TransactionPage struct {
+	Transactions []models.Transaction `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"` // empty on the last page
+	Totals       *TransactionTotals   `json:"totals"`                // over the whole filtered set, not just this page
+}
+
+// List returns up to limit transactions matching filter in the given order,
+// starting after cursor (empty for the first page)
+func (s *TransactionService) List(userID uint, filter TransactionFilter, sort TransactionSort, cursor string, limit int) (*TransactionPage, error) {
+	totals, err := sumTransactions(filter.Apply(userTransactions(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	q := filter.Apply(userTransactions(userID))
+	if cursor != "" {
+		if q, err = sort.after(q, cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether there is a next page
+	transactions := []models.Transaction{}
+	if result := sort.apply(q).Preload("Splits").Limit(limit + 1).Find(&transactions); result.Error != nil {
 		return nil, fmt.Errorf("failed to get transactions: %w", result.Error)
 	}
-	return transactions, nil
+
+	page := &TransactionPage{Transactions: transactions, Totals: totals}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = sort.encodeCursor(&page.Transactions[limit-1])
+	}
+	return page, nil
 }
 
-func (s *TransactionService) GetByID(id uint) (*models.Transaction, error) {
+func (s *TransactionService) GetByID(userID, id uint) (*models.Transaction, error) {
 	var transaction models.Transaction
-	result := config.DB.First(&transaction, id)
+	result := config.DB.Where("user_id = ?", userID).Preload("Splits").First(&transaction, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("transaction not found: %w", result.Error)
 	}
 	return &transaction, nil
 }
 
-func (s *TransactionService) Delete(id uint) error {
-	result := config.DB.Delete(&models.Transaction{}, id)
+func (s *TransactionService) Delete(userID, id uint) error {
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.Transaction{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete transaction: %w", result.Error)
 	}
 	if result.RowsAffected == 0 {
 		return fmt.Errorf("transaction not found")
 	}
-	return nil
-}
 
-func (s *TransactionService) GetByType(transactionType string) ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	result := config.DB.Where("type = ?", transactionType).Order("created_at DESC").Find(&transactions)
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to get transactions: %w", result.Error)
-	}
-	return transactions, nil
-}
-
-func (s *TransactionService) GetByBank(bank string) ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	result := config.DB.Where("bank = ?", bank).Order("created_at DESC").Find(&transactions)
+	// The incoming half of a transfer now counts in its place
+	result = config.DB.Model(&models.Transaction{}).Where("user_id = ? AND transfer_peer_id = ?", userID, id).
+		UpdateColumn("transfer_peer_id", nil)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to get transactions: %w", result.Error)
+		return fmt.Errorf("failed to unlink transfer: %w", result.Error)
 	}
-	return transactions, nil
+	return nil
 }
 
-func (s *TransactionService) Update(id uint, updates map[string]interface{}) (*models.Transaction, error) {
+func (s *TransactionService) Update(userID, id uint, updates map[string]interface{}) (*models.Transaction, error) {
 	var transaction models.Transaction
-	result := config.DB.First(&transaction, id)
+	result := config.DB.Where("user_id = ?", userID).Preload("Splits").First(&transaction, id)
 	if result.Error != nil {
 		return nil, fmt.Errorf("transaction not found: %w", result.Error)
 	}
 
+	// The split lines must keep adding up to the amount
+	if amount, ok := updates["amount"].(float64); ok && len(transaction.Splits) > 0 {
+		var total float64
+		for _, split := range transaction.Splits {
+			total += split.Amount
+		}
+		if !sameAmount(amount, total) {
+			return nil, fmt.Errorf("%w: lines add up to %.2f; change the splits to change the amount", ErrInvalidSplits, total)
+		}
+	}
+
+	// Keep occurred_at in step with the date/time strings
+	_, dateChanged := updates["date"]
+	_, timeChanged := updates["time"]
+	if dateChanged || timeChanged {
+		date, clock := transaction.Date, transaction.Time
+		if v, ok := updates["date"].(string); ok {
+			date = v
+		}
+		if v, ok := updates["time"].(string); ok {
+			clock = v
+		}
+		occurredAt, err := resolveOccurredAt(date, clock)
+		if err != nil {
+			return nil, err
+		}
+		updates["occurred_at"] = occurredAt
+	}
+
+	// A payee chosen by the user must be their own; 0 unlinks the payee
+	payeeService := NewPayeeService()
+	var payee *models.Payee
+	payeeID, payeeChosen := updates["payee_id"]
+	if payeeChosen {
+		if id, _ := payeeID.(uint); id != 0 {
+			var err error
+			if payee, err = payeeService.GetByID(userID, id); err != nil {
+				return nil, err
+			}
+		} else {
+			updates["payee_id"] = nil
+		}
+	}
+
+	kind := transaction.Type
+	if v, ok := updates["type"].(string); ok {
+		kind = v
+	}
+	if err := linkCategoryUpdate(userID, kind, updates); err != nil {
+		return nil, err
+	}
+
 	result = config.DB.Model(&transaction).Updates(updates)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update transaction: %w", result.Error)
 	}
 
+	if err := config.DB.Preload("Splits").First(&transaction, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload transaction: %w", err)
+	}
+
+	// Edits to OCR-extracted values are fed back into extraction
+	NewCorrectionService().RecordCorrections(&transaction)
+
+	// A chosen payee learns this spelling; otherwise the payee follows the
+	// receiver or sender
+	_, senderChanged := updates["sender"]
+	_, receiverChanged := updates["receiver"]
+	if payee != nil {
+		if err := payeeService.Learn(payee, counterparty(&transaction)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	} else if !payeeChosen && (senderChanged || receiverChanged) {
+		payeeService.Resolve(&transaction)
+		if err := config.DB.Model(&transaction).UpdateColumn("payee_id", transaction.PayeeID).Error; err != nil {
+			return nil, fmt.Errorf("failed to update payee: %w", err)
+		}
+	}
+
 	return &transaction, nil
 }
 
@@ -115,6 +375,7 @@ type MonthlySummary struct {
 	NetAmount      float64 `json:"net_amount"`
 	IncomeCount    int     `json:"income_count"`
 	ExpenseCount   int     `json:"expense_count"`
+	TransferCount  int     `json:"transfer_count"` // between own accounts, in neither total
 	TransactionCount int   `json:"transaction_count"`
 }
 
@@ -125,62 +386,101 @@ type CategorySummary struct {
 	Count    int     `json:"count"`
 }
 
-func (s *TransactionService) GetMonthlySummary(year int, month int) (*MonthlySummary, []CategorySummary, error) {
-	// Build date pattern for SQLite (e.g., "%/11/2025" for November 2025)
-	// Supports both DD/MM/YYYY and D/M/YYYY formats
-	monthStr := fmt.Sprintf("%02d", month)
-	yearStr := fmt.Sprintf("%d", year)
-
-	// Match patterns like: 1/11/2025, 01/11/2025, 31/11/2025
-	pattern := fmt.Sprintf("%%/%s/%s", monthStr, yearStr)
+func (s *TransactionService) GetMonthlySummary(userID uint, year int, month int) (*MonthlySummary, []CategorySummary, error) {
+	start, end := utils.MonthRange(year, month)
 
 	var transactions []models.Transaction
-	result := config.DB.Where("date LIKE ?", pattern).Find(&transactions)
+	result := config.DB.Where("user_id = ? AND occurred_at >= ? AND occurred_at < ?", userID, start, end).
+		Preload("Splits").Find(&transactions)
 	if result.Error != nil {
 		return nil, nil, fmt.Errorf("failed to get transactions: %w", result.Error)
 	}
 
-	summary := &MonthlySummary{
-		Month: fmt.Sprintf("%s/%s", monthStr, yearStr),
+	builder := newSummaryBuilder(fmt.Sprintf("%02d/%d", month, year))
+	for i := range transactions {
+		builder.add(&transactions[i])
 	}
 
-	categoryMap := make(map[string]*CategorySummary)
+	summary, categories := builder.result()
+	return summary, categories, nil
+}
 
-	for _, t := range transactions {
-		summary.TransactionCount++
+// summaryBuilder accumulates a MonthlySummary and its category breakdown one
+// transaction at a time, so exports can summarize while streaming
+type summaryBuilder struct {
+	summary    MonthlySummary
+	categories map[string]*CategorySummary
+	order      []string
+}
 
-		if t.Type == "income" {
-			summary.TotalIncome += t.Amount
-			summary.IncomeCount++
-		} else if t.Type == "expense" {
-			summary.TotalExpense += t.Amount
-			summary.ExpenseCount++
-		}
+func newSummaryBuilder(month string) *summaryBuilder {
+	return &summaryBuilder{
+		summary:    MonthlySummary{Month: month},
+		categories: make(map[string]*CategorySummary),
+	}
+}
 
-		// Category breakdown
-		category := t.Category
-		if category == "" {
-			category = "ไม่มีหมวดหมู่"
+func (b *summaryBuilder) add(t *models.Transaction) {
+	b.summary.TransactionCount++
+
+	if t.Type == "income" {
+		b.summary.TotalIncome += t.Amount
+		b.summary.IncomeCount++
+	} else if t.Type == "expense" {
+		b.summary.TotalExpense += t.Amount
+		b.summary.ExpenseCount++
+	} else if t.Type == "transfer" {
+		// A transfer recorded on both accounts counts once
+		if t.TransferPeerID == nil {
+			b.summary.TransferCount++
 		}
+		return
+	}
 
-		key := category + "_" + t.Type
-		if _, exists := categoryMap[key]; !exists {
-			categoryMap[key] = &CategorySummary{
-				Category: category,
-				Type:     t.Type,
-			}
+	// Category breakdown, by line for split transactions
+	if len(t.Splits) == 0 {
+		b.addCategory(t.Category, t.Type, t.Amount)
+		return
+	}
+	for _, split := range t.Splits {
+		b.addCategory(split.Category, t.Type, split.Amount)
+	}
+}
+
+func (b *summaryBuilder) addCategory(category, transactionType string, amount float64) {
+	if category == "" {
+		category = "ไม่มีหมวดหมู่"
+	}
+
+	key := category + "_" + transactionType
+	if _, exists := b.categories[key]; !exists {
+		b.categories[key] = &CategorySummary{
+			Category: category,
+			Type:     transactionType,
 		}
-		categoryMap[key].Total += t.Amount
-		categoryMap[key].Count++
+		b.order = append(b.order, key)
 	}
+	b.categories[key].Total += amount
+	b.categories[key].Count++
+}
 
+func (b *summaryBuilder) result() (*MonthlySummary, []CategorySummary) {
+	summary := b.summary
 	summary.NetAmount = summary.TotalIncome - summary.TotalExpense
 
-	// Convert map to slice
 	var categories []CategorySummary
-	for _, cat := range categoryMap {
-		categories = append(categories, *cat)
+	for _, key := range b.order {
+		categories = append(categories, *b.categories[key])
 	}
+	return &summary, categories
+}
 
-	return summary, categories, nil
+// resolveOccurredAt parses a transaction's date and time into a UTC instant.
+// Dates that cannot be parsed (e.g. raw OCR text) are an ErrInvalidDate.
+func resolveOccurredAt(date, clock string) (time.Time, error) {
+	occurredAt, err := utils.ParseTransactionTime(date, clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w %q %q: %v", ErrInvalidDate, date, clock, err)
+	}
+	return occurredAt.UTC(), nil
 }
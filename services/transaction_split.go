@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"ocr-api/config"
+	"ocr-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSplits is returned for split lines that cannot be saved
+var ErrInvalidSplits = errors.New("invalid splits")
+
+// prepareSplits checks that a transaction's split lines are positive and add
+// up to its amount, and links them to their categories
+func prepareSplits(db *gorm.DB, t *models.Transaction, splits []models.TransactionSplit) error {
+	if len(splits) > 0 && t.Type == "transfer" {
+		return fmt.Errorf("%w: a transfer between own accounts has no categories to split across", ErrInvalidSplits)
+	}
+	var total float64
+	for i, split := range splits {
+		if split.Amount <= 0 {
+			return fmt.Errorf("%w: line %d amount must be positive", ErrInvalidSplits, i+1)
+		}
+		total += split.Amount
+	}
+	if len(splits) > 0 && !sameAmount(total, t.Amount) {
+		return fmt.Errorf("%w: lines add up to %.2f, not the transaction amount %.2f", ErrInvalidSplits, total, t.Amount)
+	}
+
+	for i := range splits {
+		split := &splits[i]
+		split.ID = 0
+		split.TransactionID = t.ID
+		split.UserID = t.UserID
+		split.Note = strings.TrimSpace(split.Note)
+		if err := linkCategory(db, t.UserID, t.Type, &split.Category, &split.CategoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameAmount compares amounts to the satang
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
+// SetSplits replaces the split lines of a transaction; no lines unsplits it
+func (s *TransactionService) SetSplits(userID, id uint, splits []models.TransactionSplit) (*models.Transaction, error) {
+	transaction, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareSplits(config.DB, transaction, splits); err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transaction_id = ?", id).Delete(&models.TransactionSplit{}).Error; err != nil {
+			return fmt.Errorf("failed to remove splits: %w", err)
+		}
+		if len(splits) > 0 {
+			if err := tx.Create(&splits).Error; err != nil {
+				return fmt.Errorf("failed to save splits: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(userID, id)
+}
+
+// categoryLines selects what the user's transactions matching filter spent or
+// earned per category: a line for each split of split transactions and one
+// for every other transaction, with columns transaction_id, type, amount,
+// category_id and category
+func categoryLines(userID uint, filter TransactionFilter) *gorm.DB {
+	whole := filter.Apply(userTransactions(userID)).
+		Where("NOT EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id)").
+		Select("id AS transaction_id, type, amount, category_id, category")
+	split := config.DB.Table("transaction_splits").
+		Joins("JOIN (?) AS parent ON parent.id = transaction_splits.transaction_id", filter.Apply(userTransactions(userID)).Select("id, type")).
+		Select("transaction_splits.transaction_id, parent.type, transaction_splits.amount, transaction_splits.category_id, transaction_splits.category")
+	return config.DB.Table("(? UNION ALL ?) AS lines", whole, split)
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BangkokLocation is the timezone slip dates and times are printed in.
+var BangkokLocation = loadBangkokLocation()
+
+func loadBangkokLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		// No tzdata available; Thailand has no DST so a fixed offset is exact
+		return time.FixedZone("ICT", 7*60*60)
+	}
+	return loc
+}
+
+// ParseTransactionTime combines a transaction date (DD/MM/YYYY as produced by
+// ocr.NormalizeDate, or YYYY-MM-DD) and an optional HH:MM[:SS] time into an
+// instant in Asia/Bangkok. Buddhist-era years are converted to CE, and Thai
+// digits are read as ASCII.
+func ParseTransactionTime(date, clock string) (time.Time, error) {
+	date = asciiDigits(strings.TrimSpace(date))
+	clock = asciiDigits(clock)
+	if date == "" {
+		return time.Time{}, fmt.Errorf("date is empty")
+	}
+
+	year, month, dayOfMonth, err := splitDate(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The year is converted before building the date, so a BE leap day
+	// (29/02/2567) is checked against its CE year
+	if year > 2400 {
+		year -= 543
+	}
+	day := time.Date(year, time.Month(month), dayOfMonth, 0, 0, 0, 0, BangkokLocation)
+	if day.Month() != time.Month(month) || day.Day() != dayOfMonth {
+		return time.Time{}, fmt.Errorf("date out of range %q", date)
+	}
+
+	hour, minute, second := 0, 0, 0
+	if clock = strings.TrimSpace(clock); clock != "" {
+		parts := strings.Split(clock, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return time.Time{}, fmt.Errorf("unrecognized time %q", clock)
+		}
+		values := make([]int, len(parts))
+		for i, part := range parts {
+			values[i], err = strconv.Atoi(part)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("unrecognized time %q", clock)
+			}
+		}
+		hour, minute = values[0], values[1]
+		if len(values) == 3 {
+			second = values[2]
+		}
+		if hour < 0 || hour > 23 || minute < 0 || minute > 59 || second < 0 || second > 59 {
+			return time.Time{}, fmt.Errorf("time out of range %q", clock)
+		}
+	}
+
+	return time.Date(year, day.Month(), day.Day(), hour, minute, second, 0, BangkokLocation), nil
+}
+
+// splitDate reads D/M/YYYY, D-M-YYYY or YYYY-MM-DD
+func splitDate(date string) (year, month, day int, err error) {
+	parts := strings.FieldsFunc(date, func(r rune) bool { return r == '/' || r == '-' })
+	if len(parts) != 3 || (strings.Count(date, "/") != 2 && strings.Count(date, "-") != 2) {
+		return 0, 0, 0, fmt.Errorf("unrecognized date %q", date)
+	}
+	yearPart, monthPart, dayPart := parts[2], parts[1], parts[0]
+	if len(parts[0]) == 4 {
+		if !strings.Contains(date, "-") {
+			return 0, 0, 0, fmt.Errorf("unrecognized date %q", date)
+		}
+		yearPart, dayPart = parts[0], parts[2]
+	}
+	if len(yearPart) != 4 || len(monthPart) > 2 || len(dayPart) > 2 {
+		return 0, 0, 0, fmt.Errorf("unrecognized date %q", date)
+	}
+
+	values := make([]int, 3)
+	for i, part := range []string{yearPart, monthPart, dayPart} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, 0, 0, fmt.Errorf("unrecognized date %q", date)
+			}
+		}
+		values[i], _ = strconv.Atoi(part)
+	}
+	year, month, day = values[0], values[1], values[2]
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return 0, 0, 0, fmt.Errorf("date out of range %q", date)
+	}
+	return year, month, day, nil
+}
+
+// asciiDigits replaces Thai digits (๐-๙) with ASCII ones
+func asciiDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '๐' && r <= '๙' {
+			return '0' + r - '๐'
+		}
+		return r
+	}, s)
+}
+
+// MonthRange returns the [start, end) bounds of a calendar month in Asia/Bangkok, in UTC.
+func MonthRange(year, month int) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, BangkokLocation)
+	return start.UTC(), start.AddDate(0, 1, 0).UTC()
+}
+
+// YearRange returns the [start, end) bounds of a calendar year in Asia/Bangkok, in UTC.
+func YearRange(year int) (time.Time, time.Time) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, BangkokLocation)
+	return start.UTC(), start.AddDate(1, 0, 0).UTC()
+}
+
+// DateRange parses optional from/to dates (YYYY-MM-DD or DD/MM/YYYY) into
+// [start, end) bounds in UTC; to is inclusive of its whole day. A missing
+// bound is returned as the zero time.
+func DateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if from != "" {
+		day, err := ParseTransactionTime(from, "")
+		if err != nil {
+			return start, end, fmt.Errorf("invalid from date: %w", err)
+		}
+		start = day.UTC()
+	}
+	if to != "" {
+		day, err := ParseTransactionTime(to, "")
+		if err != nil {
+			return start, end, fmt.Errorf("invalid to date: %w", err)
+		}
+		end = day.AddDate(0, 0, 1).UTC()
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return start, end, fmt.Errorf("from date must not be after to date")
+	}
+	return start, end, nil
+}
+
+// Date locales a bank template or CSV mapping can declare
+const (
+	DateLocaleTH = "th" // Buddhist-era years, including 2-digit ones like 23/11/68
+	DateLocaleEN = "en" // Gregorian years
+)
+
+// NormalizeDateLocale converts a slip or statement date (23/11/2568,
+// 23 พ.ย. 68) to DD/MM/YYYY with a Gregorian year. With DateLocaleTH, numeric
+// 2-digit years are read as Buddhist era (23/11/68 is 2025). Four-digit years
+// over 2400 are Buddhist era in any locale.
+func NormalizeDateLocale(dateStr, locale string) string {
+	if dateStr == "" {
+		return ""
+	}
+
+	dateStr = strings.TrimSpace(dateStr)
+
+	// Thai month abbreviation map
+	thaiMonths := map[string]string{
+		"ม.ค.":  "01", // มกราคม
+		"ก.พ.":  "02", // กุมภาพันธ์
+		"มี.ค.": "03", // มีนาคม
+		"เม.ย.": "04", // เมษายน
+		"พ.ค.":  "05", // พฤษภาคม
+		"มิ.ย.": "06", // มิถุนายน
+		"ก.ค.":  "07", // กรกฎาคม
+		"ส.ค.":  "08", // สิงหาคม
+		"ก.ย.":  "09", // กันยายน
+		"ต.ค.":  "10", // ตุลาคม
+		"พ.ย.":  "11", // พฤศจิกายน
+		"ธ.ค.":  "12", // ธันวาคม
+	}
+
+	// Try Thai date format first (e.g., "23 พ.ย. 68")
+	reThai := regexp.MustCompile(`(\d{1,2})\s+(ม\.ค\.|ก\.พ\.|มี\.ค\.|เม\.ย\.|พ\.ค\.|มิ\.ย\.|ก\.ค\.|ส\.ค\.|ก\.ย\.|ต\.ค\.|พ\.ย\.|ธ\.ค\.)\s+(\d{2,4})`)
+	matchesThai := reThai.FindStringSubmatch(dateStr)
+
+	if len(matchesThai) == 4 {
+		day := matchesThai[1]
+		monthAbbr := matchesThai[2]
+		year := matchesThai[3]
+
+		month, exists := thaiMonths[monthAbbr]
+		if !exists {
+			month = "01" // default
+		}
+
+		if len(day) == 1 {
+			day = "0" + day
+		}
+
+		// Convert Thai Buddhist year to Western year
+		if len(year) == 2 {
+			yearInt, _ := strconv.Atoi(year)
+			// Thai Buddhist calendar is 543 years ahead
+			// 68 (2568 BE) = 2025 CE
+			if yearInt < 100 {
+				yearInt += 2500 // 68 + 2500 = 2568
+				yearInt -= 543  // 2568 - 543 = 2025
+			}
+			year = fmt.Sprintf("%04d", yearInt)
+		} else if len(year) == 4 {
+			yearInt, _ := strconv.Atoi(year)
+			if yearInt > 2400 { // Likely Buddhist year
+				yearInt -= 543
+			}
+			year = fmt.Sprintf("%04d", yearInt)
+		}
+
+		return fmt.Sprintf("%s/%s/%s", day, month, year)
+	}
+
+	// Try numeric date format (DD/MM/YYYY or DD-MM-YYYY)
+	re := regexp.MustCompile(`(\d{1,2})[/-](\d{1,2})[/-](\d{2,4})`)
+	matches := re.FindStringSubmatch(dateStr)
+
+	if len(matches) == 4 {
+		day := matches[1]
+		month := matches[2]
+		year := matches[3]
+
+		if len(day) == 1 {
+			day = "0" + day
+		}
+		if len(month) == 1 {
+			month = "0" + month
+		}
+
+		if len(year) == 2 {
+			yearInt, _ := strconv.Atoi(year)
+			if locale == DateLocaleTH {
+				year = fmt.Sprintf("%04d", yearInt+2500-543)
+			} else if yearInt > 50 {
+				year = "19" + year
+			} else {
+				year = "20" + year
+			}
+		} else if len(year) == 4 {
+			// Buddhist-era years are printed whatever the locale (23/11/2568)
+			if yearInt, _ := strconv.Atoi(year); yearInt > 2400 {
+				year = fmt.Sprintf("%04d", yearInt-543)
+			}
+		}
+
+		return fmt.Sprintf("%s/%s/%s", day, month, year)
+	}
+
+	return dateStr
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTransactionTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    string
+		clock   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "CE date", date: "23/11/2025", want: bangkok(2025, 11, 23, 0, 0, 0)},
+		{name: "BE date", date: "23/11/2568", want: bangkok(2025, 11, 23, 0, 0, 0)},
+		{name: "single digit day and month", date: "3/1/2568", want: bangkok(2025, 1, 3, 0, 0, 0)},
+		{name: "dashes", date: "23-11-2568", want: bangkok(2025, 11, 23, 0, 0, 0)},
+		{name: "ISO", date: "2025-11-23", want: bangkok(2025, 11, 23, 0, 0, 0)},
+		{name: "with time", date: "23/11/2568", clock: "14:05", want: bangkok(2025, 11, 23, 14, 5, 0)},
+		{name: "with seconds", date: "23/11/2025", clock: "14:05:09", want: bangkok(2025, 11, 23, 14, 5, 9)},
+		{name: "BE leap day", date: "29/02/2567", want: bangkok(2024, 2, 29, 0, 0, 0)},
+		{name: "CE leap day", date: "29/02/2024", want: bangkok(2024, 2, 29, 0, 0, 0)},
+		{name: "BE non-leap year", date: "29/02/2568", wantErr: true},
+		{name: "Thai digits", date: "๒๓/๑๑/๒๕๖๘", clock: "๐๙:๓๐", want: bangkok(2025, 11, 23, 9, 30, 0)},
+		{name: "surrounding spaces", date: " 23/11/2025 ", clock: " 09:30 ", want: bangkok(2025, 11, 23, 9, 30, 0)},
+
+		{name: "empty", date: "", wantErr: true},
+		{name: "day out of range", date: "31/04/2025", wantErr: true},
+		{name: "month out of range", date: "01/13/2025", wantErr: true},
+		{name: "two-digit year", date: "23/11/68", wantErr: true},
+		{name: "Thai month name", date: "23 พ.ย. 68", wantErr: true},
+		{name: "mixed separators", date: "23/11-2025", wantErr: true},
+		{name: "ISO with slashes", date: "2025/11/23", wantErr: true},
+		{name: "letters", date: "2x/11/2025", wantErr: true},
+		{name: "bad time", date: "23/11/2025", clock: "25:00", wantErr: true},
+		{name: "negative time", date: "23/11/2025", clock: "-1:00", wantErr: true},
+		{name: "time without minutes", date: "23/11/2025", clock: "14", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTransactionTime(tt.date, tt.clock)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTransactionTime(%q, %q) = %v, want error", tt.date, tt.clock, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTransactionTime(%q, %q) error: %v", tt.date, tt.clock, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTransactionTime(%q, %q) = %v, want %v", tt.date, tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func bangkok(year int, month time.Month, day, hour, minute, second int) time.Time {
+	return time.Date(year, month, day, hour, minute, second, 0, BangkokLocation)
+}
+
+func TestNormalizeDateLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   string
+		locale string
+		want   string
+	}{
+		{name: "empty", date: "", want: ""},
+		{name: "CE numeric", date: "23/11/2025", want: "23/11/2025"},
+		{name: "BE numeric", date: "23/11/2568", want: "23/11/2025"},
+		{name: "BE numeric with Thai locale", date: "23/11/2568", locale: DateLocaleTH, want: "23/11/2025"},
+		{name: "BE numeric with dashes", date: "3-1-2568", want: "03/01/2025"},
+		{name: "BE leap day", date: "29/02/2567", want: "29/02/2024"},
+		{name: "two-digit year", date: "23/11/25", want: "23/11/2025"},
+		{name: "two-digit BE year with Thai locale", date: "23/11/68", locale: DateLocaleTH, want: "23/11/2025"},
+		{name: "Thai month two-digit year", date: "23 พ.ย. 68", want: "23/11/2025"},
+		{name: "Thai month four-digit year", date: "1 ม.ค. 2568", want: "01/01/2025"},
+		{name: "unrecognized", date: "yesterday", want: "yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeDateLocale(tt.date, tt.locale); got != tt.want {
+				t.Errorf("NormalizeDateLocale(%q, %q) = %q, want %q", tt.date, tt.locale, got, tt.want)
+			}
+		})
+	}
+}
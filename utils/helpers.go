@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/rand"
 	"fmt"
 	"path/filepath"
 	"regexp"
@@ -17,10 +18,14 @@ func Contains(slice []string, item string) bool {
 	return false
 }
 
+// GenerateUniqueFilename names an upload by time, with random bytes so two
+// uploads in the same nanosecond do not overwrite each other
 func GenerateUniqueFilename(originalFilename string) string {
 	ext := filepath.Ext(originalFilename)
 	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%d%s", timestamp, ext)
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%d_%x%s", timestamp, suffix, ext)
 }
 
 func ValidateTransactionType(transactionType string) bool {
@@ -28,6 +33,11 @@ func ValidateTransactionType(transactionType string) bool {
 	return Contains(validTypes, transactionType)
 }
 
+func ValidateChannel(channel string) bool {
+	validChannels := []string{"bank", "wallet", "counter"}
+	return Contains(validChannels, channel)
+}
+
 // CleanOCRText cleans and formats OCR text for better readability
 func CleanOCRText(text string) string {
 	// Remove excessive whitespace and clean up the text
@@ -48,3 +48,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// GetUserID returns the authenticated user's ID set by AuthMiddleware.
+// It returns 0 when the request did not pass through the middleware.
+func GetUserID(c *gin.Context) uint {
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(uint); ok {
+			return id
+		}
+	}
+	return 0
+}